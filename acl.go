@@ -0,0 +1,200 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+const (
+	aclApply  = "apply"
+	aclReport = "report"
+)
+
+// aclMode set by --acl, apply reapplies the ACL of source objects to their
+// copies, report only records what bucket policies they would need.
+var aclMode string
+
+const aclReportFile = "acl_report.txt"
+
+// grantHeaders maps ACL permissions to the header granting them.
+var grantHeaders = map[string]string{
+	"READ":         "X-Amz-Grant-Read",
+	"WRITE":        "X-Amz-Grant-Write",
+	"READ_ACP":     "X-Amz-Grant-Read-Acp",
+	"WRITE_ACP":    "X-Amz-Grant-Write-Acp",
+	"FULL_CONTROL": "X-Amz-Grant-Full-Control",
+}
+
+// cannedPolicies is the bucket policy recommended in place of a canned ACL.
+var cannedPolicies = map[string]string{
+	"public-read":        "allow s3:GetObject to *",
+	"public-read-write":  "allow s3:GetObject and s3:PutObject to *",
+	"authenticated-read": "allow s3:GetObject to all authenticated users",
+	"bucket-owner-read":  "none, the destination bucket owner can read the object",
+}
+
+// aclReporter writes "acl,recommendation,object" records of objects whose
+// ACL is not private with --acl report, and of objects whose ACL could not
+// be read or represented with --acl apply.
+type aclReporter struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+var acls *aclReporter
+
+// openACLReport creates the ACL report in the run directory when --acl is set.
+func openACLReport() error {
+	acls = nil
+	if aclMode == "" {
+		return nil
+	}
+	f, err := createOutputFile(aclReportFile)
+	if err != nil {
+		logDMsg("could not create "+aclReportFile, err)
+		return err
+	}
+	acls = &aclReporter{f: f}
+	return nil
+}
+
+func (a *aclReporter) record(object, acl, recommendation string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := writeRecord(a.f, acl, recommendation, object); err != nil {
+		logMsg(fmt.Sprintf("Error writing ACL of %s: %s", object, err))
+		os.Exit(exitAborted)
+	}
+}
+
+func (a *aclReporter) close() {
+	if a != nil {
+		a.f.Close()
+	}
+}
+
+// objectACL is the ACL of a source object as a canned ACL or as grant
+// headers, unrepresentable lists the grants neither can express.
+type objectACL struct {
+	canned          string
+	grants          map[string][]string
+	unrepresentable []string
+}
+
+func (o objectACL) private() bool {
+	return (o.canned == "" || o.canned == "private") && len(o.grants) == 0 && len(o.unrepresentable) == 0
+}
+
+// String describes the ACL for the report.
+func (o objectACL) String() string {
+	if o.canned != "" {
+		return o.canned
+	}
+	var parts []string
+	for header, grantees := range o.grants {
+		parts = append(parts, strings.TrimPrefix(header, "X-Amz-Grant-")+"="+strings.Join(grantees, " "))
+	}
+	sort.Strings(parts)
+	return strings.Join(append(parts, o.unrepresentable...), ";")
+}
+
+// recommendation returns the bucket or IAM policy replacing the ACL.
+func (o objectACL) recommendation() string {
+	if policy, ok := cannedPolicies[o.canned]; ok {
+		return policy
+	}
+	return "grant the same permissions to the same principals with a bucket or IAM policy"
+}
+
+// getObjectACL reads the ACL of object from the source.
+func getObjectACL(ctx context.Context, object string) (objectACL, error) {
+	ctx, cancel := opContext(ctx)
+	defer cancel()
+	info, err := minioSrcClient.GetObjectACL(ctx, minioSrcBucket, object)
+	if err != nil {
+		return objectACL{}, err
+	}
+	acl := objectACL{canned: info.Metadata.Get("X-Amz-Acl")}
+	if acl.canned != "" {
+		return acl, nil
+	}
+	acl.grants = make(map[string][]string)
+	for _, g := range info.Grant {
+		header, ok := grantHeaders[g.Permission]
+		switch {
+		case !ok:
+			acl.unrepresentable = append(acl.unrepresentable, g.Permission)
+		case g.Permission == "FULL_CONTROL" && g.Grantee.ID == info.Owner.ID:
+			// The owner of the copy has full control anyway.
+		case g.Grantee.ID != "":
+			acl.grants[header] = append(acl.grants[header], fmt.Sprintf("id=%q", g.Grantee.ID))
+		case g.Grantee.URI != "":
+			acl.grants[header] = append(acl.grants[header], fmt.Sprintf("uri=%q", g.Grantee.URI))
+		default:
+			acl.unrepresentable = append(acl.unrepresentable, g.Permission+"=unknown grantee")
+		}
+	}
+	return acl, nil
+}
+
+// applySourceACL returns meta with the ACL headers of object added with
+// --acl apply, and records the ACL in the report. An ACL that cannot be
+// read does not fail the copy, the object is reported instead.
+func applySourceACL(ctx context.Context, object string, stat miniogo.ObjectInfo, meta map[string]string) map[string]string {
+	if aclMode == "" {
+		return meta
+	}
+	acl, err := getObjectACL(ctx, object)
+	if err != nil {
+		logDMsg("could not read ACL of "+object, err)
+		acls.record(object, "unavailable: "+failureReason(err), "check the ACL at the source")
+		return meta
+	}
+	if acl.private() {
+		return meta
+	}
+	if aclMode == aclReport {
+		acls.record(object, acl.String(), acl.recommendation())
+		return meta
+	}
+	if len(acl.unrepresentable) > 0 {
+		acls.record(object, acl.String(), acl.recommendation())
+	}
+	headers := make(map[string]string)
+	if meta == nil && sameCluster {
+		// A server side copy replaces all metadata once any is set.
+		meta = preservedMetadata(stat)
+	}
+	for k, v := range meta {
+		headers[k] = v
+	}
+	if acl.canned != "" {
+		headers["X-Amz-Acl"] = acl.canned
+	}
+	for header, grantees := range acl.grants {
+		headers[header] = strings.Join(grantees, ", ")
+	}
+	return headers
+}
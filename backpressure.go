@@ -0,0 +1,152 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// adaptive set by --adaptive lowers the number of workers taking
+	// objects while the destination slows down.
+	adaptive bool
+	// adaptiveLatency set by --adaptive-latency is the p99 latency of the
+	// destination requests above which the destination counts as slow.
+	adaptiveLatency = 2 * time.Second
+)
+
+const (
+	// adaptiveInterval is how often the destination is assessed.
+	adaptiveInterval = 10 * time.Second
+	// adaptiveMaxBody is the largest request body timed, the latency of
+	// larger uploads is mostly the transfer itself.
+	adaptiveMaxBody = 1 << 20
+)
+
+// destSamples collects the destination responses since the last
+// assessment.
+type destSamples struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	slowDowns int
+}
+
+var destLoad = &destSamples{}
+
+// observeDestination returns rt recording the status and latency of every
+// destination request for --adaptive.
+func observeDestination(rt http.RoundTripper) http.RoundTripper {
+	if !adaptive {
+		return rt
+	}
+	return &observedTransport{rt: rt, s: destLoad}
+}
+
+type observedTransport struct {
+	rt http.RoundTripper
+	s  *destSamples
+}
+
+func (t *observedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	t.s.mu.Lock()
+	// SlowDown is sent as 503, so are the other throttling responses
+	// of S3 compatible servers.
+	if resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusTooManyRequests {
+		t.s.slowDowns++
+	}
+	if req.ContentLength <= adaptiveMaxBody {
+		t.s.latencies = append(t.s.latencies, time.Since(start))
+	}
+	t.s.mu.Unlock()
+	return resp, err
+}
+
+// take returns and resets the samples.
+func (s *destSamples) take() (latencies []time.Duration, slowDowns int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	latencies, slowDowns = s.latencies, s.slowDowns
+	s.latencies, s.slowDowns = nil, 0
+	return latencies, slowDowns
+}
+
+// p99 returns the 99th percentile of latencies, which it sorts.
+func p99(latencies []time.Duration) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies[(len(latencies)*99)/100]
+}
+
+// startAdaptive halves the workers taking objects every adaptiveInterval
+// in which the destination answered with SlowDown or its p99 latency was
+// over --adaptive-latency, and adds back a tenth of the worker pool every
+// interval it was healthy, down to a single worker and up to all of them.
+func startAdaptive() {
+	if !adaptive {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(adaptiveInterval)
+		defer ticker.Stop()
+		// active is the number of workers allowed, zero for all.
+		active := 0
+		for range ticker.C {
+			pool := intake.poolSize()
+			latencies, slowDowns := destLoad.take()
+			if pool == 0 {
+				continue
+			}
+			latency := p99(latencies)
+			switch {
+			case slowDowns > 0 || latency > adaptiveLatency:
+				if active == 0 {
+					active = pool
+				}
+				if active /= 2; active < 1 {
+					active = 1
+				}
+				if intake.setAdaptive(active) {
+					logMsg(fmt.Sprintf("destination is slowing down, %d SlowDown responses and p99 latency %s, reducing to %d workers",
+						slowDowns, latency.Round(time.Millisecond), active))
+				}
+			case active > 0 && len(latencies) > 0:
+				step := pool / 10
+				if step < 1 {
+					step = 1
+				}
+				if active += step; active >= pool {
+					active = 0
+					logMsg("destination recovered, all workers take objects again")
+				} else {
+					logMsg(fmt.Sprintf("destination is keeping up, raising to %d workers", active))
+				}
+				intake.setAdaptive(active)
+			}
+		}
+	}()
+}
@@ -0,0 +1,174 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// bandwidthWindow limits the bandwidth between two times of day, windows
+// ending before they start wrap around midnight.
+type bandwidthWindow struct {
+	start, end int // minutes since midnight
+	rate       int64
+}
+
+var (
+	// maxBandwidth set by --max-bandwidth is the bandwidth in bytes per
+	// second outside of the schedule windows, zero is unlimited.
+	maxBandwidth int64
+	// bandwidthSchedule set by --bandwidth-schedule.
+	bandwidthSchedule []bandwidthWindow
+	// bandwidth throttles the client side transfers of migrate.
+	bandwidth = &bandwidthLimiter{}
+)
+
+// parseBandwidth parses "unlimited", a percentage of --max-bandwidth or a
+// human readable size per second.
+func parseBandwidth(s string) (int64, error) {
+	switch {
+	case s == "unlimited":
+		return 0, nil
+	case strings.HasSuffix(s, "%"):
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || pct <= 0 {
+			return 0, fmt.Errorf("invalid bandwidth %q", s)
+		}
+		if maxBandwidth == 0 {
+			return 0, fmt.Errorf("bandwidth %q needs --max-bandwidth", s)
+		}
+		return int64(float64(maxBandwidth) * pct / 100), nil
+	}
+	n, err := humanize.ParseBytes(s)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q", s)
+	}
+	return int64(n), nil
+}
+
+// parseTimeOfDay parses HH:MM into minutes since midnight.
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseBandwidthSchedule parses "HH:MM-HH:MM=BANDWIDTH,..." e.g.
+// "22:00-06:00=unlimited,06:00-22:00=20%", the first matching window
+// applies.
+func parseBandwidthSchedule(s string) ([]bandwidthWindow, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var windows []bandwidthWindow
+	for _, entry := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid --bandwidth-schedule entry %q, expected HH:MM-HH:MM=BANDWIDTH", entry)
+		}
+		times := strings.SplitN(kv[0], "-", 2)
+		if len(times) != 2 {
+			return nil, fmt.Errorf("invalid --bandwidth-schedule entry %q, expected HH:MM-HH:MM=BANDWIDTH", entry)
+		}
+		var (
+			w   bandwidthWindow
+			err error
+		)
+		if w.start, err = parseTimeOfDay(times[0]); err != nil {
+			return nil, err
+		}
+		if w.end, err = parseTimeOfDay(times[1]); err != nil {
+			return nil, err
+		}
+		if w.rate, err = parseBandwidth(kv[1]); err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// currentBandwidth returns the bandwidth in bytes per second at t, zero
+// is unlimited.
+func currentBandwidth(t time.Time) int64 {
+	m := t.Hour()*60 + t.Minute()
+	for _, w := range bandwidthSchedule {
+		if w.start <= w.end && m >= w.start && m < w.end {
+			return w.rate
+		}
+		if w.start > w.end && (m >= w.start || m < w.end) {
+			return w.rate
+		}
+	}
+	return maxBandwidth
+}
+
+// bandwidthLimiter paces all transfers together to the current bandwidth.
+type bandwidthLimiter struct {
+	mu sync.Mutex
+	// next is when the next byte may be transferred.
+	next time.Time
+}
+
+// wait blocks until n more bytes fit in the current bandwidth, up to a
+// second worth of unused bandwidth can be used as a burst.
+func (l *bandwidthLimiter) wait(n int) {
+	now := time.Now()
+	rate := currentBandwidth(now)
+	if rate <= 0 || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	if l.next.Before(now.Add(-time.Second)) {
+		l.next = now.Add(-time.Second)
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(rate) * float64(time.Second)))
+	d := l.next.Sub(now)
+	l.mu.Unlock()
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// reader paces the data read from r, the schedule is honored as it
+// changes during a transfer.
+func (l *bandwidthLimiter) reader(r io.Reader) io.Reader {
+	if maxBandwidth == 0 && len(bandwidthSchedule) == 0 {
+		return r
+	}
+	return &throttledReader{r: r, l: l}
+}
+
+type throttledReader struct {
+	r io.Reader
+	l *bandwidthLimiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.l.wait(n)
+	return n, err
+}
@@ -0,0 +1,127 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/minio/minio/pkg/console"
+)
+
+// Slack and Teams incoming webhooks set by --slack-webhook and
+// --teams-webhook are told when a run starts, how it progresses every
+// chatInterval and how it ended.
+var (
+	slackWebhook string
+	teamsWebhook string
+	chatInterval time.Duration
+)
+
+// chatEnabled reports whether any chat webhook is configured.
+func chatEnabled() bool {
+	return slackWebhook != "" || teamsWebhook != ""
+}
+
+// postChat posts text with title to the configured webhooks, failures are
+// reported but never affect the run.
+func postChat(title, text string) {
+	if slackWebhook != "" {
+		postWebhook("Slack", slackWebhook, map[string]string{
+			"text": "*" + title + "*\n" + text,
+		})
+	}
+	if teamsWebhook != "" {
+		postWebhook("Teams", teamsWebhook, map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  title,
+			"title":    title,
+			"text":     text,
+		})
+	}
+}
+
+func postWebhook(name, url string, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		console.Errorln(fmt.Sprintf("unable to encode %s message: %v", name, err))
+		return
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The webhook URL is a secret, leave it out of the logs.
+		console.Errorln(fmt.Sprintf("unable to post to %s: %v", name, err))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		console.Errorln(fmt.Sprintf("unable to post to %s: %s", name, resp.Status))
+	}
+}
+
+// startChat announces op and posts its progress every chatInterval until
+// the returned function is called, the outcome is posted by chatRun.
+func startChat(op string, c progressCounter) func() {
+	if !chatEnabled() {
+		return func() {}
+	}
+	host, _ := os.Hostname()
+	go postChat("moveobject: "+op+" started", fmt.Sprintf("Run %s on %s", runID, host))
+	if chatInterval <= 0 {
+		return func() {}
+	}
+	doneCh := make(chan struct{})
+	stoppedCh := make(chan struct{})
+	go func() {
+		defer close(stoppedCh)
+		ticker := time.NewTicker(chatInterval)
+		defer ticker.Stop()
+		stats := &statsLine{at: time.Now()}
+		for {
+			select {
+			case <-doneCh:
+				return
+			case <-ticker.C:
+				postChat("moveobject: "+op+" in progress", fmt.Sprintf("Run %s: %s", runID, stats.next(op, c)))
+			}
+		}
+	}()
+	return func() {
+		close(doneCh)
+		<-stoppedCh
+	}
+}
+
+// chatRun posts the outcome of the run summarized by s.
+func chatRun(s runSummary) {
+	if !chatEnabled() {
+		return
+	}
+	text := fmt.Sprintf("Run %s %s after %s: %d objects, %d failures, %s",
+		s.RunID, s.Status, s.Duration, s.Objects, s.Failures, humanize.IBytes(s.Bytes))
+	if s.Error != "" {
+		text += "\nError: " + s.Error
+	}
+	postChat("moveobject: "+s.Command+" "+s.Status, text)
+}
@@ -0,0 +1,275 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"time"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+var checkCmd = cli.Command{
+	Name:   "check",
+	Usage:  "verify configuration, connectivity and permissions before touching any data",
+	Action: checkAction,
+	Flags:  append(allFlags, sourceFlags...),
+	CustomHelpTemplate: `NAME:
+	 {{.HelpName}} - {{.Usage}}
+ 
+ USAGE:
+	 {{.HelpName}} --data-dir DIR
+ 
+ FLAGS:
+	{{range .VisibleFlags}}{{.}}
+	{{end}}
+ 
+ EXAMPLES:
+ 1. Check a migration from srcbucket to four destination buckets before starting it.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+	$ export MINIO_SOURCE_ACCESS_KEY=minio
+	$ export MINIO_SOURCE_SECRET_KEY=minio123
+	$ export MINIO_DEST_BUCKET_1=dstbucket1
+	$ export MINIO_DEST_BUCKET_2=dstbucket2
+	$ export MINIO_DEST_BUCKET_3=dstbucket3
+	$ export MINIO_DEST_BUCKET_4=dstbucket4
+	$ export MINIO_SOURCE_BUCKET=srcbucket
+	$ moveobject check --data-dir /tmp/
+
+ 2. Check the bucket used by move, copy and delete.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject check --data-dir /tmp/
+ `,
+}
+
+const (
+	// checkObjectPrefix names the probe objects written and removed by
+	// check.
+	checkObjectPrefix = ".moveobject-check-"
+	// checkConnectTimeout bounds the retries of the connectivity check.
+	checkConnectTimeout = 15 * time.Second
+)
+
+// readiness collects the results of the checks.
+type readiness struct {
+	failed int
+}
+
+func (r *readiness) pass(item, detail string) {
+	fmt.Printf("PASS  %-28s %s\n", item, detail)
+}
+
+func (r *readiness) warn(item, detail string) {
+	fmt.Printf("WARN  %-28s %s\n", item, detail)
+}
+
+func (r *readiness) fail(item string, err error) {
+	r.failed++
+	fmt.Printf("FAIL  %-28s %s\n", item, err)
+}
+
+// newCheckClient returns a client for endpoint, reporting what is missing
+// instead of exiting.
+func newCheckClient(cliCtx *cli.Context, r *readiness, name, endpoint, provider, accessKey, secretKey, signature, lookup string) *miniogo.Client {
+	if endpoint == "" || needsKeys(provider) && (accessKey == "" || secretKey == "") {
+		r.fail(name, fmt.Errorf("endpoint, access key and secret key need to be set"))
+		return nil
+	}
+	target, err := url.Parse(endpoint)
+	if err != nil {
+		r.fail(name, fmt.Errorf("unable to parse %s: %v", endpoint, err))
+		return nil
+	}
+	tr, err := newTransport(cliCtx)
+	if err != nil {
+		r.fail(name, err)
+		return nil
+	}
+	creds, err := newCredentials(provider, endpoint, accessKey, secretKey, signature, tr)
+	if err != nil {
+		r.fail(name, err)
+		return nil
+	}
+	bucketLookup, err := newBucketLookup(lookup)
+	if err != nil {
+		r.fail(name, err)
+		return nil
+	}
+	clnt, err := miniogo.New(target.Host, &miniogo.Options{
+		Creds:        creds,
+		Secure:       target.Scheme == "https",
+		Transport:    tr,
+		Region:       "us-east-1",
+		BucketLookup: bucketLookup,
+	})
+	if err != nil {
+		r.fail(name, err)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), checkConnectTimeout)
+	defer cancel()
+	if _, err = clnt.ListBuckets(ctx); err != nil && miniogo.ToErrorResponse(err).Code == "" {
+		// Access denied still proves the endpoint is reachable.
+		r.fail(name, fmt.Errorf("unable to reach %s: %v", endpoint, err))
+		return nil
+	}
+	r.pass(name, "connected to "+endpoint)
+	return clnt
+}
+
+// checkBucket verifies the permissions needed on bucket, write checks
+// put, read back and delete a small probe object.
+func checkBucket(clnt *miniogo.Client, r *readiness, name, bucket string, write bool) {
+	if bucket == "" {
+		r.fail(name, fmt.Errorf("bucket is not set"))
+		return
+	}
+	item := name + " " + bucket
+	ctx, cancel := opContext(context.Background())
+	defer cancel()
+
+	var first string
+	for object := range clnt.ListObjects(ctx, bucket, miniogo.ListObjectsOptions{MaxKeys: 1}) {
+		if object.Err != nil {
+			r.fail(item+" list", object.Err)
+			return
+		}
+		first = object.Key
+		break
+	}
+	r.pass(item+" list", "ok")
+
+	if vc, err := clnt.GetBucketVersioning(ctx, bucket); err != nil {
+		r.fail(item+" versioning", err)
+	} else if vc.Status != "Enabled" {
+		r.warn(item+" versioning", "not enabled, version IDs are not preserved")
+	} else {
+		r.pass(item+" versioning", "enabled")
+	}
+
+	if !write {
+		if first == "" {
+			r.warn(item+" get", "bucket is empty, read permission not verified")
+			return
+		}
+		if _, err := clnt.StatObject(ctx, bucket, first, miniogo.StatObjectOptions{}); err != nil {
+			r.fail(item+" get", err)
+			return
+		}
+		r.pass(item+" get", "ok")
+		return
+	}
+
+	probe := checkObjectPrefix + runID
+	data := []byte(runID)
+	if _, err := clnt.PutObject(ctx, bucket, probe, bytes.NewReader(data), int64(len(data)), miniogo.PutObjectOptions{}); err != nil {
+		r.fail(item+" put", err)
+		return
+	}
+	r.pass(item+" put", "ok")
+	obj, err := clnt.GetObject(ctx, bucket, probe, miniogo.GetObjectOptions{})
+	if err == nil {
+		var got []byte
+		if got, err = ioutil.ReadAll(obj); err == nil && !bytes.Equal(got, data) {
+			err = fmt.Errorf("read back %d bytes, expected %d", len(got), len(data))
+		}
+		obj.Close()
+	}
+	if err != nil {
+		r.fail(item+" get", err)
+	} else {
+		r.pass(item+" get", "ok")
+	}
+	// Remove all versions of the probe so nothing is left behind.
+	opts := miniogo.ListObjectsOptions{Prefix: probe, WithVersions: true}
+	for object := range clnt.ListObjects(ctx, bucket, opts) {
+		if object.Err != nil {
+			err = object.Err
+			break
+		}
+		if err = clnt.RemoveObject(ctx, bucket, object.Key, miniogo.RemoveObjectOptions{VersionID: object.VersionID}); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		r.fail(item+" delete", err)
+		return
+	}
+	r.pass(item+" delete", "ok")
+}
+
+func checkAction(cliCtx *cli.Context) error {
+	checkArgsAndInit(cliCtx)
+	r := &readiness{}
+	start := time.Now()
+
+	if fi, err := os.Stat(dirPath); err != nil {
+		r.fail("data-dir", err)
+	} else if !fi.IsDir() {
+		r.fail("data-dir", fmt.Errorf("%s is not a directory", dirPath))
+	} else if f, err := ioutil.TempFile(dirPath, checkObjectPrefix); err != nil {
+		r.fail("data-dir", fmt.Errorf("%s is not writable: %v", dirPath, err))
+	} else {
+		f.Close()
+		os.Remove(f.Name())
+		r.pass("data-dir", dirPath)
+	}
+	if _, err := os.Stat(path.Join(dirPath, objListFile)); err != nil {
+		r.warn("listing", objListFile+" not found, only needed by copy, delete, migrate and retry")
+	} else {
+		r.pass("listing", objListFile)
+	}
+
+	dst := newCheckClient(cliCtx, r, "destination", cliCtx.String("endpoint"), cliCtx.String("credentials"), cliCtx.String("access-key"), cliCtx.String("secret-key"), cliCtx.String("signature"), cliCtx.String("lookup"))
+	if dst != nil {
+		if bucket := cliCtx.String("bucket"); bucket != "" {
+			checkBucket(dst, r, "bucket", bucket, true)
+		}
+		for _, flag := range destBucketFlags(cliCtx) {
+			if bucket := cliCtx.String(flag); bucket != "" {
+				checkBucket(dst, r, flag, bucket, true)
+			}
+		}
+	}
+	if cliCtx.String("src-endpoint") != "" || cliCtx.String("src-bucket") != "" {
+		src := newCheckClient(cliCtx, r, "source", cliCtx.String("src-endpoint"), cliCtx.String("src-credentials"), cliCtx.String("src-access-key"), cliCtx.String("src-secret-key"), cliCtx.String("src-signature"), cliCtx.String("src-lookup"))
+		if src != nil {
+			checkBucket(src, r, "src-bucket", cliCtx.String("src-bucket"), false)
+		}
+	}
+
+	if r.failed > 0 {
+		fmt.Printf("not ready, %d failed checks in %s\n", r.failed, time.Since(start).Round(time.Millisecond))
+		raiseExitCode(exitConfig)
+		return nil
+	}
+	fmt.Printf("ready, all checks passed in %s\n", time.Since(start).Round(time.Millisecond))
+	return nil
+}
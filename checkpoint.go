@@ -0,0 +1,287 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/console"
+)
+
+// checkpointFile in the run directory records how far into the listing a run got,
+// --resume seeks straight to that offset instead of reading and counting
+// the entries before it like --skip.
+const checkpointFile = "checkpoint.json"
+
+// checkpointInterval is how often the checkpoint file is rewritten.
+const checkpointInterval = 10 * time.Second
+
+// checkpointState is the content of the checkpoint file. The listing is
+// identified by its size and modification time, a checkpoint is only
+// valid for the very file it was taken on.
+type checkpointState struct {
+	Command      string    `json:"command"`
+	File         string    `json:"file"`
+	FileSize     int64     `json:"fileSize"`
+	FileModified time.Time `json:"fileModified"`
+	Offset       int64     `json:"offset"`
+	Entries      uint64    `json:"entries"`
+	Updated      time.Time `json:"updated"`
+}
+
+// checkpointEntry is a queued listing entry that is not done yet.
+type checkpointEntry struct {
+	start int64
+	index uint64
+	done  bool
+}
+
+// listingCheckpoint tracks the listing entries of a run. The checkpoint is
+// the start of the first queued entry that is not done, entries that
+// were read but not queued, e.g. skipped shards or succeeded keys, count
+// as done once the next entry is read.
+type listingCheckpoint struct {
+	mu    sync.Mutex
+	path  string
+	state checkpointState
+	// start and end are the offsets of the entry read last, its index is
+	// scanned-1 and queued tells whether it was handed to a worker.
+	start, end int64
+	scanned    uint64
+	queued     bool
+	entries    []*checkpointEntry
+	pending    map[string][]*checkpointEntry
+
+	doneCh    chan struct{}
+	stoppedCh chan struct{}
+}
+
+// checkpoint is the checkpoint of the running command, nil when its input
+// is not a listing file.
+var checkpoint *listingCheckpoint
+
+// startCheckpoint starts tracking the entries read by scanner from name
+// in data-dir. With --resume it first seeks scanner to the checkpoint of
+// the latest run of command reading name.
+func startCheckpoint(cliCtx *cli.Context, scanner *recordScanner, name string) error {
+	fi, err := os.Stat(path.Join(dirPath, name))
+	if err != nil {
+		return err
+	}
+	c := &listingCheckpoint{
+		state: checkpointState{
+			Command:      cliCtx.Command.Name,
+			File:         name,
+			FileSize:     fi.Size(),
+			FileModified: fi.ModTime().UTC(),
+		},
+		pending:   make(map[string][]*checkpointEntry),
+		queued:    true,
+		doneCh:    make(chan struct{}),
+		stoppedCh: make(chan struct{}),
+	}
+	if c.path, err = runPath(checkpointFile); err != nil {
+		return err
+	}
+	c.start, c.end = scanner.dataStart, scanner.dataStart
+	if cliCtx.Bool("resume") {
+		prev, err := resumeScanner(cliCtx, scanner, name)
+		if err != nil {
+			return err
+		}
+		c.start, c.end, c.scanned = prev.Offset, prev.Offset, prev.Entries
+		logMsg(fmt.Sprintf("resuming %s at entry %d, offset %d", name, prev.Entries, prev.Offset))
+	}
+	checkpoint = c
+	flushCh := make(chan struct{}, 1)
+	setFlushCh(&checkpointFlushCh, flushCh)
+	go func() {
+		defer close(c.stoppedCh)
+		ticker := time.NewTicker(checkpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.doneCh:
+				c.write()
+				return
+			case <-ticker.C:
+				c.write()
+			case <-flushCh:
+				c.write()
+			}
+		}
+	}()
+	return nil
+}
+
+// resumeScanner seeks scanner to the checkpoint of the latest run of the
+// command reading name in data-dir and returns that checkpoint.
+func resumeScanner(cliCtx *cli.Context, scanner *recordScanner, name string) (checkpointState, error) {
+	if cliCtx.Int("skip") > 0 {
+		return checkpointState{}, fmt.Errorf("--resume and --skip cannot be used together")
+	}
+	fi, err := os.Stat(path.Join(dirPath, name))
+	if err != nil {
+		return checkpointState{}, err
+	}
+	prev, err := lastCheckpoint(cliCtx.Command.Name, name)
+	if err != nil {
+		return prev, err
+	}
+	if prev.FileSize != fi.Size() || !prev.FileModified.Equal(fi.ModTime().UTC()) {
+		return prev, fmt.Errorf("%s changed since %s was written, run without --resume", name, checkpointFile)
+	}
+	if err = scanner.seek(prev.Offset); err != nil {
+		return prev, fmt.Errorf("unable to resume %s at offset %d: %v", name, prev.Offset, err)
+	}
+	return prev, nil
+}
+
+// lastCheckpoint returns the checkpoint of the latest run of command
+// reading name.
+func lastCheckpoint(command, name string) (checkpointState, error) {
+	files, err := artifactFiles(checkpointFile)
+	if err != nil {
+		return checkpointState{}, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		prev, err := readCheckpoint(files[i])
+		if err != nil {
+			return prev, err
+		}
+		if prev.Command == command && prev.File == name {
+			return prev, nil
+		}
+	}
+	return checkpointState{}, fmt.Errorf("no %s of %s reading %s found in %s for --resume", checkpointFile, command, name, dirPath)
+}
+
+func readCheckpoint(name string) (checkpointState, error) {
+	var prev checkpointState
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return prev, fmt.Errorf("unable to read %s for --resume: %v", checkpointFile, err)
+	}
+	if err = json.Unmarshal(data, &prev); err != nil {
+		return prev, fmt.Errorf("unable to parse %s: %v", checkpointFile, err)
+	}
+	return prev, nil
+}
+
+// scan advances scanner and records the offsets of the entry read.
+func (c *listingCheckpoint) scan(scanner *recordScanner) bool {
+	if !scanner.Scan() {
+		if scanner.Err() == nil {
+			c.mu.Lock()
+			// Reached the end, the last entry is decided.
+			c.queued = true
+			c.mu.Unlock()
+		}
+		return false
+	}
+	c.mu.Lock()
+	c.start, c.end = c.end, scanner.Offset()
+	c.scanned++
+	c.queued = false
+	c.mu.Unlock()
+	return true
+}
+
+// queue records that object, the entry read last, was handed to a worker.
+func (c *listingCheckpoint) queue(object string) {
+	c.mu.Lock()
+	e := &checkpointEntry{start: c.start, index: c.scanned - 1}
+	c.entries = append(c.entries, e)
+	c.pending[object] = append(c.pending[object], e)
+	c.queued = true
+	c.mu.Unlock()
+}
+
+// done records that a worker finished with object, successfully or not.
+func (c *listingCheckpoint) done(object string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := c.pending[object]
+	if len(list) == 0 {
+		return
+	}
+	list[0].done = true
+	if len(list) == 1 {
+		delete(c.pending, object)
+	} else {
+		c.pending[object] = list[1:]
+	}
+	for len(c.entries) > 0 && c.entries[0].done {
+		c.entries[0] = nil
+		c.entries = c.entries[1:]
+	}
+}
+
+// position returns the offset and index of the first entry not done.
+func (c *listingCheckpoint) position() (int64, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case len(c.entries) > 0:
+		return c.entries[0].start, c.entries[0].index
+	case !c.queued:
+		// The entry read last was neither queued nor passed over yet,
+		// e.g. --limit stopped the run on it.
+		return c.start, c.scanned - 1
+	}
+	return c.end, c.scanned
+}
+
+// write atomically replaces the checkpoint file.
+func (c *listingCheckpoint) write() {
+	s := c.state
+	s.Offset, s.Entries = c.position()
+	s.Updated = time.Now()
+	body, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		console.Errorln(fmt.Sprintf("unable to encode %s: %v", checkpointFile, err))
+		return
+	}
+	if err = ioutil.WriteFile(c.path+".tmp", append(body, '\n'), 0600); err == nil {
+		err = os.Rename(c.path+".tmp", c.path)
+	}
+	if err != nil {
+		console.Errorln(fmt.Sprintf("unable to write %s: %v", checkpointFile, err))
+	}
+}
+
+// stopCheckpoint writes the checkpoint one last time, it is called once
+// the workers are done.
+func stopCheckpoint() {
+	if checkpoint == nil {
+		return
+	}
+	setFlushCh(&checkpointFlushCh, nil)
+	close(checkpoint.doneCh)
+	<-checkpoint.stoppedCh
+	checkpoint = nil
+}
@@ -0,0 +1,94 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+// checksumAlgo set by --checksum is the algorithm used to verify every
+// migrated object by reading it back from the destination, empty when
+// disabled. Checksums are base64 encoded like x-amz-checksum-* headers.
+var checksumAlgo string
+
+// errChecksumMismatch is returned when the destination copy reads back
+// with another checksum than the source.
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// parseChecksum validates the --checksum value.
+func parseChecksum(algo string) (string, error) {
+	switch algo {
+	case "", "crc32c", "sha256":
+		return algo, nil
+	}
+	return "", fmt.Errorf("unknown checksum %q, should be one of crc32c or sha256", algo)
+}
+
+// newChecksum returns a new hash of checksumAlgo.
+func newChecksum() hash.Hash {
+	if checksumAlgo == "sha256" {
+		return sha256.New()
+	}
+	return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+}
+
+func encodeChecksum(h hash.Hash) string {
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// objectChecksum reads object with opts and returns its checksum.
+func objectChecksum(ctx context.Context, client *miniogo.Client, bucket, object string, opts miniogo.GetObjectOptions) (string, error) {
+	ctx, opened := openContext(ctx)
+	r, err := client.GetObject(ctx, bucket, object, opts)
+	if err != nil {
+		opened()
+		return "", err
+	}
+	defer r.Close()
+	// Stat sends the GET, only its response is bounded by --op-timeout.
+	_, err = r.Stat()
+	opened()
+	if err != nil {
+		return "", err
+	}
+	h := newChecksum()
+	if _, err = io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return encodeChecksum(h), nil
+}
+
+// verifyChecksum compares want with the checksum of the destination copy.
+func verifyChecksum(ctx context.Context, bucket, object, versionID, want string) error {
+	got, err := objectChecksum(ctx, minioClient, bucket, object, miniogo.GetObjectOptions{VersionID: versionID})
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%w, %s of source %s, destination %s", errChecksumMismatch, checksumAlgo, want, got)
+	}
+	return nil
+}
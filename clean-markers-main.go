@@ -0,0 +1,156 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio/pkg/console"
+)
+
+var cleanMarkersFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "orphaned-only",
+		Usage: "only remove delete markers of objects that have no other versions left",
+	},
+	cli.BoolFlag{
+		Name:  "fake",
+		Usage: "perform a fake cleanup",
+	},
+	cli.StringFlag{
+		Name:  "shard",
+		Usage: "only process shard i of N of the listing e.g. 0/4, keys are partitioned by hash",
+	},
+}
+
+var cleanMarkersCmd = cli.Command{
+	Name:   "clean-markers",
+	Usage:  "remove dangling delete markers from a versioned bucket",
+	Action: cleanMarkersAction,
+	Flags:  append(allFlags, cleanMarkersFlags...),
+	CustomHelpTemplate: `NAME:
+	 {{.HelpName}} - {{.Usage}}
+ 
+ USAGE:
+	 {{.HelpName}} [--orphaned-only, --fake]
+ 
+ DESCRIPTION:
+	A delete marker is dangling when removing it does not change which
+	objects are visible, either because it is not the latest version of its
+	object or because no other versions of its object are left. Latest
+	delete markers hiding older versions are always kept.
+ 
+ FLAGS:
+	{{range .VisibleFlags}}{{.}}
+	{{end}}
+ 
+ EXAMPLES:
+ 1. Remove dangling delete markers in MinIO.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject clean-markers --data-dir /tmp/
+ 
+ 2. Perform a dry run for removing only delete markers whose objects have no versions left.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject clean-markers --data-dir /tmp/ --orphaned-only --fake --log
+ `,
+}
+
+func cleanMarkersAction(cliCtx *cli.Context) (err error) {
+	checkArgsAndInit(cliCtx)
+	ctx := context.Background()
+	logMsg("Init minio client..")
+	if err := initMinioClient(cliCtx); err != nil {
+		logDMsg("Unable to  initialize MinIO client, exiting...%w", err)
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
+		console.Fatalln(err)
+	}
+	cleanState = newCleanMarkersState(ctx)
+	cleanState.init(ctx)
+	start := time.Now()
+	defer func() { reportRun("clean-markers", cleanState, start, err) }()
+	dryRun = cliCtx.Bool("fake")
+	if err := queueDanglingMarkers(ctx, cliCtx.Bool("orphaned-only")); err != nil {
+		return err
+	}
+	cleanState.finish(ctx)
+	logMsg("successfully completed clean-markers.")
+
+	return nil
+}
+
+// queueDanglingMarkers lists all versions of the bucket and queues the
+// delete markers that can be removed without changing the visible
+// objects. The listing is never served from the cache, a stale listing
+// could resurrect objects.
+func queueDanglingMarkers(ctx context.Context, orphanedOnly bool) error {
+	var (
+		key      string
+		markers  []miniogo.ObjectInfo
+		versions int
+	)
+	flush := func() {
+		for _, marker := range markers {
+			if versions > 0 && (orphanedOnly || marker.IsLatest) {
+				continue
+			}
+			if !admit() {
+				break
+			}
+			cleanState.queueUploadTask(formatRecord(marker.VersionID, marker.Key))
+			logDMsg(fmt.Sprintf("adding %s to clean-markers queue", marker.Key+" : "+marker.VersionID), nil)
+		}
+		markers, versions = markers[:0], 0
+	}
+	opts := miniogo.ListObjectsOptions{
+		WithVersions: true,
+		Recursive:    true,
+	}
+	// Versions of a key are listed together, decide once all are seen.
+	for object := range minioClient.ListObjects(ctx, minioBucket, opts) {
+		if object.Err != nil {
+			fmt.Println(object.Err)
+			return object.Err
+		}
+		if object.Key != key {
+			flush()
+			if limitReached() {
+				return nil
+			}
+			key = object.Key
+		}
+		if !inShard(object.Key) || !patternMatch(object.Key) {
+			continue
+		}
+		if object.IsDeleteMarker {
+			markers = append(markers, object)
+		} else {
+			versions++
+		}
+	}
+	flush()
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+var cleanState *taskPool
+
+func newCleanMarkersState(ctx context.Context) *taskPool {
+	return newTaskPool(poolTasks{
+		action: "Cleaning markers",
+		// Tasks are "versionID,key" records.
+		fields: 2,
+		key:    1,
+		run: func(ctx context.Context, fields []string) error {
+			return removeObjectVersion(ctx, fields[1], fields[0])
+		},
+		failFile:    failCleanMarkersFile,
+		successFile: successCleanMarkersFile,
+		done:        "Removed %d delete markers, %d failures",
+	})
+}
+
+// removeObjectVersion permanently removes versionID of object.
+func removeObjectVersion(ctx context.Context, object, versionID string) error {
+	if dryRun {
+		logMsg(fmt.Sprintf("%s: version %s", object, versionID))
+		return nil
+	}
+
+	opts := miniogo.RemoveObjectOptions{
+		VersionID: versionID,
+	}
+
+	removeCtx, cancel := opContext(ctx)
+	err := minioClient.RemoveObject(removeCtx, minioBucket, object, opts)
+	cancel()
+	if err != nil {
+		logDMsg("removeObject failed for "+object, err)
+		return err
+	}
+	logDMsg("Removed version "+versionID+" of "+object+" successfully", nil)
+	return nil
+}
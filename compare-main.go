@@ -0,0 +1,207 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio/pkg/console"
+)
+
+const (
+	onlyInSourceFile = "compare_only_in_source.txt"
+	onlyInDestFile   = "compare_only_in_destination.txt"
+	mismatchedFile   = "compare_mismatched.txt"
+)
+
+var compareCmd = cli.Command{
+	Name:   "compare",
+	Usage:  "reconcile the source bucket against the destination buckets",
+	Action: compareAction,
+	Flags:  append(append(append(append(allFlags, sourceFlags...), routeFlags...), prefixFlags...), normalizeFlags...),
+	CustomHelpTemplate: `NAME:
+	 {{.HelpName}} - {{.Usage}}
+ 
+ USAGE:
+	 {{.HelpName}} --data-dir DIR
+ 
+ FLAGS:
+	{{range .VisibleFlags}}{{.}}
+	{{end}}
+ 
+ EXAMPLES:
+ 1. Compare srcbucket against the four destination buckets after a migration.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+	$ export MINIO_SOURCE_ACCESS_KEY=minio
+	$ export MINIO_SOURCE_SECRET_KEY=minio123
+	$ export MINIO_DEST_BUCKET_1=dstbucket1
+	$ export MINIO_DEST_BUCKET_2=dstbucket2
+	$ export MINIO_DEST_BUCKET_3=dstbucket3
+	$ export MINIO_DEST_BUCKET_4=dstbucket4
+	$ export MINIO_SOURCE_BUCKET=srcbucket
+	$ moveobject compare --data-dir /tmp/
+ `,
+}
+
+// sourceObject is a current source object expected at the destination.
+type sourceObject struct {
+	key   string
+	size  int64
+	etag  string
+	found bool
+}
+
+// compareReport holds the three reconciliation files.
+type compareReport struct {
+	onlyInSource, onlyInDest, mismatched *os.File
+	differences                          int
+}
+
+func (r *compareReport) write(f *os.File, fields ...string) {
+	r.differences++
+	if err := writeRecord(f, fields...); err != nil {
+		logMsg(fmt.Sprintf("Error writing to %s for %s: %s", f.Name(), fields[len(fields)-1], err))
+		os.Exit(exitAborted)
+	}
+}
+
+func (r *compareReport) close() {
+	r.onlyInSource.Close()
+	r.onlyInDest.Close()
+	r.mismatched.Close()
+}
+
+func openCompareReport() (*compareReport, error) {
+	var (
+		r   compareReport
+		err error
+	)
+	if r.onlyInSource, err = createOutputFile(onlyInSourceFile); err != nil {
+		return nil, err
+	}
+	if r.onlyInDest, err = createOutputFile(onlyInDestFile); err != nil {
+		r.onlyInSource.Close()
+		return nil, err
+	}
+	if r.mismatched, err = createOutputFile(mismatchedFile); err != nil {
+		r.onlyInSource.Close()
+		r.onlyInDest.Close()
+		return nil, err
+	}
+	return &r, nil
+}
+
+// compareObject returns why dst differs from src, empty when they match.
+// ETags are only compared when both are plain MD5 sums.
+func compareObject(src *sourceObject, dst miniogo.ObjectInfo) string {
+	if src.size != dst.Size {
+		return fmt.Sprintf("size %d != %d", src.size, dst.Size)
+	}
+	srcETag, dstETag := strings.Trim(src.etag, "\""), strings.Trim(dst.ETag, "\"")
+	if !strings.Contains(srcETag, "-") && !strings.Contains(dstETag, "-") && srcETag != dstETag {
+		return fmt.Sprintf("etag %s != %s", srcETag, dstETag)
+	}
+	return ""
+}
+
+func compareAction(cliCtx *cli.Context) error {
+	checkArgsAndInit(cliCtx)
+	logMsg("Init minio client..")
+	if err := initMinioClients(cliCtx); err != nil {
+		logDMsg("Unable to  initialize MinIO client, exiting...%w", err)
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
+		console.Fatalln(err)
+	}
+	ctx := context.Background()
+
+	// Index the current source objects by their expected destination.
+	var sources []*sourceObject
+	expected := make(map[string]*sourceObject)
+	for object := range listBucket(ctx, minioSrcClient, minioSrcBucket) {
+		if object.Err != nil {
+			fmt.Println(object.Err)
+			return object.Err
+		}
+		if object.IsDeleteMarker || !object.IsLatest || !patternMatch(object.Key) {
+			continue
+		}
+		buckets, err := candidateBuckets(object.Key, object.Size)
+		if err != nil {
+			continue
+		}
+		src := &sourceObject{key: object.Key, size: object.Size, etag: object.ETag}
+		sources = append(sources, src)
+		for _, bucket := range buckets {
+			expected[path.Join(bucket, convert(object.Key))] = src
+		}
+	}
+	logMsg(fmt.Sprintf("%d objects expected at the destination", len(sources)))
+
+	report, err := openCompareReport()
+	if err != nil {
+		logDMsg("could not create compare report", err)
+		return err
+	}
+	defer report.close()
+
+	seen := make(map[string]bool)
+	for _, bucket := range destBuckets() {
+		// The same bucket may serve several prefix ranges.
+		if seen[bucket] {
+			continue
+		}
+		seen[bucket] = true
+		opts := miniogo.ListObjectsOptions{Recursive: true}
+		for object := range minioClient.ListObjects(ctx, bucket, opts) {
+			if object.Err != nil {
+				fmt.Println(object.Err)
+				return object.Err
+			}
+			src, ok := expected[path.Join(bucket, object.Key)]
+			if !ok {
+				report.write(report.onlyInDest, bucket, object.Key)
+				continue
+			}
+			src.found = true
+			if reason := compareObject(src, object); reason != "" {
+				report.write(report.mismatched, reason, bucket, object.Key, src.key)
+			}
+		}
+	}
+	for _, src := range sources {
+		if !src.found {
+			report.write(report.onlyInSource, src.key)
+		}
+	}
+
+	if report.differences > 0 {
+		console.Errorln(fmt.Sprintf("%d differences between %s and the destination, see %s, %s and %s", report.differences, minioSrcBucket, onlyInSourceFile, onlyInDestFile, mismatchedFile))
+		raiseExitCode(exitFailures)
+		return nil
+	}
+	fmt.Printf("%d objects of %s match the destination\n", len(sources), minioSrcBucket)
+	return nil
+}
@@ -0,0 +1,97 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"time"
+)
+
+// workersFile in data-dir holds the number of workers a live run lets take
+// objects, so that it can be throttled without a restart e.g.
+//
+//	echo 20 > /tmp/workers
+//
+// Removing the file, or writing 0, lets all workers run again.
+const workersFile = "workers"
+
+// workersPollInterval is how often workersFile is read.
+const workersPollInterval = 5 * time.Second
+
+// watchWorkersFile applies workersFile in dir to intake for the lifetime
+// of the process. The pool of workers is fixed when the run starts, a
+// limit above it is rejected as it could not raise the workers.
+func watchWorkersFile(dir string) {
+	name := path.Join(dir, workersFile)
+	var lastErr string
+	apply := func() {
+		n, err := readWorkersFile(name)
+		if pool := intake.poolSize(); err == nil && pool > 0 && n > pool {
+			err = fmt.Errorf("%d workers is more than the %d workers of the run", n, pool)
+		}
+		if err != nil {
+			// Keep the current limit until the file is fixed.
+			if err.Error() != lastErr {
+				logMsg(fmt.Sprintf("ignoring %s: %v", name, err))
+			}
+			lastErr = err.Error()
+			return
+		}
+		lastErr = ""
+		if !intake.setWorkers(n) {
+			return
+		}
+		if n == 0 {
+			logMsg("all workers take objects again")
+		} else {
+			logMsg(fmt.Sprintf("only %d workers take objects as set in %s", n, name))
+		}
+	}
+	apply()
+	go func() {
+		ticker := time.NewTicker(workersPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			apply()
+		}
+	}()
+}
+
+// readWorkersFile returns the number of workers in name, zero when it does
+// not exist.
+func readWorkersFile(name string) (int, error) {
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(string(bytes.TrimSpace(data)))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number of workers %q", bytes.TrimSpace(data))
+	}
+	return n, nil
+}
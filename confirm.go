@@ -0,0 +1,85 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/console"
+)
+
+// confirmFlags guard the commands that remove data.
+var confirmFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "yes, y",
+		Usage: "do not prompt for confirmation before removing data",
+	},
+	cli.BoolFlag{
+		Name:  "force",
+		Usage: "allow removing more objects than --confirm-threshold",
+	},
+	cli.IntFlag{
+		Name:  "confirm-threshold",
+		Usage: "number of objects above which --force is required",
+		Value: 100000,
+	},
+}
+
+// confirmDestructive asks before action is applied to count objects of
+// bucket, and exits unless confirmed. Dry runs are never prompted.
+func confirmDestructive(ctx *cli.Context, action string, count int, bucket string) {
+	if dryRun || count == 0 {
+		return
+	}
+	if threshold := ctx.Int("confirm-threshold"); count > threshold && !ctx.Bool("force") {
+		console.Fatalln(fmt.Errorf("refusing to %s %d objects, more than --confirm-threshold %d, pass --force to proceed", action, count, threshold))
+	}
+	if ctx.Bool("yes") {
+		return
+	}
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		console.Fatalln(fmt.Errorf("about to %s %d objects in %s, pass --yes to proceed without a terminal", action, count, bucket))
+	}
+	fmt.Printf("About to %s %d objects in %s. Continue? [y/N]: ", action, count, bucket)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return
+	}
+	console.Fatalln("aborted")
+}
+
+// queueConfirmed passes the tasks of a destructive action that list finds
+// to queue without holding them in memory. Unless --yes and --force leave
+// nothing to confirm, list first runs with count set, only to count the
+// tasks for confirmDestructive.
+func queueConfirmed(ctx *cli.Context, action, bucket string, list func(queue func(string), count bool) error, queue func(string)) error {
+	if !dryRun && !(ctx.Bool("yes") && ctx.Bool("force")) {
+		n := 0
+		if err := list(func(string) { n++ }, true); err != nil {
+			return err
+		}
+		// The count admitted the tasks against --limit already.
+		resetLimit()
+		confirmDestructive(ctx, action, n, bucket)
+	}
+	return list(queue, false)
+}
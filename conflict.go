@@ -0,0 +1,136 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+const (
+	conflictMigFile    = "migration_conflicts.txt"
+	conflictCopyFile   = "copy_conflicts.txt"
+	conflictRenameFile = "rename_conflicts.txt"
+)
+
+// overwritePolicy decides what happens when the destination key exists.
+type overwritePolicy int
+
+const (
+	overwriteAlways overwritePolicy = iota
+	overwriteNever
+	overwriteIfNewer
+	overwriteIfSizeDiffers
+)
+
+// overwrite set by --no-overwrite, --if-newer or --if-size-differs.
+var overwrite overwritePolicy
+
+func parseOverwritePolicy(ctx *cli.Context) (overwritePolicy, error) {
+	policy := overwriteAlways
+	for flag, p := range map[string]overwritePolicy{
+		"no-overwrite":    overwriteNever,
+		"if-newer":        overwriteIfNewer,
+		"if-size-differs": overwriteIfSizeDiffers,
+	} {
+		if !ctx.Bool(flag) {
+			continue
+		}
+		if policy != overwriteAlways {
+			return policy, errors.New("only one of --no-overwrite, --if-newer or --if-size-differs can be set")
+		}
+		policy = p
+	}
+	return policy, nil
+}
+
+// checkConflict returns why the copy of the object src describes must not
+// overwrite bucket/object, empty when it can.
+func checkConflict(ctx context.Context, bucket, object string, src miniogo.ObjectInfo) (string, error) {
+	if overwrite == overwriteAlways {
+		return "", nil
+	}
+	statCtx, cancel := opContext(ctx)
+	defer cancel()
+	dst, err := minioClient.StatObject(statCtx, bucket, object, miniogo.StatObjectOptions{})
+	if err != nil {
+		if miniogo.ToErrorResponse(err).Code == "NoSuchKey" {
+			return "", nil
+		}
+		return "", err
+	}
+	switch overwrite {
+	case overwriteNever:
+		return "exists", nil
+	case overwriteIfNewer:
+		if !src.LastModified.After(dst.LastModified) {
+			return "destination is not older", nil
+		}
+	case overwriteIfSizeDiffers:
+		if src.Size == dst.Size {
+			return "same size", nil
+		}
+	}
+	return "", nil
+}
+
+// conflictReport records the objects skipped by the overwrite policy as
+// "reason,object" records.
+type conflictReport struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+var conflicts *conflictReport
+
+// openConflictReport creates a timestamped report named name in the run
+// directory when an overwrite policy is set.
+func openConflictReport(name string) error {
+	conflicts = nil
+	if overwrite == overwriteAlways {
+		return nil
+	}
+	f, err := createOutputFile(name)
+	if err != nil {
+		logDMsg("could not create "+name, err)
+		return err
+	}
+	conflicts = &conflictReport{f: f}
+	return nil
+}
+
+func (c *conflictReport) record(object, reason string) {
+	logDMsg(fmt.Sprintf("skipping %s, %s", object, reason), nil)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := writeRecord(c.f, reason, object); err != nil {
+		logMsg(fmt.Sprintf("Error writing conflict of %s: %s", object, err))
+		os.Exit(exitAborted)
+	}
+}
+
+func (c *conflictReport) close() {
+	if c != nil {
+		c.f.Close()
+	}
+}
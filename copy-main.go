@@ -17,21 +17,81 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"os"
 	"path"
+	"time"
 
 	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
 	"github.com/minio/minio/pkg/console"
 )
 
+// prefixFlags relocate a whole subtree instead of stripping one level.
+var prefixFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "src-prefix",
+		Usage: "only process objects under this prefix and replace it with --dst-prefix",
+	},
+	cli.StringFlag{
+		Name:  "dst-prefix",
+		Usage: "prefix replacing --src-prefix in the target key",
+	},
+}
+
+// overwriteFlags set the policy when the destination key exists, it is
+// overwritten by default.
+var overwriteFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "no-overwrite",
+		Usage: "skip objects whose destination key exists",
+	},
+	cli.BoolFlag{
+		Name:  "if-newer",
+		Usage: "only overwrite destination objects older than the source",
+	},
+	cli.BoolFlag{
+		Name:  "if-size-differs",
+		Usage: "only overwrite destination objects whose size differs from the source",
+	},
+}
+
+var storageClassFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "storage-class",
+		Usage: "storage class of the copies e.g. REDUCED_REDUNDANCY, defaults to the storage class of the source",
+	},
+}
+
+var copyOnlyFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "metadata-only",
+		Usage: "copy objects onto themselves to rewrite headers and tags without moving data",
+	},
+	cli.StringSliceFlag{
+		Name:  "set-header",
+		Usage: "header to set with --metadata-only e.g. \"Content-Type: text/plain\", can be repeated",
+	},
+	cli.StringSliceFlag{
+		Name:  "set-tag",
+		Usage: "tag to set with --metadata-only e.g. team=storage, can be repeated",
+	},
+	cli.BoolFlag{
+		Name:  "list-source",
+		Usage: "copy objects as they are listed from the bucket instead of reading object_listing.txt",
+	},
+	cli.StringSliceFlag{
+		Name:  "list-prefix",
+		Usage: "only list objects under this prefix with --list-source, can be repeated",
+	},
+}
+
 var copyCmd = cli.Command{
 	Name:   "copy",
 	Usage:  "copy objects up one level",
 	Action: copyAction,
-	Flags:  append(allFlags, migrateFlags...),
+	Flags:  append(append(append(append(allFlags, migrateFlags...), prefixFlags...), storageClassFlags...), append(append(append(overwriteFlags, copyOnlyFlags...), encryptionFlags...), normalizeFlags...)...),
 	CustomHelpTemplate: `NAME:
 	 {{.HelpName}} - {{.Usage}}
  
@@ -63,10 +123,31 @@ var copyCmd = cli.Command{
 	$ export MINIO_SECRET_KEY=minio123
 	$ export MINIO_BUCKET=miniobucket
 	$ moveobject copy --data-dir /tmp/ --fake --log
+ 
+ 4. Copy objects in "object_listing.txt" under "2021/raw/" to "archive/2021/" in MinIO.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject copy --data-dir /tmp/ --src-prefix 2021/raw/ --dst-prefix archive/2021/
+ 
+ 5. Rewrite the Content-Type and add a tag to objects in "object_listing.txt" in place.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject copy --data-dir /tmp/ --metadata-only --set-header "Content-Type: image/jpeg" --set-tag migrated=true
+
+ 6. Copy the objects listed under "42/" and "43/" without an "object_listing.txt".
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject copy --data-dir /tmp/ --list-source --list-prefix 42/ --list-prefix 43/
  `,
 }
 
-func copyAction(cliCtx *cli.Context) error {
+func copyAction(cliCtx *cli.Context) (err error) {
 	checkArgsAndInit(cliCtx)
 	ctx := context.Background()
 	logMsg("Init minio client..")
@@ -75,22 +156,79 @@ func copyAction(cliCtx *cli.Context) error {
 		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
 		console.Fatalln(err)
 	}
+	if err = parseEncryption(cliCtx); err != nil {
+		console.Fatalln(err)
+	}
+	listSource, listPrefixes = cliCtx.Bool("list-source"), cliCtx.StringSlice("list-prefix")
+	if len(listPrefixes) > 0 && !listSource {
+		console.Fatalln("--list-prefix needs --list-source")
+	}
+	if listSource && cliCtx.Bool("resume") {
+		console.Fatalln("--resume seeks into object_listing.txt, it cannot be combined with --list-source")
+	}
 	cpState = newCopyState(ctx)
 	cpState.init(ctx)
+	start := time.Now()
+	defer func() { reportRun("copy", cpState, start, err) }()
+	if err = openConflictReport(conflictCopyFile); err != nil {
+		return err
+	}
+	defer conflicts.close()
+	stopProbe := startProbe(ctx, minioClient, minioBucket)
 	skip := cliCtx.Int("skip")
 	dryRun = cliCtx.Bool("fake")
+	metadataOnly = cliCtx.Bool("metadata-only")
+	if setHeaders, err = parsePairs(cliCtx.StringSlice("set-header"), ":"); err != nil {
+		console.Fatalln(fmt.Errorf("invalid --set-header: %v", err))
+	}
+	if setTags, err = parsePairs(cliCtx.StringSlice("set-tag"), "="); err != nil {
+		console.Fatalln(fmt.Errorf("invalid --set-tag: %v", err))
+	}
+	var succeeded map[string]struct{}
+	if cliCtx.Bool("skip-succeeded") {
+		if succeeded, err = loadSucceeded(successCopyFile); err != nil {
+			logDMsg("could not load "+successCopyFile, err)
+			return err
+		}
+	}
+	if listSource {
+		if err = queueCopyListing(ctx, skip, succeeded); err != nil {
+			return err
+		}
+		cpState.finish(ctx)
+		stopProbe()
+		logMsg("successfully completed copy.")
+		return nil
+	}
 	file, err := os.Open(path.Join(dirPath, objListFile))
 	if err != nil {
 		logDMsg(fmt.Sprintf("could not open file :%s ", objListFile), err)
 		return err
 	}
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
+	scanner := newRecordScanner(file)
+	if err := scanner.checkHeader(objListFile); err != nil {
+		return err
+	}
+	if err := startCheckpoint(cliCtx, scanner, objListFile); err != nil {
+		return err
+	}
+	for checkpoint.scan(scanner) {
 		o := scanner.Text()
 		if skip > 0 {
 			skip--
 			continue
 		}
+		if !inShard(o) {
+			continue
+		}
+		if _, ok := succeeded[o]; ok {
+			logDMsg(fmt.Sprintf("skipping %s, already succeeded", o), nil)
+			continue
+		}
+		if !admit() {
+			break
+		}
+		checkpoint.queue(o)
 		cpState.queueUploadTask(o)
 		logDMsg(fmt.Sprintf("adding %s to migration queue", o), nil)
 	}
@@ -99,7 +237,60 @@ func copyAction(cliCtx *cli.Context) error {
 		return err
 	}
 	cpState.finish(ctx)
+	stopCheckpoint()
+	stopProbe()
 	logMsg("successfully completed copy.")
 
 	return nil
 }
+
+// queueCopyListing queues the latest version of every object under the
+// prefixes of --list-prefix, --src-prefix or the whole bucket once the
+// listing completes, as the copies land under the listed prefixes too.
+func queueCopyListing(ctx context.Context, skip int, succeeded map[string]struct{}) error {
+	list := func(queue func(string)) error {
+		return listCopyTasks(ctx, skip, succeeded, queue)
+	}
+	return spoolTasks(list, func(o string) {
+		cpState.queueUploadTask(o)
+		logDMsg(fmt.Sprintf("adding %s to migration queue", o), nil)
+	})
+}
+
+// listCopyTasks passes to queue the objects listed under the copied
+// prefixes, after skipping skip of them. Objects in succeeded are skipped.
+func listCopyTasks(ctx context.Context, skip int, succeeded map[string]struct{}, queue func(string)) error {
+	prefixes := listPrefixes
+	if len(prefixes) == 0 {
+		prefixes = []string{srcPrefix}
+	}
+	for _, prefix := range prefixes {
+		logMsg("Starting prefix " + prefix)
+		opts := miniogo.ListObjectsOptions{
+			Recursive: true,
+			Prefix:    prefix,
+		}
+		for object := range listObjects(ctx, minioClient, minioBucket, opts) {
+			if object.Err != nil {
+				return object.Err
+			}
+			o := object.Key
+			if !patternMatch(o) || !inShard(o) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if _, ok := succeeded[o]; ok {
+				logDMsg(fmt.Sprintf("skipping %s, already succeeded", o), nil)
+				continue
+			}
+			if !admit() {
+				return nil
+			}
+			queue(o)
+		}
+	}
+	return nil
+}
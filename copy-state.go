@@ -4,14 +4,16 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"net/http"
 	"os"
-	"path"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 )
 
 type copyState struct {
@@ -20,7 +22,13 @@ type copyState struct {
 	successCh chan string
 	count     uint64
 	failCnt   uint64
+	bytes     uint64
 	wg        sync.WaitGroup
+
+	// writerDone is closed once the records are written.
+	writerDone chan struct{}
+
+	stopProgress func()
 }
 
 func (m *copyState) queueUploadTask(obj string) {
@@ -37,9 +45,10 @@ func newCopyState(ctx context.Context) *copyState {
 		copyConcurrent = runtime.GOMAXPROCS(0)
 	}
 	cp := &copyState{
-		objectCh:  make(chan string, copyConcurrent),
-		failedCh:  make(chan string, copyConcurrent),
-		successCh: make(chan string, copyConcurrent),
+		objectCh:   make(chan string, copyConcurrent),
+		failedCh:   make(chan string, copyConcurrent),
+		successCh:  make(chan string, copyConcurrent),
+		writerDone: make(chan struct{}),
 	}
 
 	return cp
@@ -65,13 +74,31 @@ func (m *copyState) getFailCount() uint64 {
 	return atomic.LoadUint64(&m.failCnt)
 }
 
-// addWorker creates a new worker to process tasks
-func (m *copyState) addWorker(ctx context.Context) {
+// Increase bytes processed
+func (m *copyState) addBytes(n int64) {
+	atomic.AddUint64(&m.bytes, uint64(n))
+}
+
+// Get total bytes processed
+func (m *copyState) getBytes() uint64 {
+	return atomic.LoadUint64(&m.bytes)
+}
+
+// Get number of objects waiting for a worker
+func (m *copyState) queueDepth() int {
+	return len(m.objectCh)
+}
+
+// addWorker creates worker id to process tasks
+func (m *copyState) addWorker(ctx context.Context, id int) {
 	m.wg.Add(1)
 	// Add a new worker.
 	go func() {
 		defer m.wg.Done()
 		for {
+			if !intake.wait(ctx, id) {
+				return
+			}
 			select {
 			case <-ctx.Done():
 				return
@@ -83,16 +110,24 @@ func (m *copyState) addWorker(ctx context.Context) {
 				if !patternMatch(obj) {
 					m.incFailCount()
 					logMsg(fmt.Sprintf("error matching object %s", obj))
+					countFailure("PatternMismatch")
+					noteProcessed(obj)
 					m.failedCh <- obj
 					continue
 				}
-				if err := copyObject(ctx, obj); err != nil {
+				if err := retryTransient(ctx, obj, func() error {
+					return copyObject(ctx, obj)
+				}); err != nil {
 					m.incFailCount()
 					logMsg(fmt.Sprintf("error moving object %s: %s", obj, err))
+					countFailure(failureReason(err))
+					noteError(obj, err)
+					noteProcessed(obj)
 					m.failedCh <- obj
 					continue
 				}
 				logMsg(fmt.Sprintf("Successully copied %s", obj))
+				noteProcessed(obj)
 				m.successCh <- obj
 				m.incCount()
 			}
@@ -104,8 +139,10 @@ func (m *copyState) finish(ctx context.Context) {
 	time.Sleep(100 * time.Millisecond)
 	close(m.objectCh)
 	m.wg.Wait() // wait on workers to finish
+	m.stopProgress()
 	close(m.failedCh)
 	close(m.successCh)
+	<-m.writerDone
 
 	if !dryRun {
 		logMsg(fmt.Sprintf("Moved %d objects, %d failures", m.getCount(), m.getFailCount()))
@@ -115,11 +152,13 @@ func (m *copyState) init(ctx context.Context) {
 	if m == nil {
 		return
 	}
+	m.stopProgress = startProgress("Copying", m)
 	for i := 0; i < copyConcurrent; i++ {
-		m.addWorker(ctx)
+		m.addWorker(ctx, i)
 	}
 	go func() {
-		f, err := os.OpenFile(path.Join(dirPath, failCopyFile+time.Now().Format(".01-02-2006-15-04-05")), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		defer close(m.writerDone)
+		f, err := createRecordFile(failCopyFile)
 		if err != nil {
 			logDMsg("could not create "+failCopyFile, err)
 			return
@@ -128,7 +167,7 @@ func (m *copyState) init(ctx context.Context) {
 		defer fwriter.Flush()
 		defer f.Close()
 
-		s, err := os.OpenFile(path.Join(dirPath, successCopyFile+time.Now().Format(".01-02-2006-15-04-05")), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		s, err := createRecordFile(successCopyFile)
 		if err != nil {
 			logDMsg("could not create "+successCopyFile, err)
 			return
@@ -137,26 +176,29 @@ func (m *copyState) init(ctx context.Context) {
 		defer swriter.Flush()
 		defer s.Close()
 
-		for {
+		failedCh, successCh := m.failedCh, m.successCh
+		for failedCh != nil || successCh != nil {
 			select {
 			case <-ctx.Done():
 				return
-			case obj, ok := <-m.failedCh:
+			case obj, ok := <-failedCh:
 				if !ok {
-					return
+					failedCh = nil
+					continue
 				}
-				if _, err := f.WriteString(obj + "\n"); err != nil {
+				if err := writeRecord(f, obj); err != nil {
 					logMsg(fmt.Sprintf("Error writing to move_fails.txt for "+obj, err))
-					os.Exit(1)
+					os.Exit(exitAborted)
 				}
-			case obj, ok := <-m.successCh:
+			case obj, ok := <-successCh:
 				if !ok {
-					return
+					successCh = nil
+					continue
 				}
 				logMsg(fmt.Sprintf("Writing %s", obj))
-				if _, err := s.WriteString(obj + "\n"); err != nil {
+				if err := writeRecord(s, obj); err != nil {
 					logMsg(fmt.Sprintf("Error writing to copy_success.txt for "+obj, err))
-					os.Exit(1)
+					os.Exit(exitAborted)
 				}
 
 			}
@@ -164,7 +206,49 @@ func (m *copyState) init(ctx context.Context) {
 	}()
 }
 
+// metadataOnly set by --metadata-only copies objects onto themselves with
+// setHeaders and setTags applied.
+var (
+	metadataOnly bool
+	setHeaders   map[string]string
+	setTags      map[string]string
+)
+
+// parsePairs parses "key<sep>value" entries.
+func parsePairs(entries []string, sep string) (map[string]string, error) {
+	pairs := make(map[string]string, len(entries))
+	for _, e := range entries {
+		kv := strings.SplitN(e, sep, 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("%q is not of the form key%svalue", e, sep)
+		}
+		pairs[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return pairs, nil
+}
+
 func copyObject(ctx context.Context, object string) error {
+	stat, key, err := statEncrypted(ctx, object, "")
+	if err != nil {
+		return err
+	}
+	if metadataOnly {
+		return rewriteMetadata(ctx, object, stat, key)
+	}
+	if _, err = convertKey(object); err != nil {
+		return err
+	}
+	if err = checkNormalized(ctx, minioClient, minioBucket, object, minioBucket, convert(object), stat.Size); err != nil {
+		return err
+	}
+	reason, err := checkConflict(ctx, minioBucket, convert(object), stat)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		conflicts.record(object, reason)
+		return nil
+	}
 
 	if dryRun {
 		logMsg(migrateMsg(object, convert(object)))
@@ -181,8 +265,12 @@ func copyObject(ctx context.Context, object string) error {
 		Bucket: minioBucket,
 		Object: convert(object),
 	}
+	setStorageClass(&dst, stat)
+	applyEncryption(&src, &dst, key)
 
-	_, err := minioClient.CopyObject(ctx, dst, src)
+	ctx, cancel := opContext(ctx)
+	defer cancel()
+	_, err = minioClient.CopyObject(ctx, dst, src)
 	if err != nil {
 		logDMsg("upload to minio client failed for "+object, err)
 		return err
@@ -190,3 +278,53 @@ func copyObject(ctx context.Context, object string) error {
 	logDMsg("Uploaded "+object+" successfully", nil)
 	return nil
 }
+
+// rewriteMetadata copies object onto itself, keeping its metadata and tags
+// apart from setHeaders and setTags. key reads an SSE-C object.
+func rewriteMetadata(ctx context.Context, object string, stat miniogo.ObjectInfo, key encrypt.ServerSide) error {
+	meta := preservedMetadata(stat)
+	for k, v := range setHeaders {
+		k = http.CanonicalHeaderKey(k)
+		meta[strings.TrimPrefix(k, "X-Amz-Meta-")] = v
+	}
+	if dryRun {
+		logMsg(fmt.Sprintf("%s: %v %v", object, meta, setTags))
+		return nil
+	}
+
+	src := miniogo.CopySrcOptions{
+		Bucket:    minioBucket,
+		Object:    object,
+		VersionID: stat.VersionID,
+	}
+	dst := miniogo.CopyDestOptions{
+		Bucket:          minioBucket,
+		Object:          object,
+		ReplaceMetadata: true,
+		UserMetadata:    meta,
+	}
+	applyEncryption(&src, &dst, key)
+	if len(setTags) > 0 {
+		tagCtx, cancel := opContext(ctx)
+		t, err := minioClient.GetObjectTagging(tagCtx, minioBucket, object, miniogo.GetObjectTaggingOptions{VersionID: stat.VersionID})
+		cancel()
+		if err != nil {
+			logDMsg("get tags failed for "+object, err)
+			return err
+		}
+		dst.UserTags = t.ToMap()
+		for k, v := range setTags {
+			dst.UserTags[k] = v
+		}
+		dst.ReplaceTags = true
+	}
+
+	ctx, cancel := opContext(ctx)
+	defer cancel()
+	if _, err := minioClient.CopyObject(ctx, dst, src); err != nil {
+		logDMsg("metadata rewrite failed for "+object, err)
+		return err
+	}
+	logDMsg("Rewrote metadata of "+object+" successfully", nil)
+	return nil
+}
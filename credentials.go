@@ -0,0 +1,178 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	credsStatic = "static"
+	credsSTS    = "sts"
+	credsIAM    = "iam"
+	credsFile   = "file"
+)
+
+// stsDuration set by --sts-duration is the lifetime requested for the
+// credentials of --credentials sts, they are renewed before they expire.
+var stsDuration = time.Hour
+
+// credentialFileCheck is how often a credentials file is checked for
+// changes.
+const credentialFileCheck = 10 * time.Second
+
+var (
+	refreshableMu sync.Mutex
+	// refreshable are the credentials of the clients, expired together
+	// when a request is rejected with an expired token.
+	refreshable []*credentials.Credentials
+)
+
+// newCredentials returns the credentials of an endpoint according to
+// provider: static uses accessKey and secretKey as they are, sts[:URL]
+// assumes temporary credentials with them from the STS of the endpoint or
+// URL, iam takes them from the instance, container or web identity role
+// and file:PATH reads them from an AWS credentials file whenever it
+// changes. Temporary credentials are renewed by the client before they
+// expire, tr carries the STS requests.
+func newCredentials(provider, endpoint, accessKey, secretKey, signature string, tr http.RoundTripper) (*credentials.Credentials, error) {
+	if provider == "" || provider == credsStatic {
+		return newStaticCredentials(accessKey, secretKey, signature)
+	}
+	if signature == "v2" {
+		return nil, fmt.Errorf("credentials %s are only supported with signature v4", provider)
+	}
+	var creds *credentials.Credentials
+	switch name, arg := splitProvider(provider); name {
+	case credsSTS:
+		if arg == "" {
+			arg = endpoint
+		}
+		if accessKey == "" || secretKey == "" {
+			return nil, errors.New("credentials sts need the access key and secret key to assume a role with")
+		}
+		creds = credentials.New(&credentials.STSAssumeRole{
+			Client:      &http.Client{Transport: tr},
+			STSEndpoint: arg,
+			Options: credentials.STSAssumeRoleOptions{
+				AccessKey:       accessKey,
+				SecretKey:       secretKey,
+				DurationSeconds: int(stsDuration / time.Second),
+			},
+		})
+	case credsIAM:
+		creds = credentials.NewIAM(arg)
+	case credsFile:
+		if arg == "" {
+			return nil, errors.New("credentials file need a path e.g. file:/etc/moveobject/credentials")
+		}
+		creds = credentials.New(&fileCredentials{path: arg})
+	default:
+		return nil, fmt.Errorf("unknown credentials %q, should be one of static, sts, sts:URL, iam or file:PATH", provider)
+	}
+	if _, err := creds.Get(); err != nil {
+		return nil, fmt.Errorf("unable to get %s credentials: %v", provider, err)
+	}
+	refreshableMu.Lock()
+	refreshable = append(refreshable, creds)
+	refreshableMu.Unlock()
+	return creds, nil
+}
+
+// splitProvider splits "name:arg" of --credentials.
+func splitProvider(provider string) (name, arg string) {
+	if i := strings.Index(provider, ":"); i >= 0 {
+		return provider[:i], provider[i+1:]
+	}
+	return provider, ""
+}
+
+// needsKeys returns whether the credentials of provider are made from the
+// access key and secret key.
+func needsKeys(provider string) bool {
+	name, _ := splitProvider(provider)
+	return name == "" || name == credsStatic || name == credsSTS
+}
+
+// isExpiredToken returns whether err rejects a request for its expired
+// temporary credentials.
+func isExpiredToken(err error) bool {
+	var resp miniogo.ErrorResponse
+	if !errors.As(err, &resp) {
+		return false
+	}
+	switch resp.Code {
+	case "ExpiredToken", "ExpiredTokenException", "TokenRefreshRequired", "InvalidTokenId":
+		return true
+	}
+	return false
+}
+
+// expireCredentials makes the clients renew their temporary credentials
+// with their next request, e.g. when a token was revoked early.
+func expireCredentials() {
+	refreshableMu.Lock()
+	defer refreshableMu.Unlock()
+	for _, creds := range refreshable {
+		creds.Expire()
+	}
+}
+
+// fileCredentials reads an AWS credentials file, the default profile or
+// AWS_PROFILE, again whenever its modification time changes, e.g. when a
+// mounted secret of a rotated service account is updated.
+type fileCredentials struct {
+	path      string
+	mu        sync.Mutex
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func (f *fileCredentials) Retrieve() (credentials.Value, error) {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	v, err := (&credentials.FileAWSCredentials{Filename: f.path}).Retrieve()
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	f.mu.Lock()
+	f.modTime, f.lastCheck = fi.ModTime(), time.Now()
+	f.mu.Unlock()
+	return v, nil
+}
+
+func (f *fileCredentials) IsExpired() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if time.Since(f.lastCheck) < credentialFileCheck {
+		return false
+	}
+	f.lastCheck = time.Now()
+	fi, err := os.Stat(f.path)
+	return err == nil && !fi.ModTime().Equal(f.modTime)
+}
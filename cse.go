@@ -0,0 +1,252 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+// Objects are encrypted client side with --cse-key before they leave this
+// host, the destination only ever sees ciphertext. Every object has its own
+// random data key, sealed with the --cse-key master key and stored in the
+// object metadata. The data is split into 64KiB chunks, each sealed with
+// AES-256-GCM under the data key with the chunk number as nonce and a
+// final chunk flag as additional data, so that reordered, dropped or
+// truncated chunks fail to decrypt.
+const (
+	cseAlgorithm = "AES256-GCM-64K-v1"
+	cseChunkSize = 64 * 1024
+	cseTagSize   = 16
+	cseKeyAAD    = "moveobject-cse-key-v1"
+
+	cseMetaAlgorithm = "Moveobject-Cse-Algorithm"
+	cseMetaKey       = "Moveobject-Cse-Key"
+	cseMetaKeyID     = "Moveobject-Cse-Key-Id"
+	cseMetaSize      = "Moveobject-Cse-Size"
+)
+
+// cseKey set by --cse-key is the master key objects are encrypted with,
+// nil when client side encryption is disabled.
+var cseKey []byte
+
+// parseCSEKey decodes a base64 encoded 32 byte master key.
+func parseCSEKey(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --cse-key, expected base64: %v", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid --cse-key, expected 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// cseKeyID identifies the master key in the metadata without revealing it.
+func cseKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// uploadSize returns the size of the upload of an object of size bytes,
+// larger than the object by a tag per chunk with --cse-key.
+func uploadSize(size int64) int64 {
+	if cseKey == nil {
+		return size
+	}
+	chunks := (size + cseChunkSize - 1) / cseChunkSize
+	if chunks == 0 {
+		// An empty object is a single empty final chunk.
+		chunks = 1
+	}
+	return size + chunks*cseTagSize
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptReader returns a reader of r, which holds size bytes, encrypted
+// with a new data key and metadata with the sealed key added for the
+// destination object.
+func encryptReader(r io.Reader, size int64, metadata map[string]string) (io.Reader, map[string]string, error) {
+	dataKey := make([]byte, 32)
+	nonce := make([]byte, 12)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, nil, err
+	}
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+	master, err := newGCM(cseKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	sealed := master.Seal(nonce, nonce, dataKey, []byte(cseKeyAAD))
+	meta := make(map[string]string, len(metadata)+4)
+	for k, v := range metadata {
+		meta[k] = v
+	}
+	meta[cseMetaAlgorithm] = cseAlgorithm
+	meta[cseMetaKey] = base64.StdEncoding.EncodeToString(sealed)
+	meta[cseMetaKeyID] = cseKeyID(cseKey)
+	meta[cseMetaSize] = strconv.FormatInt(size, 10)
+	return &cseReader{r: r, aead: aead, remaining: size, seal: true}, meta, nil
+}
+
+// decryptReader returns a reader of the plain data of an object encrypted
+// by encryptReader, read from r, and its size.
+func decryptReader(r io.Reader, stat miniogo.ObjectInfo) (io.Reader, int64, error) {
+	if alg := stat.Metadata.Get("X-Amz-Meta-" + cseMetaAlgorithm); alg != cseAlgorithm {
+		return nil, 0, fmt.Errorf("unknown client side encryption %q", alg)
+	}
+	if id := stat.Metadata.Get("X-Amz-Meta-" + cseMetaKeyID); id != cseKeyID(cseKey) {
+		return nil, 0, fmt.Errorf("encrypted with key %s, not the --cse-key %s", id, cseKeyID(cseKey))
+	}
+	size, err := strconv.ParseInt(stat.Metadata.Get("X-Amz-Meta-"+cseMetaSize), 10, 64)
+	if err != nil || size < 0 {
+		return nil, 0, fmt.Errorf("invalid %s metadata", cseMetaSize)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(stat.Metadata.Get("X-Amz-Meta-" + cseMetaKey))
+	if err != nil || len(sealed) < 12 {
+		return nil, 0, fmt.Errorf("invalid %s metadata", cseMetaKey)
+	}
+	master, err := newGCM(cseKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	dataKey, err := master.Open(nil, sealed[:12], sealed[12:], []byte(cseKeyAAD))
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to unseal the data key: %v", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &cseReader{r: r, aead: aead, remaining: size}, size, nil
+}
+
+// isClientEncrypted returns whether stat is of an object encrypted by
+// encryptReader.
+func isClientEncrypted(stat miniogo.ObjectInfo) bool {
+	return stat.Metadata.Get("X-Amz-Meta-"+cseMetaAlgorithm) != ""
+}
+
+var (
+	errCSETruncated    = errors.New("client side encrypted object is truncated")
+	errCSETrailingData = errors.New("client side encrypted object has data after its final chunk")
+)
+
+// cseReader seals or opens r chunk by chunk, remaining is the plain data
+// still to go.
+type cseReader struct {
+	r         io.Reader
+	aead      cipher.AEAD
+	seal      bool
+	remaining int64
+	chunk     uint64
+	done      bool
+	buf, out  []byte
+}
+
+func (c *cseReader) Read(p []byte) (int, error) {
+	if len(c.out) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+// next seals or opens the next chunk into out.
+func (c *cseReader) next() error {
+	n := int64(cseChunkSize)
+	if c.remaining < n {
+		n = c.remaining
+	}
+	final := c.remaining == n
+	in := int(n)
+	if !c.seal {
+		in += cseTagSize
+	}
+	if cap(c.buf) < in {
+		c.buf = make([]byte, in, cseChunkSize+cseTagSize)
+	}
+	c.buf = c.buf[:in]
+	if _, err := io.ReadFull(c.r, c.buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if c.seal {
+				return io.ErrUnexpectedEOF
+			}
+			return errCSETruncated
+		}
+		return err
+	}
+	var nonce [12]byte
+	binary.BigEndian.PutUint64(nonce[4:], c.chunk)
+	aad := []byte{0}
+	if final {
+		aad[0] = 1
+	}
+	if c.seal {
+		c.out = c.aead.Seal(c.buf[:0], nonce[:], c.buf, aad)
+	} else {
+		out, err := c.aead.Open(c.buf[:0], nonce[:], c.buf, aad)
+		if err != nil {
+			return fmt.Errorf("unable to decrypt chunk %d: %v", c.chunk, err)
+		}
+		if final {
+			// Nothing may follow the final chunk.
+			var extra [1]byte
+			if n, _ := io.ReadFull(c.r, extra[:]); n > 0 {
+				return errCSETrailingData
+			}
+		}
+		c.out = out
+	}
+	c.chunk++
+	c.remaining -= n
+	c.done = final
+	return nil
+}
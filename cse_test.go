@@ -0,0 +1,124 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+// sealObject encrypts data with --cse-key and returns the ciphertext and
+// the stat of the object it would be uploaded as.
+func sealObject(t *testing.T, data []byte) ([]byte, miniogo.ObjectInfo) {
+	t.Helper()
+	r, meta, err := encryptReader(bytes.NewReader(data), int64(len(data)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(sealed)) != uploadSize(int64(len(data))) {
+		t.Fatalf("sealed %d bytes into %d, expected %d", len(data), len(sealed), uploadSize(int64(len(data))))
+	}
+	stat := miniogo.ObjectInfo{Metadata: make(http.Header)}
+	for k, v := range meta {
+		stat.Metadata.Set("X-Amz-Meta-"+k, v)
+	}
+	return sealed, stat
+}
+
+// openObject decrypts sealed with --cse-key.
+func openObject(sealed []byte, stat miniogo.ObjectInfo) ([]byte, error) {
+	r, _, err := decryptReader(bytes.NewReader(sealed), stat)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+func withCSEKey(t *testing.T) {
+	cseKey = make([]byte, 32)
+	if _, err := rand.Read(cseKey); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cseKey = nil })
+}
+
+// TestCSERoundTrip decrypts what was encrypted, across chunk boundaries.
+func TestCSERoundTrip(t *testing.T) {
+	withCSEKey(t)
+	for _, size := range []int{0, 1, cseChunkSize - 1, cseChunkSize, cseChunkSize + 1, 3*cseChunkSize + 17} {
+		data := make([]byte, size)
+		rand.Read(data)
+		sealed, stat := sealObject(t, data)
+		got, err := openObject(sealed, stat)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%d bytes do not round trip", size)
+		}
+	}
+}
+
+// TestCSERejectsTampering fails objects whose ciphertext or metadata was
+// cut, reordered or altered.
+func TestCSERejectsTampering(t *testing.T) {
+	withCSEKey(t)
+	data := make([]byte, 3*cseChunkSize+17)
+	rand.Read(data)
+	sealed, stat := sealObject(t, data)
+	sealedChunk := cseChunkSize + cseTagSize
+
+	flipped := append([]byte(nil), sealed...)
+	flipped[sealedChunk+5] ^= 1
+	reordered := append([]byte(nil), sealed...)
+	copy(reordered, sealed[sealedChunk:2*sealedChunk])
+	copy(reordered[sealedChunk:], sealed[:sealedChunk])
+
+	for name, c := range map[string][]byte{
+		"truncated to whole chunks":  sealed[:2*sealedChunk],
+		"truncated within a chunk":   sealed[:len(sealed)-1],
+		"empty":                      nil,
+		"chunks reordered":           reordered,
+		"bit flipped":                flipped,
+		"data after the final chunk": append(append([]byte(nil), sealed...), 0),
+	} {
+		if _, err := openObject(c, stat); err == nil {
+			t.Errorf("%s: decrypted", name)
+		}
+	}
+
+	// The size in the metadata cannot turn a chunk into the final one.
+	short := miniogo.ObjectInfo{Metadata: stat.Metadata.Clone()}
+	short.Metadata.Set("X-Amz-Meta-"+cseMetaSize, "131072")
+	if _, err := openObject(sealed[:2*sealedChunk], short); err == nil {
+		t.Error("truncated object with altered size decrypted")
+	}
+
+	if _, err := openObject(sealed[:2*sealedChunk], stat); !errors.Is(err, errCSETruncated) {
+		t.Errorf("truncated object fails with %v, expected %v", err, errCSETruncated)
+	}
+}
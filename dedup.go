@@ -0,0 +1,134 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+const duplicatesFile = "duplicates.txt"
+
+const (
+	dedupReport = "report"
+	dedupSkip   = "skip"
+)
+
+// dedupMode set by --dedup reports or skips source objects whose content
+// is already at the destination under another key.
+var dedupMode string
+
+// contentIndex maps the ETag and size of every object at the destination
+// to its "bucket/key", and records the duplicates found as "bucket/key,
+// object" records.
+type contentIndex struct {
+	mu   sync.Mutex
+	keys map[string]string
+	f    *os.File
+}
+
+var dedup *contentIndex
+
+// contentKey returns the index key of an object with etag and size.
+func contentKey(etag string, size int64) string {
+	return strings.Trim(etag, "\"") + "/" + strconv.FormatInt(size, 10)
+}
+
+// loadContentIndex lists the destination buckets into dedup when --dedup
+// is set.
+func loadContentIndex(ctx context.Context) error {
+	dedup = nil
+	if dedupMode == "" {
+		return nil
+	}
+	keys := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, bucket := range destBuckets() {
+		if seen[bucket] {
+			continue
+		}
+		seen[bucket] = true
+		for object := range listBucket(ctx, minioClient, bucket) {
+			if object.Err != nil {
+				return fmt.Errorf("unable to list destination bucket %s: %w", bucket, object.Err)
+			}
+			if object.IsLatest && !object.IsDeleteMarker && object.Size > 0 {
+				keys[contentKey(object.ETag, object.Size)] = bucket + "/" + object.Key
+			}
+		}
+	}
+	logMsg(fmt.Sprintf("%d distinct contents at the destination", len(keys)))
+	f, err := createOutputFile(duplicatesFile)
+	if err != nil {
+		logDMsg("could not create "+duplicatesFile, err)
+		return err
+	}
+	dedup = &contentIndex{keys: keys, f: f}
+	return nil
+}
+
+// lookup returns "bucket/key" of an object at the destination with the
+// content of the object stat describes, other than its own copy in
+// bucket/key. The ETags of encrypted objects are not their MD5, they are
+// never looked up.
+func (c *contentIndex) lookup(stat miniogo.ObjectInfo, bucket, key string) (string, bool) {
+	if c == nil || stat.Size == 0 || isEncrypted(stat) || isSSEC(stat) {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	match, ok := c.keys[contentKey(stat.ETag, stat.Size)]
+	if !ok || match == bucket+"/"+key {
+		return "", false
+	}
+	return match, true
+}
+
+// add indexes the copy uploaded to bucket/key, so that later duplicates in
+// the source are found too.
+func (c *contentIndex) add(bucket, key string, info miniogo.UploadInfo, size int64) {
+	if c == nil || size == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := contentKey(info.ETag, size)
+	if _, ok := c.keys[k]; !ok {
+		c.keys[k] = bucket + "/" + key
+	}
+}
+
+func (c *contentIndex) record(object, match string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := writeRecord(c.f, match, object); err != nil {
+		logMsg(fmt.Sprintf("Error writing to %s for %s: %s", duplicatesFile, object, err))
+		os.Exit(exitAborted)
+	}
+}
+
+func (c *contentIndex) close() {
+	if c != nil {
+		c.f.Close()
+	}
+}
@@ -17,21 +17,46 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"os"
 	"path"
+	"time"
 
 	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
 	"github.com/minio/minio/pkg/console"
 )
 
+var deleteFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "require-replicated",
+		Usage: "refuse to delete objects whose replication status is PENDING or FAILED",
+	},
+	cli.StringFlag{
+		Name:  "trash-bucket",
+		Usage: "bucket or bucket/prefix to server side copy every object to before deleting it",
+	},
+	cli.DurationFlag{
+		Name:  "trash-ttl",
+		Usage: "how long copies in --trash-bucket are kept, recorded in their moveobject-trash-expires tag",
+		Value: 7 * 24 * time.Hour,
+	},
+	cli.StringFlag{
+		Name:  "prefix",
+		Usage: "delete the objects listed under this prefix instead of the objects in object_listing.txt",
+	},
+	cli.DurationFlag{
+		Name:  "older-than",
+		Usage: "only delete listed objects last modified longer ago than this e.g. 720h, lists the whole bucket without --prefix",
+	},
+}
+
 var delCmd = cli.Command{
 	Name:   "delete",
 	Usage:  "delete objects specified in the list",
 	Action: deleteAction,
-	Flags:  append(allFlags, migrateFlags...),
+	Flags:  append(append(append(allFlags, migrateFlags...), deleteFlags...), confirmFlags...),
 	CustomHelpTemplate: `NAME:
 	 {{.HelpName}} - {{.Usage}}
  
@@ -63,10 +88,39 @@ var delCmd = cli.Command{
 	$ export MINIO_SECRET_KEY=minio123
 	$ export MINIO_BUCKET=miniobucket
 	$ moveobject delete --data-dir /tmp/ --fake --log
+ 
+ 4. Delete objects in "object_listing.txt" in MinIO only once their replication has completed.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject delete --data-dir /tmp/ --require-replicated
+
+ 5. Delete objects in "object_listing.txt" in MinIO keeping a copy of each under trash/ in bucket recovery for 30 days.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject delete --data-dir /tmp/ --trash-bucket recovery/trash/ --trash-ttl 720h
+
+ 6. Delete the objects under "logs/" last modified more than 90 days ago, without an "object_listing.txt".
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject delete --data-dir /tmp/ --prefix logs/ --older-than 2160h
+
+ 7. Delete exactly the versions listed by "list", using its "version_listing.txt" as "object_listing.txt".
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ cp /tmp/version_listing.txt /tmp/object_listing.txt
+	$ moveobject delete --data-dir /tmp/
  `,
 }
 
-func deleteAction(cliCtx *cli.Context) error {
+func deleteAction(cliCtx *cli.Context) (err error) {
 	checkArgsAndInit(cliCtx)
 	ctx := context.Background()
 	logMsg("Init minio client..")
@@ -77,29 +131,141 @@ func deleteAction(cliCtx *cli.Context) error {
 	}
 	delState = newDeleteState(ctx)
 	delState.init(ctx)
+	start := time.Now()
+	defer func() { reportRun("delete", delState, start, err) }()
 	skip := cliCtx.Int("skip")
 	dryRun = cliCtx.Bool("fake")
+	requireReplicated = cliCtx.Bool("require-replicated")
+	deleteOlderThan = cliCtx.Duration("older-than")
+	if (cliCtx.IsSet("prefix") || deleteOlderThan > 0) && cliCtx.Bool("resume") {
+		console.Fatalln("--resume seeks into object_listing.txt, it cannot be combined with --prefix or --older-than")
+	}
+	if err = parseTrash(cliCtx.String("trash-bucket"), cliCtx.Duration("trash-ttl")); err != nil {
+		console.Fatalln(err)
+	}
+	var succeeded map[string]struct{}
+	if cliCtx.Bool("skip-succeeded") {
+		if succeeded, err = loadSucceeded(successDeleteFile); err != nil {
+			logDMsg("could not load "+successDeleteFile, err)
+			return err
+		}
+	}
+	list := func(queue func(string), count bool) error {
+		if cliCtx.IsSet("prefix") || deleteOlderThan > 0 {
+			return listDeletions(ctx, cliCtx.String("prefix"), skip, succeeded, queue)
+		}
+		return readDeletions(cliCtx, skip, succeeded, queue, count)
+	}
+	if err = queueConfirmed(cliCtx, "delete", minioBucket, list, func(o string) {
+		delState.queueUploadTask(o)
+		logDMsg(fmt.Sprintf("adding %s to migration queue", o), nil)
+	}); err != nil {
+		return err
+	}
+	delState.finish(ctx)
+	stopCheckpoint()
+	logMsg("successfully completed deletion.")
+
+	return nil
+}
+
+// readDeletions passes to queue the objects of object_listing.txt to
+// delete after skipping skip of them. Objects in succeeded are skipped.
+// Records of a version listing written by list delete exactly the version
+// listed. Unless count is set the records read are tracked by the
+// checkpoint.
+func readDeletions(cliCtx *cli.Context, skip int, succeeded map[string]struct{}, queue func(string), count bool) error {
 	file, err := os.Open(path.Join(dirPath, objListFile))
 	if err != nil {
 		logDMsg(fmt.Sprintf("could not open file :%s ", objListFile), err)
 		return err
 	}
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
+	defer file.Close()
+	scanner := newRecordScanner(file)
+	if err := scanner.checkHeader(objListFile); err != nil {
+		return err
+	}
+	scan := scanner.Scan
+	switch {
+	case !count:
+		if err := startCheckpoint(cliCtx, scanner, objListFile); err != nil {
+			return err
+		}
+		scan = func() bool { return checkpoint.scan(scanner) }
+	case cliCtx.Bool("resume"):
+		if _, err := resumeScanner(cliCtx, scanner, objListFile); err != nil {
+			return err
+		}
+	}
+	for scan() {
 		o := scanner.Text()
 		if skip > 0 {
 			skip--
 			continue
 		}
-		delState.queueUploadTask(o)
-		logDMsg(fmt.Sprintf("adding %s to migration queue", o), nil)
+		if !inShard(o) {
+			continue
+		}
+		if _, ok := succeeded[o]; ok {
+			logDMsg(fmt.Sprintf("skipping %s, already succeeded", o), nil)
+			continue
+		}
+		if !admit() {
+			break
+		}
+		if !count {
+			checkpoint.queue(o)
+		}
+		queue(deleteTask(listingVersion(scanner.Fields()), o))
 	}
 	if err := scanner.Err(); err != nil {
 		logDMsg(fmt.Sprintf("error processing file :%s ", objListFile), err)
 		return err
 	}
-	delState.finish(ctx)
-	logMsg("successfully completed deletion.")
+	return nil
+}
 
+// listDeletions passes to queue the latest objects listed under prefix
+// that are older than --older-than to delete, after skipping skip of them.
+// Objects in succeeded are skipped.
+func listDeletions(ctx context.Context, prefix string, skip int, succeeded map[string]struct{}, queue func(string)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	opts := miniogo.ListObjectsOptions{
+		Recursive: true,
+		Prefix:    prefix,
+	}
+	for object := range minioClient.ListObjects(ctx, minioBucket, opts) {
+		if object.Err != nil {
+			fmt.Println(object.Err)
+			return object.Err
+		}
+		o := object.Key
+		if !tooOld(object.LastModified) || !patternMatch(o) || !inShard(o) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if _, ok := succeeded[o]; ok {
+			logDMsg(fmt.Sprintf("skipping %s, already succeeded", o), nil)
+			continue
+		}
+		if !admit() {
+			break
+		}
+		queue(deleteTask("", o))
+	}
 	return nil
 }
+
+// listingVersion returns the version ID of a "versionID,key" or extended
+// listing record, empty for records of a key only.
+func listingVersion(fields []string) string {
+	switch len(fields) {
+	case 2, extendedFields:
+		return fields[0]
+	}
+	return ""
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -20,7 +19,13 @@ type deleteState struct {
 	successCh chan string
 	count     uint64
 	failCnt   uint64
+	bytes     uint64
 	wg        sync.WaitGroup
+
+	// writerDone is closed once the records are written.
+	writerDone chan struct{}
+
+	stopProgress func()
 }
 
 func (m *deleteState) queueUploadTask(obj string) {
@@ -37,9 +42,10 @@ func newDeleteState(ctx context.Context) *deleteState {
 		deleteConcurrent = runtime.GOMAXPROCS(0)
 	}
 	ms := &deleteState{
-		objectCh:  make(chan string, deleteConcurrent),
-		failedCh:  make(chan string, deleteConcurrent),
-		successCh: make(chan string, deleteConcurrent),
+		objectCh:   make(chan string, deleteConcurrent),
+		failedCh:   make(chan string, deleteConcurrent),
+		successCh:  make(chan string, deleteConcurrent),
+		writerDone: make(chan struct{}),
 	}
 
 	return ms
@@ -65,34 +71,69 @@ func (m *deleteState) getFailCount() uint64 {
 	return atomic.LoadUint64(&m.failCnt)
 }
 
-// addWorker creates a new worker to process tasks
-func (m *deleteState) addWorker(ctx context.Context) {
+// Increase bytes processed
+func (m *deleteState) addBytes(n int64) {
+	atomic.AddUint64(&m.bytes, uint64(n))
+}
+
+// Get total bytes processed
+func (m *deleteState) getBytes() uint64 {
+	return atomic.LoadUint64(&m.bytes)
+}
+
+// Get number of objects waiting for a worker
+func (m *deleteState) queueDepth() int {
+	return len(m.objectCh)
+}
+
+// addWorker creates worker id to process tasks
+func (m *deleteState) addWorker(ctx context.Context, id int) {
 	m.wg.Add(1)
 	// Add a new worker.
 	go func() {
 		defer m.wg.Done()
 		for {
+			if !intake.wait(ctx, id) {
+				return
+			}
 			select {
 			case <-ctx.Done():
 				return
-			case obj, ok := <-m.objectCh:
+			case task, ok := <-m.objectCh:
 				if !ok {
 					return
 				}
+				versionID, obj, err := parseDeleteTask(task)
+				if err != nil {
+					m.incFailCount()
+					logMsg(fmt.Sprintf("error parsing delete task %s", task))
+					countFailure("InvalidTask")
+					noteProcessed(deleteTaskKey(task))
+					m.failedCh <- task
+					continue
+				}
 				logDMsg(fmt.Sprintf("Moving...%s", obj), nil)
 				if !patternMatch(obj) {
 					m.incFailCount()
 					logMsg(fmt.Sprintf("error matching object %s", obj))
-					m.failedCh <- obj
+					countFailure("PatternMismatch")
+					noteProcessed(obj)
+					m.failedCh <- task
 					continue
 				}
-				if err := deleteObject(ctx, obj); err != nil {
+				if err := retryTransient(ctx, obj, func() error {
+					return deleteObject(ctx, obj, versionID)
+				}); err != nil {
 					m.incFailCount()
 					logMsg(fmt.Sprintf("error moving object %s: %s", obj, err))
-					m.failedCh <- obj
+					countFailure(failureReason(err))
+					noteError(obj, err)
+					noteProcessed(obj)
+					m.failedCh <- task
 					continue
 				}
-				m.successCh <- obj
+				noteProcessed(obj)
+				m.successCh <- task
 				m.incCount()
 			}
 		}
@@ -103,8 +144,10 @@ func (m *deleteState) finish(ctx context.Context) {
 	time.Sleep(100 * time.Millisecond)
 	close(m.objectCh)
 	m.wg.Wait() // wait on workers to finish
+	m.stopProgress()
 	close(m.failedCh)
 	close(m.successCh)
+	<-m.writerDone
 
 	if !dryRun {
 		logMsg(fmt.Sprintf("Moved %d objects, %d failures", m.getCount(), m.getFailCount()))
@@ -114,11 +157,13 @@ func (m *deleteState) init(ctx context.Context) {
 	if m == nil {
 		return
 	}
+	m.stopProgress = startProgress("Deleting", m)
 	for i := 0; i < deleteConcurrent; i++ {
-		m.addWorker(ctx)
+		m.addWorker(ctx, i)
 	}
 	go func() {
-		f, err := os.OpenFile(path.Join(dirPath, failDeleteFile+time.Now().Format(".01-02-2006-15-04-05")), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		defer close(m.writerDone)
+		f, err := createRecordFile(failDeleteFile)
 		if err != nil {
 			logDMsg("could not create "+failDeleteFile, err)
 			return
@@ -127,7 +172,7 @@ func (m *deleteState) init(ctx context.Context) {
 		defer fwriter.Flush()
 		defer f.Close()
 
-		s, err := os.OpenFile(path.Join(dirPath, successDeleteFile+time.Now().Format(".01-02-2006-15-04-05")), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		s, err := createRecordFile(successDeleteFile)
 		if err != nil {
 			logDMsg("could not create "+successDeleteFile, err)
 			return
@@ -136,25 +181,28 @@ func (m *deleteState) init(ctx context.Context) {
 		defer swriter.Flush()
 		defer s.Close()
 
-		for {
+		failedCh, successCh := m.failedCh, m.successCh
+		for failedCh != nil || successCh != nil {
 			select {
 			case <-ctx.Done():
 				return
-			case obj, ok := <-m.failedCh:
+			case obj, ok := <-failedCh:
 				if !ok {
-					return
+					failedCh = nil
+					continue
 				}
-				if _, err := f.WriteString(obj + "\n"); err != nil {
+				if err := writeRecord(f, taskFields(obj)...); err != nil {
 					logMsg(fmt.Sprintf("Error writing to move_fails.txt for "+obj, err))
-					os.Exit(1)
+					os.Exit(exitAborted)
 				}
-			case obj, ok := <-m.successCh:
+			case obj, ok := <-successCh:
 				if !ok {
-					return
+					successCh = nil
+					continue
 				}
-				if _, err := s.WriteString(obj + "\n"); err != nil {
+				if err := writeRecord(s, taskFields(obj)...); err != nil {
 					logMsg(fmt.Sprintf("Error writing to copy_successs.txt for "+obj, err))
-					os.Exit(1)
+					os.Exit(exitAborted)
 				}
 
 			}
@@ -162,22 +210,100 @@ func (m *deleteState) init(ctx context.Context) {
 	}()
 }
 
-func deleteObject(ctx context.Context, object string) error {
-	stat, err := minioClient.StatObject(ctx, minioBucket, object, miniogo.StatObjectOptions{})
+// Delete tasks are records of a key, deleting its latest version, or of
+// "versionID,key" as written by list, deleting exactly that version.
+func deleteTask(versionID, object string) string {
+	if versionID == "" {
+		return formatRecord(object)
+	}
+	return formatRecord(versionID, object)
+}
+
+// parseDeleteTask returns the version and key of a delete task, versionID
+// is empty for the latest version.
+func parseDeleteTask(task string) (versionID, object string, err error) {
+	fields, err := parseRecord(task)
+	if err != nil {
+		return "", "", err
+	}
+	switch len(fields) {
+	case 1:
+		return "", fields[0], nil
+	case 2:
+		return fields[0], fields[1], nil
+	}
+	return "", "", fmt.Errorf("invalid delete task %s", task)
+}
+
+// deleteTaskKey returns the listing key a delete task was queued under,
+// its last field, when the task is not a valid delete task.
+func deleteTaskKey(task string) string {
+	if fields, err := parseRecord(task); err == nil && len(fields) > 0 {
+		return fields[len(fields)-1]
+	}
+	return task
+}
+
+// taskFields returns the fields of a delete task for the success and fail
+// files, the task itself when it cannot be parsed.
+func taskFields(task string) []string {
+	fields, err := parseRecord(task)
+	if err != nil {
+		return []string{task}
+	}
+	return fields
+}
+
+// requireReplicated set by --require-replicated keeps objects whose
+// replicas have not completed.
+var requireReplicated bool
+
+// deleteOlderThan set by --older-than keeps objects modified more
+// recently.
+var deleteOlderThan time.Duration
+
+// tooOld reports whether an object last modified at modTime is old enough
+// to be deleted.
+func tooOld(modTime time.Time) bool {
+	return time.Since(modTime) > deleteOlderThan
+}
+
+// deleteObject deletes versionID of object, the latest version when empty.
+func deleteObject(ctx context.Context, object, versionID string) error {
+	statCtx, cancel := opContext(ctx)
+	stat, err := minioClient.StatObject(statCtx, minioBucket, object, miniogo.StatObjectOptions{VersionID: versionID})
+	cancel()
 	if err != nil {
 		return err
 	}
+	if requireReplicated {
+		switch status := stat.ReplicationStatus; status {
+		case "PENDING", "FAILED":
+			logMsg(fmt.Sprintf("not deleting %s, replication status is %s", object, status))
+			return fmt.Errorf("replication status of %s is %s", object, status)
+		}
+	}
+	if deleteOlderThan > 0 && !tooOld(stat.LastModified) {
+		// Rewritten since it was listed.
+		logMsg(fmt.Sprintf("not deleting %s, modified at %s", object, stat.LastModified.Format(time.RFC3339)))
+		return fmt.Errorf("%s was modified less than %s ago", object, deleteOlderThan)
+	}
 
 	if dryRun {
 		logMsg(migrateMsg(object, object))
 		return nil
 	}
+	if err = moveToTrash(ctx, object, stat); err != nil {
+		return err
+	}
 
 	opts := miniogo.RemoveObjectOptions{
 		VersionID: stat.VersionID,
 	}
 
-	err = minioClient.RemoveObject(ctx, minioBucket, object, opts)
+	removeCtx, cancel := opContext(ctx)
+	err = minioClient.RemoveObject(removeCtx, minioBucket, object, opts)
+	cancel()
 	if err != nil {
 		logDMsg("removeObject failed for "+object, err)
 		return err
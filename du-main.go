@@ -0,0 +1,154 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/console"
+)
+
+var duFlags = []cli.Flag{
+	cli.IntFlag{
+		Name:  "depth",
+		Usage: "number of prefix levels to aggregate at, 1 for the first-level prefixes",
+		Value: 1,
+	},
+	cli.BoolFlag{
+		Name:  "all-versions",
+		Usage: "also count the non-current versions of objects",
+	},
+}
+
+var duCmd = cli.Command{
+	Name:   "du",
+	Usage:  "summarize object count and size per prefix",
+	Action: duAction,
+	Flags:  append(allFlags, duFlags...),
+	CustomHelpTemplate: `NAME:
+	 {{.HelpName}} - {{.Usage}}
+ 
+ USAGE:
+	 {{.HelpName}} [--depth, --all-versions]
+ 
+ FLAGS:
+	{{range .VisibleFlags}}{{.}}
+	{{end}}
+ 
+ EXAMPLES:
+ 1. Show object count and size of every first-level prefix in the bucket.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject du --data-dir /tmp/
+
+ 2. Show object count and size of every second-level prefix including non-current versions.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject du --data-dir /tmp/ --depth 2 --all-versions --use-cached-listing
+ `,
+}
+
+// prefixUsage is the object count and size under a prefix.
+type prefixUsage struct {
+	objects, bytes uint64
+}
+
+// prefixAt returns the prefix of key at depth levels, shorter when key has
+// fewer levels, objects at the top level of the bucket are under "/".
+func prefixAt(key string, depth int) string {
+	parts := strings.SplitAfterN(key, "/", depth+1)
+	if len(parts) <= depth {
+		// The last part is the object name.
+		parts = parts[:len(parts)-1]
+	} else {
+		parts = parts[:depth]
+	}
+	if len(parts) == 0 {
+		return "/"
+	}
+	return strings.Join(parts, "")
+}
+
+func duAction(cliCtx *cli.Context) error {
+	checkArgsAndInit(cliCtx)
+	logMsg("Init minio client..")
+	if err := initMinioClient(cliCtx); err != nil {
+		logDMsg("Unable to  initialize MinIO client, exiting...%w", err)
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
+		console.Fatalln(err)
+	}
+	depth := cliCtx.Int("depth")
+	if depth <= 0 {
+		console.Fatalln(fmt.Errorf("--depth should be greater than 0"))
+	}
+	allVersions := cliCtx.Bool("all-versions")
+
+	usage := make(map[string]*prefixUsage)
+	var total prefixUsage
+	for object := range listBucket(context.Background(), minioClient, minioBucket) {
+		if object.Err != nil {
+			fmt.Println(object.Err)
+			return object.Err
+		}
+		if object.IsDeleteMarker || (!object.IsLatest && !allVersions) {
+			continue
+		}
+		prefix := prefixAt(object.Key, depth)
+		u, ok := usage[prefix]
+		if !ok {
+			u = &prefixUsage{}
+			usage[prefix] = u
+		}
+		u.objects++
+		u.bytes += uint64(object.Size)
+		total.objects++
+		total.bytes += uint64(object.Size)
+	}
+
+	prefixes := make([]string, 0, len(usage))
+	for prefix := range usage {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Objects\tSize\tShare\t")
+	for _, prefix := range prefixes {
+		u := usage[prefix]
+		fmt.Fprintf(w, "%d\t%s\t%.1f%%\t  %s\n", u.objects, humanize.IBytes(u.bytes), share(u.bytes, total.bytes), prefix)
+	}
+	fmt.Fprintf(w, "%d\t%s\t%.1f%%\t  %s\n", total.objects, humanize.IBytes(total.bytes), 100.0, "Total")
+	return w.Flush()
+}
+
+// share returns n as a percentage of total.
+func share(n, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
@@ -0,0 +1,227 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/minio/minio/pkg/console"
+)
+
+// maxMailAttachments bounds the size of the fail files attached to the
+// report email, bigger ones are referred to by path instead.
+const maxMailAttachments = 10 << 20
+
+// The run summary is emailed through smtpServer from mailFrom to mailTo
+// when they are set by --smtp-server, --mail-from and --mail-to.
+var (
+	smtpServer   string
+	smtpUser     string
+	smtpPassword string
+	mailFrom     string
+	mailTo       []string
+)
+
+// parseMailTo splits the comma separated --mail-to addresses.
+func parseMailTo(value string) []string {
+	var to []string
+	for _, addr := range strings.Split(value, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return to
+}
+
+// checkMailFlags returns an error when the email report is half
+// configured.
+func checkMailFlags() error {
+	if smtpServer == "" && mailFrom == "" && len(mailTo) == 0 {
+		return nil
+	}
+	if smtpServer == "" || mailFrom == "" || len(mailTo) == 0 {
+		return fmt.Errorf("--smtp-server, --mail-from and --mail-to need to be set together")
+	}
+	if _, _, err := net.SplitHostPort(smtpServer); err != nil {
+		return fmt.Errorf("invalid --smtp-server %q, should be host:port", smtpServer)
+	}
+	return nil
+}
+
+// mailRun emails the run summary s with the fail files of the run
+// attached, or listed by path when they are too big to attach.
+func mailRun(s runSummary) {
+	if smtpServer == "" {
+		return
+	}
+	msg, err := reportMail(s)
+	if err != nil {
+		console.Errorln(fmt.Sprintf("unable to compose report email: %v", err))
+		return
+	}
+	var auth smtp.Auth
+	if smtpUser != "" {
+		host, _, _ := net.SplitHostPort(smtpServer)
+		auth = smtp.PlainAuth("", smtpUser, smtpPassword, host)
+	}
+	if err = smtp.SendMail(smtpServer, auth, mailFrom, mailTo, msg); err != nil {
+		console.Errorln(fmt.Sprintf("unable to email run summary through %s: %v", smtpServer, err))
+		return
+	}
+	logDMsg("emailed run summary to "+strings.Join(mailTo, ", "), nil)
+}
+
+// reportMail returns the report email of s as a MIME message.
+func reportMail(s runSummary) ([]byte, error) {
+	var attach, refer []string
+	var size int64
+	for _, name := range s.Files {
+		if outputKind(name) != "fail" {
+			continue
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		if size+fi.Size() > maxMailAttachments {
+			refer = append(refer, name)
+			continue
+		}
+		size += fi.Size()
+		attach = append(attach, name)
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "Run:       %s\n", s.RunID)
+	fmt.Fprintf(&body, "Command:   %s\n", s.Command)
+	fmt.Fprintf(&body, "Status:    %s\n", s.Status)
+	fmt.Fprintf(&body, "Start:     %s\n", s.Start.Format(time.RFC3339))
+	fmt.Fprintf(&body, "End:       %s\n", s.End.Format(time.RFC3339))
+	fmt.Fprintf(&body, "Duration:  %s\n", s.Duration)
+	fmt.Fprintf(&body, "Objects:   %d\n", s.Objects)
+	fmt.Fprintf(&body, "Failures:  %d\n", s.Failures)
+	fmt.Fprintf(&body, "Bytes:     %s\n", humanize.IBytes(s.Bytes))
+	if s.Error != "" {
+		fmt.Fprintf(&body, "Error:     %s\n", s.Error)
+	}
+	reasons := make([]string, 0, len(s.FailureReasons))
+	for reason := range s.FailureReasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(&body, "  %s: %d\n", reason, s.FailureReasons[reason])
+	}
+	if len(s.Flags) > 0 {
+		names := make([]string, 0, len(s.Flags))
+		for name := range s.Flags {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		body.WriteString("\nFlags:\n")
+		for _, name := range names {
+			fmt.Fprintf(&body, "  --%s=%s\n", name, s.Flags[name])
+		}
+	}
+	if len(s.Files) > 0 {
+		body.WriteString("\nFiles:\n")
+		for _, name := range s.Files {
+			fmt.Fprintf(&body, "  %s\n", name)
+		}
+	}
+	if len(refer) > 0 {
+		body.WriteString("\nFail files too big to attach:\n")
+		for _, name := range refer {
+			fmt.Fprintf(&body, "  %s\n", name)
+		}
+	}
+
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "From: %s\r\n", mailFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(mailTo, ", "))
+	fmt.Fprintf(&msg, "Subject: moveobject %s %s, %d objects, %d failures\r\n", s.Command, s.Status, s.Objects, s.Failures)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err = qp.Write(body.Bytes()); err != nil {
+		return nil, err
+	}
+	if err = qp.Close(); err != nil {
+		return nil, err
+	}
+
+	for _, name := range attach {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"text/csv; charset=utf-8"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", filepath.Base(name))},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err = writeBase64Lines(part, data); err != nil {
+			return nil, err
+		}
+	}
+	if err = mw.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+// writeBase64Lines writes data base64 encoded in lines of 76 characters
+// as MIME requires.
+func writeBase64Lines(w io.Writer, data []byte) error {
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		if _, err := w.Write([]byte(enc[:76] + "\r\n")); err != nil {
+			return err
+		}
+		enc = enc[76:]
+	}
+	_, err := w.Write([]byte(enc + "\r\n"))
+	return err
+}
@@ -0,0 +1,102 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// dstAddrs set by --dst-endpoints are the addresses destination
+// connections are dialed to instead of the host of --endpoint.
+var dstAddrs []string
+
+// parseEndpoints parses --dst-endpoints, a comma separated list of
+// host[:port] or URLs of nodes or load balancers serving the same cluster
+// as target. The port of target is used where none is given.
+func parseEndpoints(value string, target *url.URL) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	port := endpointPort(target)
+	var addrs []string
+	for _, endpoint := range strings.Split(value, ",") {
+		endpoint = strings.TrimSpace(endpoint)
+		if endpoint == "" {
+			continue
+		}
+		if strings.Contains(endpoint, "://") {
+			u, err := url.Parse(endpoint)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse --dst-endpoints %s: %v", endpoint, err)
+			}
+			if u.Scheme != target.Scheme {
+				return nil, fmt.Errorf("--dst-endpoints %s should use %s like --endpoint", endpoint, target.Scheme)
+			}
+			endpoint = u.Host
+		}
+		if _, _, err := net.SplitHostPort(endpoint); err != nil {
+			endpoint = net.JoinHostPort(endpoint, port)
+		}
+		addrs = append(addrs, endpoint)
+	}
+	return addrs, nil
+}
+
+// endpointPort returns the port of target, the default one of its scheme
+// when none is given.
+func endpointPort(target *url.URL) string {
+	if port := target.Port(); port != "" {
+		return port
+	}
+	if target.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// spreadConnections makes tr dial the addresses in addrs round-robin
+// instead of the host of the endpoint target. Requests keep the endpoint
+// as Host header and TLS server name, so signatures and certificates are
+// those of the endpoint while the connections of the workers are spread
+// across the nodes. Dials to other addresses, e.g. of STS or a proxy
+// sharing tr, are left as they are.
+func spreadConnections(tr *http.Transport, target *url.URL, addrs []string) {
+	if len(addrs) == 0 {
+		return
+	}
+	endpoint := net.JoinHostPort(target.Hostname(), endpointPort(target))
+	dial := tr.DialContext
+	var next uint64
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == endpoint {
+			addr = addrs[(atomic.AddUint64(&next, 1)-1)%uint64(len(addrs))]
+		}
+		return dial(ctx, network, addr)
+	}
+	// Keep enough connections idle for every address.
+	if tr.MaxIdleConnsPerHost*len(addrs) > tr.MaxIdleConns {
+		tr.MaxIdleConns = tr.MaxIdleConnsPerHost * len(addrs)
+	}
+	tr.MaxIdleConnsPerHost *= len(addrs)
+	logMsg(fmt.Sprintf("spreading destination connections across %s", strings.Join(addrs, ", ")))
+}
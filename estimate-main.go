@@ -0,0 +1,233 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/console"
+)
+
+var estimateFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "from-listing",
+		Usage: "only estimate objects listed in object_listing.txt, an extended listing is used without listing the bucket",
+	},
+	cli.IntFlag{
+		Name:  "concurrency",
+		Usage: "number of concurrent workers to project duration for",
+		Value: migrationConcurrent,
+	},
+	cli.StringFlag{
+		Name:  "throughput",
+		Usage: "aggregate throughput per second to project duration for",
+		Value: "100MiB",
+	},
+	cli.DurationFlag{
+		Name:  "latency",
+		Usage: "expected per object request latency",
+		Value: 50 * time.Millisecond,
+	},
+}
+
+var estimateCmd = cli.Command{
+	Name:   "estimate",
+	Usage:  "estimate object count, size and duration of a migration",
+	Action: estimateAction,
+	Flags:  append(allFlags, estimateFlags...),
+	CustomHelpTemplate: `NAME:
+	 {{.HelpName}} - {{.Usage}}
+ 
+ USAGE:
+	 {{.HelpName}} [--from-listing, --concurrency, --throughput, --latency]
+ 
+ FLAGS:
+	{{range .VisibleFlags}}{{.}}
+	{{end}}
+ 
+ EXAMPLES:
+ 1. Estimate the size and duration of migrating all objects in the bucket at 500MiB/s.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject estimate --data-dir /tmp/ --throughput 500MiB
+
+ 2. Estimate only the objects in "object_listing.txt" with 200 workers, reusing a cached listing.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject estimate --data-dir /tmp/ --from-listing --concurrency 200 --use-cached-listing
+ `,
+}
+
+// sizeHistogramBuckets upper bounds of the object size histogram.
+var sizeHistogramBuckets = []uint64{
+	64 * humanize.KiByte,
+	humanize.MiByte,
+	16 * humanize.MiByte,
+	128 * humanize.MiByte,
+	humanize.GiByte,
+	5 * humanize.GiByte,
+}
+
+type sizeHistogram struct {
+	counts []uint64
+	bytes  []uint64
+}
+
+func newSizeHistogram() *sizeHistogram {
+	return &sizeHistogram{
+		counts: make([]uint64, len(sizeHistogramBuckets)+1),
+		bytes:  make([]uint64, len(sizeHistogramBuckets)+1),
+	}
+}
+
+func (h *sizeHistogram) add(size int64) {
+	i := 0
+	for i < len(sizeHistogramBuckets) && uint64(size) >= sizeHistogramBuckets[i] {
+		i++
+	}
+	h.counts[i]++
+	h.bytes[i] += uint64(size)
+}
+
+func (h *sizeHistogram) label(i int) string {
+	switch i {
+	case 0:
+		return "< " + humanize.IBytes(sizeHistogramBuckets[0])
+	case len(sizeHistogramBuckets):
+		return ">= " + humanize.IBytes(sizeHistogramBuckets[i-1])
+	default:
+		return humanize.IBytes(sizeHistogramBuckets[i-1]) + " - " + humanize.IBytes(sizeHistogramBuckets[i])
+	}
+}
+
+// loadListing returns the keys in object_listing.txt with their size,
+// extended is true when every record carries the size as written by
+// "list --extended".
+func loadListing() (keys map[string]int64, extended bool, err error) {
+	file, err := os.Open(path.Join(dirPath, objListFile))
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+	keys = make(map[string]int64)
+	extended = true
+	scanner := newRecordScanner(file)
+	if err = scanner.checkHeader(objListFile); err != nil {
+		return nil, false, err
+	}
+	for scanner.Scan() {
+		entry, ok, err := parseListingEntry(scanner.Fields())
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			extended = false
+			entry.size = -1
+		}
+		keys[scanner.Text()] = entry.size
+	}
+	return keys, extended && len(keys) > 0, scanner.Err()
+}
+
+func estimateAction(cliCtx *cli.Context) error {
+	checkArgsAndInit(cliCtx)
+	logMsg("Init minio client..")
+	if err := initMinioClient(cliCtx); err != nil {
+		logDMsg("Unable to  initialize MinIO client, exiting...%w", err)
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
+		console.Fatalln(err)
+	}
+	concurrency := cliCtx.Int("concurrency")
+	if concurrency <= 0 {
+		console.Fatalln(fmt.Errorf("--concurrency should be greater than 0"))
+	}
+	throughput, err := humanize.ParseBytes(cliCtx.String("throughput"))
+	if err != nil || throughput == 0 {
+		console.Fatalln(fmt.Errorf("unable to parse --throughput %s", cliCtx.String("throughput")))
+	}
+	latency := cliCtx.Duration("latency")
+
+	var keys map[string]int64
+	var extended bool
+	if cliCtx.Bool("from-listing") {
+		if keys, extended, err = loadListing(); err != nil {
+			logDMsg(fmt.Sprintf("could not read file :%s ", objListFile), err)
+			return err
+		}
+	}
+
+	var count, total uint64
+	hist := newSizeHistogram()
+	if extended {
+		// The listing carries the sizes, no need to list the bucket.
+		for _, size := range keys {
+			count++
+			total += uint64(size)
+			hist.add(size)
+		}
+	} else {
+		for object := range listBucket(context.Background(), minioClient, minioBucket) {
+			if object.Err != nil {
+				fmt.Println(object.Err)
+				return object.Err
+			}
+			if object.IsDeleteMarker || !object.IsLatest {
+				continue
+			}
+			if keys != nil {
+				if _, ok := keys[object.Key]; !ok {
+					continue
+				}
+			}
+			count++
+			total += uint64(object.Size)
+			hist.add(object.Size)
+		}
+	}
+
+	// The run is bound by whichever is slower, moving the bytes at the
+	// given throughput or issuing the requests at the given latency.
+	byBytes := time.Duration(float64(total) / float64(throughput) * float64(time.Second))
+	byRequests := time.Duration(count) * latency / time.Duration(concurrency)
+	projected := byBytes
+	if byRequests > projected {
+		projected = byRequests
+	}
+
+	fmt.Printf("Objects:   %d\n", count)
+	fmt.Printf("Total:     %s\n", humanize.IBytes(total))
+	if count > 0 {
+		fmt.Printf("Average:   %s\n", humanize.IBytes(total/count))
+	}
+	fmt.Println("Size distribution:")
+	for i := range hist.counts {
+		fmt.Printf("  %-20s %12d objects %12s\n", hist.label(i), hist.counts[i], humanize.IBytes(hist.bytes[i]))
+	}
+	fmt.Printf("Projected duration at %d workers, %s/s, %s latency: %s\n",
+		concurrency, humanize.IBytes(throughput), latency, projected.Round(time.Second))
+	return nil
+}
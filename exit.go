@@ -0,0 +1,54 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+// Process exit codes, wrapper scripts can branch on these.
+const (
+	// exitSuccess means the run completed with zero failures.
+	exitSuccess = 0
+	// exitConfig means the run did not start because of invalid flags,
+	// missing credentials or an unreachable data dir.
+	exitConfig = 1
+	// exitFailures means the run completed but some objects failed, see
+	// the fail files in --data-dir.
+	exitFailures = 2
+	// exitAborted means the run stopped midway e.g. on a listing or
+	// write error.
+	exitAborted = 3
+)
+
+// exitCode is the worst exit code of the runs of this process.
+var exitCode = exitSuccess
+
+// raiseExitCode sets the exit code to code unless a run already ended
+// worse, the codes grow with severity.
+func raiseExitCode(code int) {
+	if code > exitCode {
+		exitCode = code
+	}
+}
+
+// setExitCode records the outcome of a run, a clean run after a failed one
+// of --every or --src-buckets does not hide the failure.
+func setExitCode(s runSummary) {
+	switch {
+	case s.Error != "":
+		raiseExitCode(exitAborted)
+	case s.Failures > 0:
+		raiseExitCode(exitFailures)
+	}
+}
@@ -0,0 +1,336 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio/pkg/console"
+)
+
+const failExportFile = "export_fails.txt"
+
+var exportFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "output",
+		Usage: "local path to write the archive to",
+	},
+	cli.StringFlag{
+		Name:  "to-bucket",
+		Usage: "BUCKET/OBJECT on MinIO to upload the archive to instead of --output",
+	},
+	cli.BoolFlag{
+		Name:  "gzip",
+		Usage: "gzip compress the archive",
+	},
+	cli.StringFlag{
+		Name:  "prefix",
+		Usage: "export all objects under this prefix instead of the objects in object_listing.txt",
+	},
+	cli.IntFlag{
+		Name:  "skip, s",
+		Usage: "number of entries to skip from input file",
+		Value: 0,
+	},
+	cli.StringFlag{
+		Name:  "shard",
+		Usage: "only process shard i of N of the input e.g. 0/4, keys are partitioned by hash",
+	},
+	cli.StringFlag{
+		Name:   "cse-key",
+		Usage:  "base64 encoded 32 byte master key to decrypt the objects encrypted by migrate --cse-key with",
+		EnvVar: EnvCSEKey,
+	},
+}
+
+var exportCmd = cli.Command{
+	Name:   "export",
+	Usage:  "stream objects into a tar archive",
+	Action: exportAction,
+	Flags:  append(allFlags, exportFlags...),
+	CustomHelpTemplate: `NAME:
+	 {{.HelpName}} - {{.Usage}}
+ 
+ USAGE:
+	 {{.HelpName}} --output FILE | --to-bucket BUCKET/OBJECT [--gzip, --prefix, --skip]
+ 
+ FLAGS:
+	{{range .VisibleFlags}}{{.}}
+	{{end}}
+ 
+ EXAMPLES:
+ 1. Export the objects in "object_listing.txt" in MinIO to a local tar file.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject export --data-dir /tmp/ --output /backup/listing.tar
+ 
+ 2. Export all objects under prefix 42/ into a single gzip compressed archive in another bucket.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject export --data-dir /tmp/ --prefix 42/ --gzip --to-bucket coldbucket/42.tar.gz
+ `,
+}
+
+// exportPartSize part size of archives uploaded with --to-bucket, the
+// archive size is unknown upfront.
+const exportPartSize = 64 * 1024 * 1024
+
+// exportState counts the objects written to the archive.
+type exportState struct {
+	count   uint64
+	failCnt uint64
+	bytes   uint64
+}
+
+func (e *exportState) getCount() uint64     { return atomic.LoadUint64(&e.count) }
+func (e *exportState) getFailCount() uint64 { return atomic.LoadUint64(&e.failCnt) }
+func (e *exportState) getBytes() uint64     { return atomic.LoadUint64(&e.bytes) }
+func (e *exportState) queueDepth() int      { return 0 }
+
+var expState *exportState
+
+// exportObject appends object to tw. Objects that cannot be read are
+// recorded in fails and skipped, errors writing the archive are returned.
+func exportObject(ctx context.Context, tw *tar.Writer, object string, fails io.Writer) error {
+	getCtx, cancel := opContext(ctx)
+	defer cancel()
+	r, err := minioClient.GetObject(getCtx, minioBucket, object, miniogo.GetObjectOptions{})
+	if err != nil {
+		exportFailed(object, err, fails)
+		return nil
+	}
+	defer r.Close()
+	stat, err := r.Stat()
+	if err != nil {
+		exportFailed(object, err, fails)
+		return nil
+	}
+	var src io.Reader = r
+	if cseKey != nil && isClientEncrypted(stat) {
+		if src, stat.Size, err = decryptReader(r, stat); err != nil {
+			exportFailed(object, err, fails)
+			return nil
+		}
+	}
+	hdr := &tar.Header{
+		Name:    object,
+		Mode:    0644,
+		Size:    stat.Size,
+		ModTime: stat.LastModified,
+	}
+	if err = tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	// The header is written, a short copy leaves the archive unusable.
+	if _, err = io.Copy(tw, src); err != nil {
+		return fmt.Errorf("error exporting %s: %v", object, err)
+	}
+	atomic.AddUint64(&expState.count, 1)
+	atomic.AddUint64(&expState.bytes, uint64(stat.Size))
+	noteProcessed(object)
+	logDMsg("Exported "+object+" successfully", nil)
+	return nil
+}
+
+func exportFailed(object string, err error, fails io.Writer) {
+	atomic.AddUint64(&expState.failCnt, 1)
+	countFailure(failureReason(err))
+	noteProcessed(object)
+	noteError(object, err)
+	logMsg(fmt.Sprintf("error exporting object %s: %s", object, err))
+	if err := writeRecord(fails, object); err != nil {
+		logMsg(fmt.Sprintf("Error writing to export_fails.txt for %s: %s", object, err))
+		os.Exit(exitAborted)
+	}
+}
+
+// openArchive returns the writer of the archive and a function that
+// completes it, or discards it when passed an error.
+func openArchive(ctx context.Context, output, toBucket string, compress bool) (io.Writer, func(error) error, error) {
+	var w io.Writer
+	var done func(error) error
+	if output != "" {
+		f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return nil, nil, err
+		}
+		w = f
+		done = func(error) error { return f.Close() }
+	} else {
+		parts := strings.SplitN(toBucket, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, nil, fmt.Errorf("invalid --to-bucket %q, expected BUCKET/OBJECT", toBucket)
+		}
+		contentType := "application/x-tar"
+		if compress {
+			contentType = "application/gzip"
+		}
+		pr, pw := io.Pipe()
+		uploaded := make(chan error, 1)
+		go func() {
+			_, err := minioClient.PutObject(ctx, parts[0], parts[1], pr, -1, miniogo.PutObjectOptions{
+				ContentType: contentType,
+				PartSize:    exportPartSize,
+			})
+			pr.CloseWithError(err)
+			uploaded <- err
+		}()
+		w = pw
+		done = func(err error) error {
+			// Closing with an error aborts the upload.
+			pw.CloseWithError(err)
+			return <-uploaded
+		}
+	}
+	if !compress {
+		return w, done, nil
+	}
+	gz := gzip.NewWriter(w)
+	return gz, func(err error) error {
+		if err == nil {
+			err = gz.Close()
+		}
+		if derr := done(err); err == nil {
+			err = derr
+		}
+		return err
+	}, nil
+}
+
+func exportAction(cliCtx *cli.Context) (err error) {
+	checkArgsAndInit(cliCtx)
+	ctx := context.Background()
+	output, toBucket := cliCtx.String("output"), cliCtx.String("to-bucket")
+	if (output == "") == (toBucket == "") {
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name)
+		console.Fatalln("exactly one of --output or --to-bucket must be set")
+	}
+	if cseKey, err = parseCSEKey(cliCtx.String("cse-key")); err != nil {
+		console.Fatalln(err)
+	}
+	logMsg("Init minio client..")
+	if err := initMinioClient(cliCtx); err != nil {
+		logDMsg("Unable to  initialize MinIO client, exiting...%w", err)
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
+		console.Fatalln(err)
+	}
+	expState = &exportState{}
+	start := time.Now()
+	defer func() { reportRun("export", expState, start, err) }()
+
+	fails, err := createRecordFile(failExportFile)
+	if err != nil {
+		logDMsg("could not create "+failExportFile, err)
+		return err
+	}
+	defer fails.Close()
+
+	w, done, err := openArchive(ctx, output, toBucket, cliCtx.Bool("gzip"))
+	if err != nil {
+		console.Fatalln(err)
+	}
+	tw := tar.NewWriter(w)
+	stopProgress := startProgress("Exporting", expState)
+	err = exportObjects(ctx, tw, cliCtx.String("prefix"), cliCtx.Int("skip"), fails)
+	if err == nil {
+		err = tw.Close()
+	}
+	if derr := done(err); err == nil {
+		err = derr
+	}
+	stopProgress()
+	if err != nil {
+		logDMsg("export failed", err)
+		return err
+	}
+	logMsg(fmt.Sprintf("Exported %d objects, %d failures", expState.getCount(), expState.getFailCount()))
+	logMsg("successfully completed export.")
+	return nil
+}
+
+// exportObjects writes the objects under prefix, or in object_listing.txt
+// when prefix is empty, to tw.
+func exportObjects(ctx context.Context, tw *tar.Writer, prefix string, skip int, fails io.Writer) error {
+	if prefix != "" {
+		opts := miniogo.ListObjectsOptions{
+			Recursive: true,
+			Prefix:    prefix,
+		}
+		for object := range minioClient.ListObjects(ctx, minioBucket, opts) {
+			if object.Err != nil {
+				fmt.Println(object.Err)
+				return object.Err
+			}
+			if !inShard(object.Key) {
+				continue
+			}
+			if !admit() {
+				return nil
+			}
+			if err := exportObject(ctx, tw, object.Key, fails); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	file, err := os.Open(path.Join(dirPath, objListFile))
+	if err != nil {
+		logDMsg(fmt.Sprintf("could not open file :%s ", objListFile), err)
+		return err
+	}
+	defer file.Close()
+	scanner := newRecordScanner(file)
+	if err := scanner.checkHeader(objListFile); err != nil {
+		return err
+	}
+	for scanner.Scan() {
+		o := scanner.Text()
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if !inShard(o) {
+			continue
+		}
+		if !admit() {
+			break
+		}
+		if err := exportObject(ctx, tw, o, fails); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logDMsg(fmt.Sprintf("error processing file :%s ", objListFile), err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/minio/minio/pkg/console"
+)
+
+// failureClassFiles are the fail files of the run by error class, next to
+// the combined fail file, so that each remediation, e.g. restoring missing
+// objects, fixing credentials or re-running timeouts, has its own list.
+var failureClassFiles = map[string]string{
+	"NoSuchKey":             "not_found.txt",
+	"NoSuchVersion":         "not_found.txt",
+	"AccessDenied":          "access_denied.txt",
+	"InvalidAccessKeyId":    "access_denied.txt",
+	"SignatureDoesNotMatch": "access_denied.txt",
+	"Timeout":               "timeouts.txt",
+	"Stalled":               "timeouts.txt",
+	"ChecksumMismatch":      "checksum_mismatch.txt",
+}
+
+var (
+	failureClassMu sync.Mutex
+	// failureClasses are the class files opened so far, by path of the
+	// run directory and name.
+	failureClasses = make(map[string]*os.File)
+)
+
+// recordFailureClass adds the record object of a failure with err to the
+// fail file of its class, created on the first failure of the class.
+func recordFailureClass(object string, err error) {
+	name, ok := failureClassFiles[failureReason(err)]
+	if !ok {
+		return
+	}
+	dir, perr := runPath("")
+	if perr != nil {
+		console.Errorln(fmt.Sprintf("unable to record %s in %s: %v", object, name, perr))
+		return
+	}
+	failureClassMu.Lock()
+	defer failureClassMu.Unlock()
+	f, ok := failureClasses[dir+"/"+name]
+	if !ok {
+		var cerr error
+		if f, cerr = createRecordFile(name); cerr != nil {
+			console.Errorln(fmt.Sprintf("unable to create %s: %v", name, cerr))
+			return
+		}
+		failureClasses[dir+"/"+name] = f
+	}
+	if werr := writeRecord(f, object); werr != nil {
+		console.Errorln(fmt.Sprintf("unable to record %s in %s: %v", object, name, werr))
+	}
+}
+
+// closeFailureClasses closes the class fail files at the end of a run.
+func closeFailureClasses() {
+	failureClassMu.Lock()
+	defer failureClassMu.Unlock()
+	for key, f := range failureClasses {
+		f.Close()
+		delete(failureClasses, key)
+	}
+}
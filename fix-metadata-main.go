@@ -0,0 +1,184 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/console"
+)
+
+const metadataRulesFile = "metadata_rules.json"
+
+var fixMetadataFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "rules",
+		Usage: "JSON file with the metadata rules, defaults to " + metadataRulesFile + " in data-dir",
+	},
+}
+
+var fixMetadataCmd = cli.Command{
+	Name:   "fix-metadata",
+	Usage:  "rewrite object headers in place according to a rules file",
+	Action: fixMetadataAction,
+	Flags:  append(append(allFlags, migrateFlags...), fixMetadataFlags...),
+	CustomHelpTemplate: `NAME:
+	 {{.HelpName}} - {{.Usage}}
+ 
+ USAGE:
+	 {{.HelpName}} [--skip, --fake, --rules]
+ 
+ FLAGS:
+	{{range .VisibleFlags}}{{.}}
+	{{end}}
+ 
+ RULES:
+	The rules file is a JSON array, the first rule whose prefix and suffix match
+	an object is applied. "headers" are always set, "defaults" only when missing.
+	[{"suffix": ".json", "headers": {"Content-Type": "application/json"}},
+	 {"prefix": "img/", "defaults": {"Cache-Control": "max-age=86400"}}]
+ 
+ EXAMPLES:
+ 1. Fix the headers of objects in "object_listing.txt" in MinIO using "metadata_rules.json" in data-dir.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject fix-metadata --data-dir /tmp/
+ 
+ 2. Perform a dry run printing the headers each object in "object_listing.txt" would get.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject fix-metadata --data-dir /tmp/ --rules /tmp/rules.json --fake --log
+ `,
+}
+
+// metadataRule sets Headers on, and adds missing Defaults to, objects
+// with the given prefix and suffix.
+type metadataRule struct {
+	Prefix   string            `json:"prefix"`
+	Suffix   string            `json:"suffix"`
+	Headers  map[string]string `json:"headers"`
+	Defaults map[string]string `json:"defaults"`
+}
+
+var metadataRules []metadataRule
+
+func loadMetadataRules(name string) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, &metadataRules); err != nil {
+		return fmt.Errorf("unable to parse %s: %v", name, err)
+	}
+	return nil
+}
+
+// matchMetadataRule returns the first rule matching object, nil if none.
+func matchMetadataRule(object string) *metadataRule {
+	for i, r := range metadataRules {
+		if strings.HasPrefix(object, r.Prefix) && strings.HasSuffix(object, r.Suffix) {
+			return &metadataRules[i]
+		}
+	}
+	return nil
+}
+
+func fixMetadataAction(cliCtx *cli.Context) (err error) {
+	checkArgsAndInit(cliCtx)
+	ctx := context.Background()
+	rules := cliCtx.String("rules")
+	if rules == "" {
+		rules = path.Join(dirPath, metadataRulesFile)
+	}
+	if err := loadMetadataRules(rules); err != nil {
+		console.Fatalln(err)
+	}
+	logMsg("Init minio client..")
+	if err := initMinioClient(cliCtx); err != nil {
+		logDMsg("Unable to  initialize MinIO client, exiting...%w", err)
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
+		console.Fatalln(err)
+	}
+	fixMetaState = newFixMetadataState(ctx)
+	fixMetaState.init(ctx)
+	start := time.Now()
+	defer func() { reportRun("fix-metadata", fixMetaState, start, err) }()
+	stopProbe := startProbe(ctx, minioClient, minioBucket)
+	skip := cliCtx.Int("skip")
+	dryRun = cliCtx.Bool("fake")
+	var succeeded map[string]struct{}
+	if cliCtx.Bool("skip-succeeded") {
+		if succeeded, err = loadSucceeded(successFixMetaFile); err != nil {
+			logDMsg("could not load "+successFixMetaFile, err)
+			return err
+		}
+	}
+	file, err := os.Open(path.Join(dirPath, objListFile))
+	if err != nil {
+		logDMsg(fmt.Sprintf("could not open file :%s ", objListFile), err)
+		return err
+	}
+	scanner := newRecordScanner(file)
+	if err := scanner.checkHeader(objListFile); err != nil {
+		return err
+	}
+	if err := startCheckpoint(cliCtx, scanner, objListFile); err != nil {
+		return err
+	}
+	for checkpoint.scan(scanner) {
+		o := scanner.Text()
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if !inShard(o) {
+			continue
+		}
+		if _, ok := succeeded[o]; ok {
+			logDMsg(fmt.Sprintf("skipping %s, already succeeded", o), nil)
+			continue
+		}
+		if !admit() {
+			break
+		}
+		checkpoint.queue(o)
+		fixMetaState.queueUploadTask(o)
+		logDMsg(fmt.Sprintf("adding %s to fix-metadata queue", o), nil)
+	}
+	if err := scanner.Err(); err != nil {
+		logDMsg(fmt.Sprintf("error processing file :%s ", objListFile), err)
+		return err
+	}
+	fixMetaState.finish(ctx)
+	stopCheckpoint()
+	stopProbe()
+	logMsg("successfully completed fix-metadata.")
+
+	return nil
+}
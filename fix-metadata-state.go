@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+var fixMetaState *taskPool
+
+func newFixMetadataState(ctx context.Context) *taskPool {
+	return newTaskPool(poolTasks{
+		action:       "Fixing metadata",
+		matchPattern: true,
+		run: func(ctx context.Context, fields []string) error {
+			return fixMetadataObject(ctx, fields[0])
+		},
+		failFile:    failFixMetaFile,
+		successFile: successFixMetaFile,
+		done:        "Fixed metadata of %d objects, %d failures",
+	})
+}
+
+// fixMetadataObject rewrites the headers of object in place with the
+// first matching rule. Objects whose headers already conform are left
+// untouched.
+func fixMetadataObject(ctx context.Context, object string) error {
+	rule := matchMetadataRule(object)
+	if rule == nil {
+		logDMsg("no metadata rule matches "+object, nil)
+		return nil
+	}
+
+	statCtx, cancel := opContext(ctx)
+	stat, err := minioClient.StatObject(statCtx, minioBucket, object, miniogo.StatObjectOptions{})
+	cancel()
+	if err != nil {
+		logDMsg("stat failed for "+object, err)
+		return err
+	}
+	// Rule keys may name user metadata with or without X-Amz-Meta-, they
+	// are compared in the form of the preserved keys so that each header
+	// is sent once.
+	meta := preservedMetadata(stat)
+	changed := false
+	for k, v := range rule.Headers {
+		if k = metadataKey(k); meta[k] != v {
+			meta[k] = v
+			changed = true
+		}
+	}
+	for k, v := range rule.Defaults {
+		if _, ok := meta[metadataKey(k)]; !ok {
+			meta[metadataKey(k)] = v
+			changed = true
+		}
+	}
+	if !changed {
+		logDMsg("metadata of "+object+" already conforms", nil)
+		return nil
+	}
+	if dryRun {
+		logMsg(fmt.Sprintf("%s: %v", object, meta))
+		return nil
+	}
+
+	src := miniogo.CopySrcOptions{
+		Bucket:    minioBucket,
+		Object:    object,
+		VersionID: stat.VersionID,
+	}
+	dst := miniogo.CopyDestOptions{
+		Bucket:          minioBucket,
+		Object:          object,
+		ReplaceMetadata: true,
+		UserMetadata:    meta,
+	}
+	if stat.Size > maxCopyObjectSize {
+		// A single copy request is limited to 5GiB, larger objects are
+		// rewritten with one copy request per part.
+		_, err = minioClient.ComposeObject(ctx, dst, src)
+	} else {
+		copyCtx, cancel := opContext(ctx)
+		_, err = minioClient.CopyObject(copyCtx, dst, src)
+		cancel()
+	}
+	if err != nil {
+		logDMsg("metadata update failed for "+object, err)
+		return err
+	}
+	fixMetaState.addBytes(stat.Size)
+	logDMsg("Updated metadata of "+object+" successfully", nil)
+	return nil
+}
@@ -0,0 +1,97 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// flushEvery set by --flush-every is the number of processed objects after
+// which the success and fail files are synced to disk and the status and
+// checkpoint files are rewritten, bounding the bookkeeping lost on a crash
+// to as many objects. Zero leaves it to the periodic writes.
+var flushEvery uint64
+
+var (
+	flushMu sync.Mutex
+	// recordFiles are the success and fail files synced on flush.
+	recordFiles []*os.File
+	processed   uint64
+	flushing    int32
+	// statusFlushCh and checkpointFlushCh ask the status and checkpoint
+	// writers for an immediate write, nil when they are not running.
+	statusFlushCh     chan struct{}
+	checkpointFlushCh chan struct{}
+)
+
+// syncOnFlush adds f to the files synced on flush.
+func syncOnFlush(f *os.File) {
+	flushMu.Lock()
+	recordFiles = append(recordFiles, f)
+	flushMu.Unlock()
+}
+
+// setFlushCh registers or, with nil, unregisters the channel of a writer.
+func setFlushCh(target *chan struct{}, ch chan struct{}) {
+	flushMu.Lock()
+	*target = ch
+	flushMu.Unlock()
+}
+
+// countProcessed counts a processed object and flushes in the background
+// every flushEvery objects, a flush still running is not waited for.
+func countProcessed() {
+	if flushEvery == 0 || atomic.AddUint64(&processed, 1)%flushEvery != 0 {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&flushing, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&flushing, 0)
+		flush()
+	}()
+}
+
+// flush syncs the record files and has the status and checkpoint files
+// rewritten. Files closed since they were created are dropped.
+func flush() {
+	flushMu.Lock()
+	open := recordFiles[:0]
+	for _, f := range recordFiles {
+		if err := f.Sync(); err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				continue
+			}
+			logMsg(fmt.Sprintf("unable to sync %s: %v", f.Name(), err))
+		}
+		open = append(open, f)
+	}
+	recordFiles = open
+	writers := []chan struct{}{statusFlushCh, checkpointFlushCh}
+	flushMu.Unlock()
+	for _, ch := range writers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio/pkg/console"
+)
+
+const freezeCheckFile = "freeze_check.txt"
+
+var freezeCheckFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "since",
+		Usage: "start of the write freeze in RFC3339 format e.g. 2021-03-01T22:00:00Z",
+	},
+	cli.DurationFlag{
+		Name:  "listen",
+		Usage: "additionally listen for bucket notifications for this long and report any write",
+	},
+}
+
+var freezeCheckCmd = cli.Command{
+	Name:   "freeze-check",
+	Usage:  "verify that a bucket had no writes since a given time",
+	Action: freezeCheckAction,
+	Flags:  append(allFlags, freezeCheckFlags...),
+	CustomHelpTemplate: `NAME:
+	 {{.HelpName}} - {{.Usage}}
+ 
+ USAGE:
+	 {{.HelpName}} --since TIME [--listen DURATION]
+ 
+ FLAGS:
+	{{range .VisibleFlags}}{{.}}
+	{{end}}
+ 
+ EXAMPLES:
+ 1. Verify no object was written or deleted in the bucket since the freeze started.
+	$ export MINIO_ENDPOINT=https://minio-src:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=srcbucket
+	$ moveobject freeze-check --data-dir /tmp/ --since 2021-03-01T22:00:00Z
+
+ 2. Same as above and also verify there are no bucket notifications for 10 minutes.
+	$ export MINIO_ENDPOINT=https://minio-src:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=srcbucket
+	$ moveobject freeze-check --data-dir /tmp/ --since 2021-03-01T22:00:00Z --listen 10m
+ `,
+}
+
+func freezeCheckAction(cliCtx *cli.Context) error {
+	checkArgsAndInit(cliCtx)
+	since, err := time.Parse(time.RFC3339, cliCtx.String("since"))
+	if err != nil {
+		console.Fatalln(fmt.Errorf("unable to parse --since %q: %v", cliCtx.String("since"), err))
+	}
+	logMsg("Init minio client..")
+	if err := initMinioClient(cliCtx); err != nil {
+		logDMsg("Unable to  initialize MinIO client, exiting...%w", err)
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
+		console.Fatalln(err)
+	}
+	ctx := context.Background()
+
+	f, err := createOutputFile(freezeCheckFile)
+	if err != nil {
+		logDMsg("could not create "+freezeCheckFile, err)
+		console.Fatalln(err)
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "bucket: %s\nsince: %s\nchecked: %s\n", minioBucket, since.Format(time.RFC3339), time.Now().UTC().Format(time.RFC3339))
+
+	// The listing is always live here, a cached listing can't prove a freeze.
+	var scanned, violations uint64
+	opts := miniogo.ListObjectsOptions{
+		WithVersions: true,
+		Recursive:    true,
+	}
+	for object := range minioClient.ListObjects(ctx, minioBucket, opts) {
+		if object.Err != nil {
+			fmt.Println(object.Err)
+			return object.Err
+		}
+		scanned++
+		if object.LastModified.After(since) {
+			violations++
+			kind := "write"
+			if object.IsDeleteMarker {
+				kind = "delete"
+			}
+			fmt.Fprintf(f, "%s,%s,%s,%s\n", kind, object.LastModified.UTC().Format(time.RFC3339), object.VersionID, object.Key)
+			logMsg(fmt.Sprintf("%s of %s at %s after freeze", kind, object.Key, object.LastModified.UTC().Format(time.RFC3339)))
+		}
+	}
+
+	if listen := cliCtx.Duration("listen"); listen > 0 {
+		logMsg(fmt.Sprintf("listening for bucket notifications for %s", listen))
+		lctx, cancel := context.WithTimeout(ctx, listen)
+		events := []string{"s3:ObjectCreated:*", "s3:ObjectRemoved:*"}
+		for info := range minioClient.ListenBucketNotification(lctx, minioBucket, "", "", events) {
+			if info.Err != nil {
+				if lctx.Err() != nil {
+					break
+				}
+				cancel()
+				fmt.Println(info.Err)
+				return info.Err
+			}
+			for _, record := range info.Records {
+				violations++
+				fmt.Fprintf(f, "event,%s,%s,%s\n", record.EventTime, record.EventName, record.S3.Object.Key)
+				logMsg(fmt.Sprintf("%s on %s during freeze check", record.EventName, record.S3.Object.Key))
+			}
+		}
+		cancel()
+	}
+
+	fmt.Fprintf(f, "scanned: %d\nviolations: %d\n", scanned, violations)
+	if violations > 0 {
+		console.Errorln(fmt.Errorf("freeze not honored, %d writes to %s since %s, see %s", violations, minioBucket, since.Format(time.RFC3339), f.Name()))
+		raiseExitCode(exitFailures)
+		return nil
+	}
+	fmt.Printf("freeze honored, no writes to %s since %s across %d versions, evidence in %s\n", minioBucket, since.Format(time.RFC3339), scanned, f.Name())
+	return nil
+}
@@ -3,9 +3,12 @@ module github.com/minio/moveobject
 go 1.16
 
 require (
+	github.com/dustin/go-humanize v1.0.0
 	github.com/fatih/color v1.7.0
+	github.com/gomodule/redigo v2.0.0+incompatible
 	github.com/minio/cli v1.22.0
 	github.com/minio/minio v0.0.0-20200806030120-121164db56c1
 	github.com/minio/minio-go/v7 v7.0.6-0.20201010062427-39dead307a0d
-
+	github.com/segmentio/kafka-go v0.4.47
+	golang.org/x/text v0.13.0
 )
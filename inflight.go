@@ -0,0 +1,104 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+)
+
+// inflightLimiter caps the number of bytes in flight against an
+// endpoint, independent of the number of workers.
+type inflightLimiter struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    int64
+	inflight int64
+}
+
+var (
+	srcInflight *inflightLimiter
+	dstInflight *inflightLimiter
+)
+
+// newInflightLimiter parses a human readable size such as "512MiB",
+// an empty value disables the limit.
+func newInflightLimiter(limit string) (*inflightLimiter, error) {
+	if limit == "" {
+		return nil, nil
+	}
+	n, err := humanize.ParseBytes(limit)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse inflight limit %s: %v", limit, err)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	l := &inflightLimiter{limit: int64(n)}
+	l.cond = sync.NewCond(&l.mu)
+	return l, nil
+}
+
+// acquire blocks until size bytes can be put in flight or ctx is done and
+// returns the amount reserved, objects bigger than the limit reserve the
+// whole limit so that they are transferred alone instead of never.
+func (l *inflightLimiter) acquire(ctx context.Context, size int64) (int64, error) {
+	if l == nil {
+		return 0, nil
+	}
+	if size > l.limit {
+		size = l.limit
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight+size > l.limit {
+		// Wake the wait below up when ctx is done.
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				l.mu.Lock()
+				l.cond.Broadcast()
+				l.mu.Unlock()
+			case <-done:
+			}
+		}()
+	}
+	for l.inflight+size > l.limit {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		l.cond.Wait()
+	}
+	l.inflight += size
+	return size, nil
+}
+
+// release returns bytes reserved by acquire.
+func (l *inflightLimiter) release(size int64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.inflight -= size
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
@@ -0,0 +1,101 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/minio/cli"
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaBatchWait is how long to wait for more messages before the
+// current batch is processed and its offsets committed.
+const kafkaBatchWait = time.Second
+
+// queueFromKafka feeds object keys consumed from a Kafka topic to the
+// migration workers until interrupted. Offsets of the consumer group are
+// only committed once every object of a batch has been processed, so the
+// group offset doubles as the migration checkpoint.
+func queueFromKafka(ctx context.Context, cliCtx *cli.Context) error {
+	brokers := strings.Split(cliCtx.String("kafka-brokers"), ",")
+	topic := cliCtx.String("kafka-topic")
+	if topic == "" {
+		return errors.New("--kafka-topic needs to be set with --kafka-brokers")
+	}
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: brokers,
+		Topic:   topic,
+		GroupID: cliCtx.String("kafka-group"),
+	})
+	defer r.Close()
+
+	stopCh := make(chan os.Signal, 1)
+	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stopCh)
+
+	logMsg(fmt.Sprintf("consuming object keys from kafka topic %s", topic))
+	batch := make([]kafka.Message, 0, migrationConcurrent)
+	for {
+		if limitReached() {
+			// Commit what was queued before stopping.
+			if len(batch) > 0 {
+				migrationState.drain()
+				if err := r.CommitMessages(ctx, batch...); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		fetchCtx, cancel := context.WithTimeout(ctx, kafkaBatchWait)
+		msg, err := r.FetchMessage(fetchCtx)
+		cancel()
+		if err == nil {
+			if o := strings.TrimSpace(string(msg.Value)); o != "" && admit() {
+				migrationState.queueUploadTask(o)
+				logDMsg(fmt.Sprintf("adding %s to migration queue", o), nil)
+			}
+			batch = append(batch, msg)
+			if len(batch) < cap(batch) {
+				continue
+			}
+		} else if !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		if len(batch) > 0 {
+			migrationState.drain()
+			if err = r.CommitMessages(ctx, batch...); err != nil {
+				return err
+			}
+			logDMsg(fmt.Sprintf("committed kafka offset %d of partition %d", batch[len(batch)-1].Offset, batch[len(batch)-1].Partition), nil)
+			batch = batch[:0]
+		}
+		select {
+		case <-stopCh:
+			logMsg("stopping kafka consumer")
+			return nil
+		default:
+		}
+	}
+}
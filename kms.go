@@ -0,0 +1,78 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// dstKMSKeys set by --dst-kms-key holds the SSE-KMS key of each destination
+// bucket, the "" entry applies to buckets without a key of their own.
+var dstKMSKeys map[string]encrypt.ServerSide
+
+// parseKMSKeys parses "bucket=keyID" entries and at most one bare "keyID"
+// used for all other buckets.
+func parseKMSKeys(entries []string) (map[string]encrypt.ServerSide, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	keys := make(map[string]encrypt.ServerSide, len(entries))
+	for _, entry := range entries {
+		bucket, keyID := "", entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			bucket, keyID = entry[:i], entry[i+1:]
+			if bucket == "" {
+				return nil, fmt.Errorf("invalid --dst-kms-key %q, expected bucket=keyID", entry)
+			}
+		}
+		if keyID == "" {
+			return nil, fmt.Errorf("invalid --dst-kms-key %q, empty key ID", entry)
+		}
+		if _, ok := keys[bucket]; ok {
+			return nil, fmt.Errorf("--dst-kms-key set more than once for bucket %q", bucket)
+		}
+		sse, err := encrypt.NewSSEKMS(keyID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --dst-kms-key %q: %v", entry, err)
+		}
+		keys[bucket] = sse
+	}
+	return keys, nil
+}
+
+// destinationEncryption returns the encryption of copies written to bucket,
+// --dst-sse for buckets without a --dst-kms-key, nil to leave it to the
+// bucket's default.
+func destinationEncryption(bucket string) encrypt.ServerSide {
+	if sse, ok := dstKMSKeys[bucket]; ok {
+		return sse
+	}
+	if sse, ok := dstKMSKeys[""]; ok {
+		return sse
+	}
+	return dstSSE
+}
+
+// isEncrypted returns whether the server encrypted the object stat
+// describes with SSE-S3 or SSE-KMS, whose ETags are not the MD5 of the data.
+func isEncrypted(stat miniogo.ObjectInfo) bool {
+	return stat.Metadata.Get("X-Amz-Server-Side-Encryption") != ""
+}
@@ -0,0 +1,53 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+var (
+	// runLimit set by --limit caps the number of objects queued per run,
+	// zero is unlimited.
+	runLimit uint64
+	// queued counts the objects queued in this run.
+	queued uint64
+)
+
+// admit counts an object about to be queued, it returns false once
+// --limit objects were queued in this run.
+func admit() bool {
+	if runLimit == 0 {
+		return true
+	}
+	n := atomic.AddUint64(&queued, 1)
+	if n == runLimit+1 {
+		logMsg(fmt.Sprintf("reached --limit %d, not queueing more objects", runLimit))
+	}
+	return n <= runLimit
+}
+
+// limitReached reports whether --limit objects were queued in this run.
+func limitReached() bool {
+	return runLimit > 0 && atomic.LoadUint64(&queued) >= runLimit
+}
+
+// resetLimit starts counting a new run.
+func resetLimit() {
+	atomic.StoreUint64(&queued, 0)
+}
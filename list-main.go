@@ -20,19 +20,37 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"strconv"
+	"time"
 
 	"github.com/minio/cli"
-	"github.com/minio/minio-go/v7"
+	miniogo "github.com/minio/minio-go/v7"
 	"github.com/minio/minio/pkg/console"
 )
 
+var listFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "extended",
+		Usage: "also record size, ETag, storage class and modification time of every object",
+	},
+	cli.IntFlag{
+		Name:  "min-versions",
+		Usage: "only list objects with at least this many versions including delete markers, 2 lists objects with non-current versions",
+	},
+	cli.BoolFlag{
+		Name:  "resume",
+		Usage: "continue an interrupted listing after the last key recorded in listing_marker.json instead of starting over",
+	},
+}
+
 var listCmd = cli.Command{
 	Name:   "list",
 	Usage:  "list objects and it's version",
 	Action: listAction,
-	Flags:  allFlags,
+	Flags:  append(append(append(allFlags, listFlags...), listingFlags...), prefixFileFlags...),
 	CustomHelpTemplate: `NAME:
 	 {{.HelpName}} - {{.Usage}}
  
@@ -50,9 +68,86 @@ var listCmd = cli.Command{
 	$ export MINIO_SECRET_KEY=minio123
 	$ export MINIO_BUCKET=miniobucket
 	$ moveobject list --data-dir /tmp/
+
+ 2. save list of object versions in "version_listing.txt" reusing a listing cached in the last 6 hours.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject list --data-dir /tmp/ --use-cached-listing --cached-listing-max-age 6h
+
+ 3. save list of object versions with their size, ETag, storage class and modification time.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject list --data-dir /tmp/ --extended
+
+ 4. save list of objects with 10 or more versions, to prune or migrate them separately.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject list --data-dir /tmp/ --min-versions 10
+
+ 5. save list of the latest objects sorting after the last key of an interrupted listing, 500 keys per page.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject list --data-dir /tmp/ --start-after 42/abc/d/e/2021/01/f --max-keys 500
+
+ 6. continue an interrupted listing of a huge bucket where it left off.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject list --data-dir /tmp/ --resume
  `,
 }
 
+// listingEntry is an extended listing record
+// "versionID,size,etag,storageClass,lastModified,key".
+type listingEntry struct {
+	versionID    string
+	size         int64
+	etag         string
+	storageClass string
+	lastModified time.Time
+	key          string
+}
+
+// extendedFields is the number of fields of an extended listing record.
+const extendedFields = 6
+
+func (e listingEntry) fields() []string {
+	return []string{e.versionID, strconv.FormatInt(e.size, 10), e.etag, e.storageClass, e.lastModified.UTC().Format(time.RFC3339), e.key}
+}
+
+// parseListingEntry decodes an extended listing record, it returns false
+// for records of a plain listing.
+func parseListingEntry(fields []string) (listingEntry, bool, error) {
+	if len(fields) != extendedFields {
+		return listingEntry{}, false, nil
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return listingEntry{}, false, fmt.Errorf("invalid size in listing record of %s: %v", fields[5], err)
+	}
+	modTime, err := time.Parse(time.RFC3339, fields[4])
+	if err != nil {
+		return listingEntry{}, false, fmt.Errorf("invalid modification time in listing record of %s: %v", fields[5], err)
+	}
+	return listingEntry{
+		versionID:    fields[0],
+		size:         size,
+		etag:         fields[2],
+		storageClass: fields[3],
+		lastModified: modTime,
+		key:          fields[5],
+	}, true, nil
+}
+
 func listAction(cliCtx *cli.Context) error {
 	checkArgsAndInit(cliCtx)
 	logMsg("Init minio client..")
@@ -61,7 +156,37 @@ func listAction(cliCtx *cli.Context) error {
 		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
 		console.Fatalln(err)
 	}
-	s, err := os.OpenFile(path.Join(dirPath, versionListFile), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	extended := cliCtx.Bool("extended")
+	minVersions := cliCtx.Int("min-versions")
+	if minVersions < 0 {
+		console.Fatalln(fmt.Errorf("--min-versions should not be negative"))
+	}
+	if useCachedListing && tunedListing() {
+		console.Fatalln(errCachedListing)
+	}
+	if minVersions > 1 && (listDelimiter != "" || listStartAfter != "") {
+		console.Fatalln(fmt.Errorf("--delimiter and --start-after only list the latest version of each object, they cannot be combined with --min-versions"))
+	}
+	resume := cliCtx.Bool("resume")
+	if resume && (useCachedListing || listDelimiter != "" || listStartAfter != "" || len(filePrefixes) > 0) {
+		console.Fatalln(fmt.Errorf("--resume continues the listing of the whole bucket, it cannot be combined with --use-cached-listing, --delimiter, --start-after or --prefix-file"))
+	}
+	if resume && cliCtx.String("signature") == "v2" {
+		console.Fatalln(fmt.Errorf("--resume signs the continued listing with signature v4, it cannot be combined with --signature v2"))
+	}
+	marker := listingMarker{Bucket: minioBucket, Extended: extended, MinVersions: minVersions}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		prev, err := readListingMarker(minioBucket)
+		if err != nil {
+			console.Fatalln(err)
+		}
+		if prev.Extended != extended || prev.MinVersions != minVersions {
+			console.Fatalln(fmt.Errorf("%s was written with other --extended or --min-versions flags, run without --resume", listingMarkerFile))
+		}
+		marker, flags = prev, os.O_WRONLY
+	}
+	s, err := os.OpenFile(path.Join(dirPath, versionListFile), flags, 0600)
 	if err != nil {
 		logDMsg("could not create "+versionListFile, err)
 		console.Fatalln(err)
@@ -69,26 +194,106 @@ func listAction(cliCtx *cli.Context) error {
 	swriter := bufio.NewWriter(s)
 	defer swriter.Flush()
 	defer s.Close()
+	if resume {
+		// Records written after the marker was taken are listed again.
+		if err = s.Truncate(marker.Offset); err == nil {
+			_, err = s.Seek(marker.Offset, io.SeekStart)
+		}
+		if err != nil {
+			console.Fatalln(fmt.Errorf("unable to resume %s at offset %d: %v", versionListFile, marker.Offset, err))
+		}
+		logMsg(fmt.Sprintf("resuming listing of %s after %s", minioBucket, marker.Key))
+	} else if err = writeRecordHeader(s); err != nil {
+		console.Fatalln(err)
+	}
 
-	opts := minio.ListObjectsOptions{
+	write := func(object miniogo.ObjectInfo) {
+		fields := []string{object.VersionID, object.Key}
+		if extended {
+			fields = listingEntry{
+				versionID:    object.VersionID,
+				size:         object.Size,
+				etag:         object.ETag,
+				storageClass: object.StorageClass,
+				lastModified: object.LastModified,
+				key:          object.Key,
+			}.fields()
+		}
+		if err := writeRecord(s, fields...); err != nil {
+			logMsg(fmt.Sprintf("Error writing to version_listing.txt for "+object.Key, err))
+			os.Exit(exitAborted)
+		}
+	}
+	// Versions of a key are listed one after the other, the latest one is
+	// held back until the versions of its key are counted.
+	var (
+		latest   *miniogo.ObjectInfo
+		key      string
+		versions int
+	)
+	flush := func() {
+		if latest != nil && versions >= minVersions {
+			write(*latest)
+		}
+		latest = nil
+	}
+	// Every listingMarkerInterval the key whose versions were written last
+	// is recorded for --resume, unless only part of the bucket is listed.
+	resumable := listDelimiter == "" && listStartAfter == "" && len(filePrefixes) == 0
+	saved := time.Now()
+	save := func() {
+		offset, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			logMsg(fmt.Sprintf("unable to record the %s offset: %v", versionListFile, err))
+			return
+		}
+		marker.Key, marker.Offset = key, offset
+		marker.write()
+		saved = time.Now()
+	}
+	// List all objects from a bucket-name, served from the listing cache
+	// when --use-cached-listing is set. A listing tuned by the listing flags,
+	// resumed or of the prefixes of --prefix-file covers only part of the
+	// bucket and is not cached.
+	ctx := context.Background()
+	if resume {
+		ctx = withListingMarker(ctx, marker.Key)
+	}
+	opts := miniogo.ListObjectsOptions{
 		WithVersions: true,
 		Recursive:    true,
-		Prefix:       "",
 	}
-
-	// List all objects from a bucket-name with a matching prefix.
-	for object := range minioClient.ListObjects(context.Background(), minioBucket, opts) {
+	var objCh <-chan miniogo.ObjectInfo
+	switch {
+	case len(filePrefixes) > 0 && !useCachedListing:
+		objCh = listFilePrefixes(ctx, minioClient, minioBucket, opts)
+	case tunedListing() || resume:
+		objCh = listObjects(ctx, minioClient, minioBucket, opts)
+	default:
+		objCh = listBucket(ctx, minioClient, minioBucket)
+	}
+	for object := range objCh {
 		if object.Err != nil {
 			fmt.Println(object.Err)
 			return object.Err
 		}
-		if !object.IsDeleteMarker && object.IsLatest && patternMatch(object.Key) {
-			if _, err := s.WriteString(object.VersionID + "," + object.Key + "\n"); err != nil {
-				logMsg(fmt.Sprintf("Error writing to version_listing.txt for "+object.Key, err))
-				os.Exit(1)
+		if object.Key != key {
+			flush()
+			if resumable && key != "" && time.Since(saved) >= listingMarkerInterval {
+				save()
 			}
+			key, versions = object.Key, 0
+		}
+		versions++
+		if !object.IsDeleteMarker && object.IsLatest && patternMatch(object.Key) {
+			object := object
+			latest = &object
 		}
 	}
+	flush()
+	if err = os.Remove(listingMarkerPath()); err != nil && !os.IsNotExist(err) {
+		logMsg(fmt.Sprintf("unable to remove %s: %v", listingMarkerFile, err))
+	}
 
 	logMsg("successfully completed listing.")
 
@@ -0,0 +1,193 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+const listingCacheFile = "listing_cache_%s.json"
+
+var (
+	useCachedListing    bool
+	cachedListingMaxAge time.Duration
+)
+
+// listingCacheHeader is the first line of a listing cache file.
+type listingCacheHeader struct {
+	Bucket  string    `json:"bucket"`
+	Created time.Time `json:"created"`
+}
+
+// listingCacheEntry is a single object version in a listing cache file.
+type listingCacheEntry struct {
+	Key            string    `json:"key"`
+	VersionID      string    `json:"versionId,omitempty"`
+	ETag           string    `json:"etag,omitempty"`
+	Size           int64     `json:"size"`
+	LastModified   time.Time `json:"lastModified"`
+	StorageClass   string    `json:"storageClass,omitempty"`
+	IsLatest       bool      `json:"isLatest,omitempty"`
+	IsDeleteMarker bool      `json:"isDeleteMarker,omitempty"`
+}
+
+func listingCachePath(bucket string) string {
+	return path.Join(dirPath, fmt.Sprintf(listingCacheFile, bucket))
+}
+
+// listBucket lists all object versions of bucket. With --use-cached-listing
+// a fresh cache in data-dir is served instead of listing the bucket again,
+// otherwise the bucket is listed and the cache refreshed for later commands.
+func listBucket(ctx context.Context, client *miniogo.Client, bucket string) <-chan miniogo.ObjectInfo {
+	objCh := make(chan miniogo.ObjectInfo, 1000)
+	go func() {
+		defer close(objCh)
+		if useCachedListing {
+			hdr, err := readListingCache(ctx, bucket, nil)
+			if err == nil && time.Since(hdr.Created) <= cachedListingMaxAge {
+				logMsg(fmt.Sprintf("using cached listing of %s from %s", bucket, hdr.Created.Format(time.RFC3339)))
+				if _, err = readListingCache(ctx, bucket, objCh); err != nil {
+					sendListingErr(ctx, objCh, err)
+				}
+				return
+			}
+			logDMsg("cached listing of "+bucket+" is missing or stale, listing again", err)
+		}
+		if err := listAndCache(ctx, client, bucket, objCh); err != nil {
+			sendListingErr(ctx, objCh, err)
+		}
+	}()
+	return objCh
+}
+
+// sendListingErr reports err on objCh unless the consumer is gone.
+func sendListingErr(ctx context.Context, objCh chan<- miniogo.ObjectInfo, err error) {
+	select {
+	case objCh <- miniogo.ObjectInfo{Err: err}:
+	case <-ctx.Done():
+	}
+}
+
+// readListingCache reads the cache header of bucket and, when objCh is
+// not nil, sends every cached entry to it.
+func readListingCache(ctx context.Context, bucket string, objCh chan<- miniogo.ObjectInfo) (hdr listingCacheHeader, err error) {
+	f, err := os.Open(listingCachePath(bucket))
+	if err != nil {
+		return hdr, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if !scanner.Scan() {
+		return hdr, fmt.Errorf("listing cache of %s is empty", bucket)
+	}
+	if err = json.Unmarshal(scanner.Bytes(), &hdr); err != nil {
+		return hdr, err
+	}
+	if hdr.Bucket != bucket {
+		return hdr, fmt.Errorf("listing cache is for bucket %s, expected %s", hdr.Bucket, bucket)
+	}
+	if objCh == nil {
+		return hdr, nil
+	}
+	for scanner.Scan() {
+		var e listingCacheEntry
+		if err = json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return hdr, err
+		}
+		select {
+		case <-ctx.Done():
+			return hdr, ctx.Err()
+		case objCh <- miniogo.ObjectInfo{
+			Key:            e.Key,
+			VersionID:      e.VersionID,
+			ETag:           e.ETag,
+			Size:           e.Size,
+			LastModified:   e.LastModified,
+			StorageClass:   e.StorageClass,
+			IsLatest:       e.IsLatest,
+			IsDeleteMarker: e.IsDeleteMarker,
+		}:
+		}
+	}
+	return hdr, scanner.Err()
+}
+
+// listAndCache lists bucket into objCh, the cache is only replaced once
+// the listing completed so that an interrupted listing is never reused.
+// When ctx is done before the consumer drained objCh the listing stops
+// and the partial cache file is removed.
+func listAndCache(ctx context.Context, client *miniogo.Client, bucket string, objCh chan<- miniogo.ObjectInfo) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cachePath := listingCachePath(bucket)
+	f, err := os.OpenFile(cachePath+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	if err = enc.Encode(listingCacheHeader{Bucket: bucket, Created: time.Now().UTC()}); err != nil {
+		return err
+	}
+	opts := miniogo.ListObjectsOptions{
+		WithVersions: true,
+		Recursive:    true,
+	}
+	for object := range client.ListObjects(ctx, bucket, opts) {
+		if object.Err != nil {
+			return object.Err
+		}
+		if err = enc.Encode(listingCacheEntry{
+			Key:            object.Key,
+			VersionID:      object.VersionID,
+			ETag:           object.ETag,
+			Size:           object.Size,
+			LastModified:   object.LastModified,
+			StorageClass:   object.StorageClass,
+			IsLatest:       object.IsLatest,
+			IsDeleteMarker: object.IsDeleteMarker,
+		}); err != nil {
+			return err
+		}
+		select {
+		case objCh <- object:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), cachePath)
+}
@@ -0,0 +1,132 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/signer"
+)
+
+// listingMarkerFile in data-dir records how far list got into the bucket,
+// list --resume continues the listing after it instead of starting over.
+const listingMarkerFile = "listing_marker.json"
+
+// listingMarkerInterval is how often the listing marker is rewritten.
+const listingMarkerInterval = 10 * time.Second
+
+// listingMarker is the content of the listing marker file. All versions
+// of the keys up to Key are written to version_listing.txt, which is
+// Offset bytes long at that point. The flags shaping the records are kept
+// so that a resumed listing writes the same kind.
+type listingMarker struct {
+	Bucket      string    `json:"bucket"`
+	Key         string    `json:"key"`
+	Offset      int64     `json:"offset"`
+	Extended    bool      `json:"extended"`
+	MinVersions int       `json:"minVersions"`
+	Updated     time.Time `json:"updated"`
+}
+
+func listingMarkerPath() string {
+	return path.Join(dirPath, listingMarkerFile)
+}
+
+// readListingMarker returns the listing marker of bucket for --resume.
+func readListingMarker(bucket string) (listingMarker, error) {
+	var m listingMarker
+	data, err := ioutil.ReadFile(listingMarkerPath())
+	if err != nil {
+		return m, fmt.Errorf("unable to read %s for --resume: %v", listingMarkerFile, err)
+	}
+	if err = json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("unable to parse %s: %v", listingMarkerFile, err)
+	}
+	if m.Bucket != bucket {
+		return m, fmt.Errorf("%s is a listing of %s, not of %s", listingMarkerFile, m.Bucket, bucket)
+	}
+	return m, nil
+}
+
+// write atomically replaces the listing marker file.
+func (m listingMarker) write() {
+	m.Updated = time.Now()
+	body, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		logMsg(fmt.Sprintf("unable to encode %s: %v", listingMarkerFile, err))
+		return
+	}
+	name := listingMarkerPath()
+	if err = ioutil.WriteFile(name+".tmp", append(body, '\n'), 0600); err == nil {
+		err = os.Rename(name+".tmp", name)
+	}
+	if err != nil {
+		logMsg(fmt.Sprintf("unable to write %s: %v", listingMarkerFile, err))
+	}
+}
+
+type listingMarkerKey struct{}
+
+// withListingMarker returns ctx continuing the versioned listings made
+// with it after all versions of key.
+func withListingMarker(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, listingMarkerKey{}, key)
+}
+
+// resumeListings returns rt starting the versioned listings of contexts
+// from withListingMarker after their marker. minio-go always starts a
+// listing at the beginning of the bucket and follows the markers of the
+// responses after that, so only its first request, the one without a
+// key-marker, is changed and signed again with the current value of creds.
+func resumeListings(rt http.RoundTripper, creds *credentials.Credentials) http.RoundTripper {
+	return &listingMarkerTransport{rt: rt, creds: creds}
+}
+
+type listingMarkerTransport struct {
+	rt    http.RoundTripper
+	creds *credentials.Credentials
+}
+
+func (t *listingMarkerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, ok := req.Context().Value(listingMarkerKey{}).(string)
+	if !ok || key == "" || req.Method != http.MethodGet {
+		return t.rt.RoundTrip(req)
+	}
+	query := req.URL.Query()
+	if _, ok = query["versions"]; !ok || query.Get("key-marker") != "" {
+		return t.rt.RoundTrip(req)
+	}
+	query.Set("key-marker", key)
+	r := req.Clone(req.Context())
+	r.URL.RawQuery = strings.Replace(query.Encode(), "+", "%20", -1)
+	v, err := t.creds.Get()
+	if err != nil {
+		return nil, err
+	}
+	region := signingRegion(req.Header.Get("Authorization"))
+	r.Header.Del("Authorization")
+	return t.rt.RoundTrip(signer.SignV4(*r, v.AccessKeyID, v.SecretAccessKey, v.SessionToken, region))
+}
@@ -0,0 +1,121 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+// maxListKeys is the most keys S3 returns in one listing page.
+const maxListKeys = 1000
+
+var listingFlags = []cli.Flag{
+	cli.IntFlag{
+		Name:  "max-keys",
+		Usage: "number of keys to request per listing page, at most 1000, 0 leaves it to the server",
+	},
+	cli.StringFlag{
+		Name:  "delimiter",
+		Usage: "only list the keys before the next delimiter under the prefix, for a shallow listing",
+	},
+	cli.StringFlag{
+		Name:  "start-after",
+		Usage: "only list the keys sorting after this key, to resume a listing mid-bucket",
+	},
+}
+
+var (
+	// listMaxKeys, listDelimiter and listStartAfter set by --max-keys,
+	// --delimiter and --start-after tune the listings of the source.
+	listMaxKeys    int
+	listDelimiter  string
+	listStartAfter string
+)
+
+// parseListFlags reads the listing flags of the command.
+func parseListFlags(cliCtx *cli.Context) error {
+	listMaxKeys = cliCtx.Int("max-keys")
+	listDelimiter, listStartAfter = cliCtx.String("delimiter"), cliCtx.String("start-after")
+	if listMaxKeys < 0 || listMaxKeys > maxListKeys {
+		return fmt.Errorf("--max-keys %d is out of range, expected 0 to %d", listMaxKeys, maxListKeys)
+	}
+	return nil
+}
+
+// errCachedListing is returned by list and move for --use-cached-listing
+// with any of the listing flags.
+var errCachedListing = fmt.Errorf("--use-cached-listing serves the whole cached listing, it cannot be combined with --max-keys, --delimiter or --start-after")
+
+// tunedListing reports whether any of the listing flags is set.
+func tunedListing() bool {
+	return listMaxKeys > 0 || listDelimiter != "" || listStartAfter != ""
+}
+
+// listObjects lists bucket with opts honouring --max-keys. With
+// --delimiter or --start-after the latest version of each key is paged
+// through with V1 listings instead, keys grouped under a common prefix by
+// the delimiter are left out.
+func listObjects(ctx context.Context, client *miniogo.Client, bucket string, opts miniogo.ListObjectsOptions) <-chan miniogo.ObjectInfo {
+	opts.MaxKeys = listMaxKeys
+	// The listings of minio-go cannot express a delimiter other than "/"
+	// or a key to start after.
+	if listDelimiter == "" && listStartAfter == "" {
+		return client.ListObjects(ctx, bucket, opts)
+	}
+	objCh := make(chan miniogo.ObjectInfo, 1)
+	go func() {
+		defer close(objCh)
+		core := miniogo.Core{Client: client}
+		marker := listStartAfter
+		for {
+			result, err := core.ListObjects(bucket, opts.Prefix, marker, listDelimiter, listMaxKeys)
+			if err != nil {
+				objCh <- miniogo.ObjectInfo{Err: err}
+				return
+			}
+			for _, object := range result.Contents {
+				object.IsLatest = true
+				select {
+				case objCh <- object:
+				case <-ctx.Done():
+					return
+				}
+			}
+			for _, prefix := range result.CommonPrefixes {
+				logDMsg("not listing below "+prefix.Prefix, nil)
+			}
+			if !result.IsTruncated {
+				return
+			}
+			// NextMarker is only returned with a delimiter.
+			next := result.NextMarker
+			if next == "" && len(result.Contents) > 0 {
+				next = result.Contents[len(result.Contents)-1].Key
+			}
+			if next == "" || next == marker {
+				objCh <- miniogo.ObjectInfo{Err: fmt.Errorf("listing of %s did not advance past %q", bucket, marker)}
+				return
+			}
+			marker = next
+		}
+	}()
+	return objCh
+}
@@ -0,0 +1,71 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+// lockFile in data-dir is held by the running command, two commands
+// writing to the same data-dir corrupt each other's output files.
+const lockFile = "moveobject.lock"
+
+// readOnlyCommands only read data-dir and may run next to another command.
+var readOnlyCommands = map[string]bool{
+	"check":    true,
+	"estimate": true,
+	"du":       true,
+}
+
+// runLock is kept open for the lifetime of the process, the advisory lock
+// is released by the kernel when the process exits however it exits.
+var runLock *os.File
+
+// lockDataDir takes the advisory lock on data-dir and records the PID and
+// start time of this run in it, it fails with the holder's when taken.
+func lockDataDir(command string) error {
+	if readOnlyCommands[command] || runLock != nil {
+		return nil
+	}
+	name := path.Join(dirPath, lockFile)
+	f, busy, err := lockFileExclusive(name)
+	if busy {
+		// The holder is not readable where the lock is exclusive.
+		by := "another run"
+		if holder, _ := ioutil.ReadFile(name); len(bytes.TrimSpace(holder)) > 0 {
+			by += " " + string(bytes.TrimSpace(holder))
+		}
+		return fmt.Errorf("%s is in use by %s, wait for it to finish or use another --data-dir", dirPath, by)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to lock %s: %v", name, err)
+	}
+	if err = f.Truncate(0); err == nil {
+		_, err = fmt.Fprintf(f, "pid %d, command %s, started %s\n", os.Getpid(), command, time.Now().Format(time.RFC3339))
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write %s: %v", name, err)
+	}
+	runLock = f
+	return nil
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+// +build !windows
+
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFileExclusive opens name and takes an advisory lock on it, busy is
+// true when another process holds it.
+func lockFileExclusive(name string) (f *os.File, busy bool, err error) {
+	if f, err = os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0600); err != nil {
+		return nil, false, err
+	}
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		return nil, err == syscall.EWOULDBLOCK, err
+	}
+	return f, false, nil
+}
@@ -0,0 +1,45 @@
+//go:build windows
+// +build windows
+
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// errorSharingViolation is returned by CreateFile when another process
+// has the file open.
+const errorSharingViolation syscall.Errno = 32
+
+// lockFileExclusive opens name without sharing it, which keeps any other
+// process from opening it until this one exits. busy is true when another
+// process has it open.
+func lockFileExclusive(name string) (f *os.File, busy bool, err error) {
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, false, err
+	}
+	h, err := syscall.CreateFile(p, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil,
+		syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return nil, err == errorSharingViolation, err
+	}
+	return os.NewFile(uintptr(h), name), false, nil
+}
@@ -19,8 +19,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/console"
 )
 
 var (
@@ -47,10 +49,226 @@ var allFlags = []cli.Flag{
 		Name:  "debug",
 		Usage: "enable debugging",
 	},
+	cli.BoolFlag{
+		Name:  "no-log-file",
+		Usage: "do not write the log of the run to moveobject.log in its run directory",
+	},
+	cli.StringFlag{
+		Name:  "log-max-size",
+		Usage: "size at which the log file of the run is rotated",
+		Value: "100MiB",
+	},
+	cli.IntFlag{
+		Name:  "log-keep",
+		Usage: "number of rotated log files kept",
+		Value: 5,
+	},
+	cli.StringFlag{
+		Name:  "syslog",
+		Usage: "also send log and debug lines to syslog, local or udp://host:port or tcp://host:port",
+	},
+	cli.StringFlag{
+		Name:  "syslog-tag",
+		Usage: "tag of the lines sent to syslog",
+		Value: "moveobject",
+	},
 	cli.StringFlag{
 		Name:  "data-dir",
 		Usage: "data directory",
 	},
+	cli.StringFlag{
+		Name:  "client-cert",
+		Usage: "client certificate file for mTLS",
+	},
+	cli.StringFlag{
+		Name:  "client-key",
+		Usage: "client private key file for mTLS",
+	},
+	cli.StringFlag{
+		Name:  "signature",
+		Usage: "signature version for the destination endpoint, v2 or v4",
+		Value: "v4",
+	},
+	cli.StringFlag{
+		Name:  "credentials",
+		Usage: "how the destination credentials are obtained, static keys, sts or sts:URL to assume renewed temporary credentials with the keys, iam for the instance, container or web identity role or file:PATH for an AWS credentials file re-read when it changes",
+		Value: credsStatic,
+	},
+	cli.DurationFlag{
+		Name:  "sts-duration",
+		Usage: "lifetime of the temporary credentials of sts, they are renewed before they expire",
+		Value: time.Hour,
+	},
+	cli.StringFlag{
+		Name:  "lookup",
+		Usage: "bucket addressing of the destination endpoint, auto, path or dns for virtual host style",
+		Value: "auto",
+	},
+	cli.IntFlag{
+		Name:  "max-idle-conns-per-host",
+		Usage: "idle connections kept open to each endpoint, raise with the concurrency",
+		Value: 16,
+	},
+	cli.DurationFlag{
+		Name:  "dial-timeout",
+		Usage: "timeout for establishing a connection",
+		Value: 30 * time.Second,
+	},
+	cli.DurationFlag{
+		Name:  "tls-handshake-timeout",
+		Usage: "timeout for the TLS handshake",
+		Value: 10 * time.Second,
+	},
+	cli.DurationFlag{
+		Name:  "response-header-timeout",
+		Usage: "timeout waiting for the response headers of a request e.g. 1m, disabled by default",
+	},
+	cli.DurationFlag{
+		Name:  "op-timeout",
+		Usage: "timeout for each individual request e.g. 5m, disabled by default",
+	},
+	cli.IntFlag{
+		Name:  "retries",
+		Usage: "number of times an object failing with a transient error e.g. a timeout or a 5xx response is retried before it is recorded as failed",
+		Value: 3,
+	},
+	cli.IntFlag{
+		Name:  "max-rps",
+		Usage: "maximum requests per second to the source and destination together, unlimited by default",
+	},
+	cli.BoolFlag{
+		Name:  "adaptive",
+		Usage: "halve the workers while the destination answers SlowDown or slows down and ramp them back up as it recovers",
+	},
+	cli.DurationFlag{
+		Name:  "adaptive-latency",
+		Usage: "p99 latency of the destination requests above which --adaptive reduces the workers",
+		Value: 2 * time.Second,
+	},
+	cli.BoolFlag{
+		Name:  "skip-preflight",
+		Usage: "skip probing the endpoints and buckets before the run",
+	},
+	cli.DurationFlag{
+		Name:  "wait-for-dest",
+		Usage: "keep retrying an unreachable destination for up to this long before the run e.g. 5m",
+	},
+	cli.BoolFlag{
+		Name:  "ignore-header",
+		Usage: "use listing, success and fail files generated from another bucket",
+	},
+	cli.BoolFlag{
+		Name:  "use-cached-listing",
+		Usage: "reuse the bucket listing cached in data directory instead of listing again",
+	},
+	cli.DurationFlag{
+		Name:  "cached-listing-max-age",
+		Usage: "maximum age of a cached listing before the bucket is listed again",
+		Value: 24 * time.Hour,
+	},
+	cli.DurationFlag{
+		Name:  "stats-interval",
+		Usage: "interval between throughput statistics log lines",
+		Value: 30 * time.Second,
+	},
+	cli.DurationFlag{
+		Name:  "status-interval",
+		Usage: "interval at which status.json in data directory is rewritten, 0 disables it",
+		Value: 10 * time.Second,
+	},
+	cli.IntFlag{
+		Name:  "flush-every",
+		Usage: "sync success and fail files and rewrite status.json and checkpoint.json every N processed objects, 0 disables it",
+		Value: 1000,
+	},
+	cli.DurationFlag{
+		Name:  "probe-interval",
+		Usage: "interval between read-after-write probes of the destination, disabled by default",
+	},
+	cli.StringFlag{
+		Name:  "notify-url",
+		Usage: "URL to POST a JSON run summary to when the run finishes or aborts",
+	},
+	cli.StringFlag{
+		Name:   "slack-webhook",
+		Usage:  "Slack incoming webhook URL to post run start, progress and outcome to",
+		EnvVar: EnvSlackWebhook,
+	},
+	cli.StringFlag{
+		Name:   "teams-webhook",
+		Usage:  "Microsoft Teams incoming webhook URL to post run start, progress and outcome to",
+		EnvVar: EnvTeamsWebhook,
+	},
+	cli.DurationFlag{
+		Name:  "chat-interval",
+		Usage: "interval between progress posts to the Slack and Teams webhooks, 0 disables them",
+		Value: time.Hour,
+	},
+	cli.StringFlag{
+		Name:  "smtp-server",
+		Usage: "SMTP server host:port to email the run summary and fail files through",
+	},
+	cli.StringFlag{
+		Name:  "smtp-user",
+		Usage: "SMTP user, the email is sent without authentication when empty",
+	},
+	cli.StringFlag{
+		Name:   "smtp-password",
+		Usage:  "SMTP password",
+		EnvVar: EnvSMTPPassword,
+	},
+	cli.StringFlag{
+		Name:  "mail-from",
+		Usage: "sender address of the run summary email",
+	},
+	cli.StringFlag{
+		Name:  "mail-to",
+		Usage: "comma separated recipients of the run summary email",
+	},
+	cli.StringFlag{
+		Name:   "endpoint, dst-endpoint",
+		Usage:  "MinIO endpoint",
+		EnvVar: EnvMinIOEndpoint,
+	},
+	cli.StringFlag{
+		Name:  "dst-endpoints",
+		Usage: "comma separated nodes or load balancers of the --endpoint cluster to spread connections across round-robin",
+	},
+	cli.StringFlag{
+		Name:   "access-key, dst-access-key",
+		Usage:  "MinIO access key",
+		EnvVar: EnvMinIOAccessKey,
+	},
+	cli.StringFlag{
+		Name:   "secret-key, dst-secret-key",
+		Usage:  "MinIO secret key",
+		EnvVar: EnvMinIOSecretKey,
+	},
+	cli.StringFlag{
+		Name:   "bucket",
+		Usage:  "bucket on MinIO",
+		EnvVar: EnvMinIOBucket,
+	},
+	cli.StringFlag{
+		Name:  "dst",
+		Usage: "mc alias and optional bucket e.g. myminio/mybucket to take the endpoint, credentials and bucket from",
+	},
+	cli.StringFlag{
+		Name:  "mc-config-dir",
+		Usage: "mc configuration directory to resolve aliases in (default: ~/.mc)",
+	},
+	cli.StringFlag{
+		Name:  "pprof-addr",
+		Usage: "address to serve net/http/pprof profiles on e.g. localhost:6060, disabled by default",
+	},
+	cli.IntFlag{
+		Name:  "limit",
+		Usage: "only process the first N objects of each run e.g. for a pilot batch, unlimited by default",
+	},
+	cli.StringFlag{
+		Name:  "report",
+		Usage: "write a run report with per prefix counts, failure reasons and throughput to the run directory, html or csv",
+	},
 }
 
 var subcommands = []cli.Command{
@@ -59,17 +277,30 @@ var subcommands = []cli.Command{
 	moveCmd,
 	copyCmd,
 	delCmd,
+	estimateCmd,
+	freezeCheckCmd,
+	retryCmd,
+	fixMetadataCmd,
+	cleanMarkersCmd,
+	pruneVersionsCmd,
+	undoCmd,
+	renameCmd,
+	exportCmd,
+	checkCmd,
+	compareCmd,
+	duCmd,
+	statusCmd,
 }
 
 func mainAction(ctx *cli.Context) error {
 	if !ctx.Args().Present() {
 		cli.ShowCommandHelp(ctx, "")
-		os.Exit(1)
+		os.Exit(exitConfig)
 	}
 	command := ctx.Args().First()
 	if command != "move" && command != "migrate" {
 		cli.ShowCommandHelp(ctx, "")
-		os.Exit(1)
+		os.Exit(exitConfig)
 	}
 	debugFlag = ctx.Bool("debug")
 	logFlag = ctx.Bool("log")
@@ -85,5 +316,9 @@ func main() {
 	app.Flags = []cli.Flag{}
 	app.Action = mainAction
 	app.Commands = subcommands
-	app.Run(os.Args)
+	if err := app.Run(os.Args); err != nil {
+		console.Errorln(err)
+		os.Exit(exitAborted)
+	}
+	os.Exit(exitCode)
 }
@@ -0,0 +1,120 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/minio/cli"
+)
+
+// mcAlias is an alias entry of the mc configuration file.
+type mcAlias struct {
+	URL       string `json:"url"`
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+	API       string `json:"api"`
+}
+
+// mcConfig is the mc configuration file, version 10 keeps the aliases
+// under "aliases", older versions under "hosts".
+type mcConfig struct {
+	Aliases map[string]mcAlias `json:"aliases"`
+	Hosts   map[string]mcAlias `json:"hosts"`
+}
+
+// loadMcAlias returns alias from config.json in configDir, ~/.mc when
+// empty.
+func loadMcAlias(configDir, alias string) (mcAlias, error) {
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return mcAlias{}, err
+		}
+		configDir = filepath.Join(home, ".mc")
+	}
+	name := filepath.Join(configDir, "config.json")
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return mcAlias{}, err
+	}
+	var config mcConfig
+	if err = json.Unmarshal(data, &config); err != nil {
+		return mcAlias{}, fmt.Errorf("unable to parse %s: %v", name, err)
+	}
+	if a, ok := config.Aliases[alias]; ok {
+		return a, nil
+	}
+	if a, ok := config.Hosts[alias]; ok {
+		return a, nil
+	}
+	return mcAlias{}, fmt.Errorf("alias %s not found in %s", alias, name)
+}
+
+// hasFlag reports whether the command defines the flag name.
+func hasFlag(ctx *cli.Context, name string) bool {
+	for _, f := range ctx.Command.Flags {
+		for _, n := range strings.Split(f.GetName(), ",") {
+			if strings.TrimSpace(n) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyMcAlias resolves the ALIAS[/BUCKET] value of flag and sets the
+// endpoint, credential, signature and bucket flags from it, prefixed with
+// prefix. Values from the alias take precedence over the environment.
+func applyMcAlias(ctx *cli.Context, flag, prefix string, buckets ...string) error {
+	target := ctx.String(flag)
+	if target == "" {
+		return nil
+	}
+	parts := strings.SplitN(target, "/", 2)
+	alias, err := loadMcAlias(ctx.String("mc-config-dir"), parts[0])
+	if err != nil {
+		return err
+	}
+	set := map[string]string{
+		prefix + "endpoint":   alias.URL,
+		prefix + "access-key": alias.AccessKey,
+		prefix + "secret-key": alias.SecretKey,
+	}
+	if strings.EqualFold(alias.API, "S3v2") {
+		set[prefix+"signature"] = "v2"
+	}
+	if len(parts) == 2 && parts[1] != "" {
+		for _, bucket := range buckets {
+			set[bucket] = parts[1]
+		}
+	}
+	for name, value := range set {
+		if !hasFlag(ctx, name) {
+			continue
+		}
+		if err = ctx.Set(name, value); err != nil {
+			return fmt.Errorf("unable to apply --%s %s: %v", flag, target, err)
+		}
+	}
+	return nil
+}
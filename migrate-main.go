@@ -17,45 +17,311 @@
 package main
 
 import (
-	"bufio"
 	"context"
-	"crypto/tls"
 	"fmt"
-	"net"
-	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/fatih/color"
 	"github.com/minio/cli"
 	miniogo "github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/minio/minio/pkg/console"
 )
 
+// routeFlags choose the destination bucket of each object among
+// --dst-bucket-1 to --dst-bucket-4.
+var routeFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "route",
+		Usage: "destination bucket routing, one of prefix (numbered prefix ranges), hash, round-robin or size",
+		Value: routePrefix,
+	},
+	cli.StringFlag{
+		Name:  "route-size-tiers",
+		Usage: "upper object sizes of the first three buckets with --route size",
+		Value: "1MiB,16MiB,256MiB",
+	},
+}
+
 var migrateFlags = []cli.Flag{
 	cli.IntFlag{
 		Name:  "skip, s",
 		Usage: "number of entries to skip from input file",
 		Value: 0,
 	},
+	cli.BoolFlag{
+		Name:  "resume",
+		Usage: "continue from the checkpoint of the previous run instead of reading the input file from the start",
+	},
 	cli.BoolFlag{
 		Name:  "fake",
 		Usage: "perform a fake migration",
 	},
+	cli.BoolFlag{
+		Name:  "skip-succeeded",
+		Usage: "skip entries already recorded in success files of previous runs",
+	},
+	cli.StringFlag{
+		Name:  "shard",
+		Usage: "only process shard i of N of the input e.g. 0/4, keys are partitioned by hash",
+	},
 }
+
+// sourceFlags configure the source endpoint and the destination buckets of
+// migrate.
+var sourceFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "src",
+		Usage: "mc alias and optional bucket e.g. srcalias/srcbucket to take the source endpoint, credentials and bucket from",
+	},
+	cli.StringFlag{
+		Name:   "src-endpoint",
+		Usage:  "source MinIO endpoint",
+		EnvVar: EnvMinIOSourceEndpoint,
+	},
+	cli.StringFlag{
+		Name:   "src-access-key",
+		Usage:  "source MinIO access key",
+		EnvVar: EnvMinIOSourceAccessKey,
+	},
+	cli.StringFlag{
+		Name:   "src-secret-key",
+		Usage:  "source MinIO secret key",
+		EnvVar: EnvMinIOSourceSecretKey,
+	},
+	cli.StringFlag{
+		Name:  "src-credentials",
+		Usage: "how the source credentials are obtained, static, sts or sts:URL, iam or file:PATH, see --credentials",
+		Value: credsStatic,
+	},
+	cli.StringFlag{
+		Name:   "src-bucket",
+		Usage:  "bucket on source MinIO",
+		EnvVar: EnvMinIOSourceBucket,
+	},
+	cli.StringFlag{
+		Name:   "dst-bucket-1",
+		Usage:  "destination bucket for prefixes 0 to 249",
+		EnvVar: EnvMinIODestBucket1,
+	},
+	cli.StringFlag{
+		Name:   "dst-bucket-2",
+		Usage:  "destination bucket for prefixes 250 to 499",
+		EnvVar: EnvMinIODestBucket2,
+	},
+	cli.StringFlag{
+		Name:   "dst-bucket-3",
+		Usage:  "destination bucket for prefixes 500 to 749",
+		EnvVar: EnvMinIODestBucket3,
+	},
+	cli.StringFlag{
+		Name:   "dst-bucket-4",
+		Usage:  "destination bucket for prefixes 750 to 999",
+		EnvVar: EnvMinIODestBucket4,
+	},
+}
+
+var migrateOnlyFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "version-map",
+		Usage: "record source to destination version ID mapping in version_map.txt",
+	},
+	cli.StringFlag{
+		Name:  "src-signature",
+		Usage: "signature version for the source endpoint, v2 or v4",
+		Value: "v4",
+	},
+	cli.StringFlag{
+		Name:  "src-lookup",
+		Usage: "bucket addressing of the source endpoint, auto, path or dns for virtual host style",
+		Value: "auto",
+	},
+	cli.StringFlag{
+		Name:  "src-max-inflight",
+		Usage: "maximum bytes in flight from the source endpoint e.g. 512MiB",
+	},
+	cli.StringFlag{
+		Name:  "dst-max-inflight",
+		Usage: "maximum bytes in flight to the destination endpoint e.g. 512MiB",
+	},
+	cli.DurationFlag{
+		Name:  "every",
+		Usage: "keep running and repeat the migration at this interval e.g. 15m",
+	},
+	cli.StringFlag{
+		Name:  "until",
+		Usage: "stop repeating the migration at this time in RFC3339 format",
+	},
+	cli.StringFlag{
+		Name:  "kafka-brokers",
+		Usage: "comma separated kafka brokers to consume object keys from instead of object_listing.txt",
+	},
+	cli.StringFlag{
+		Name:  "kafka-topic",
+		Usage: "kafka topic to consume object keys from",
+	},
+	cli.StringFlag{
+		Name:  "kafka-group",
+		Usage: "kafka consumer group, its committed offsets are the migration checkpoint",
+		Value: "moveobject",
+	},
+	cli.StringFlag{
+		Name:  "redis-addr",
+		Usage: "redis server address e.g. localhost:6379 for --redis-queue and --redis-results",
+	},
+	cli.StringFlag{
+		Name:  "redis-queue",
+		Usage: "redis list to pop object keys from instead of object_listing.txt, needs redis 6.2 or later",
+	},
+	cli.StringFlag{
+		Name:  "redis-processing",
+		Usage: "redis list holding the keys popped from --redis-queue until their object is done, defaults to the queue name with a :processing suffix",
+	},
+	cli.StringFlag{
+		Name:  "redis-results",
+		Usage: "redis list to push a JSON result for every object to",
+	},
+	cli.BoolFlag{
+		Name:  "skip-object-lock",
+		Usage: "do not apply the legal hold and retention of source objects to the migrated copies",
+	},
+	cli.BoolFlag{
+		Name:  "remove",
+		Usage: "remove objects the migrated source prefixes map to at the destination that no longer exist at the source after the migration",
+	},
+	cli.StringFlag{
+		Name:  "checksum",
+		Usage: "verify every object by reading it back from the destination and comparing its crc32c or sha256 checksum",
+	},
+	cli.BoolFlag{
+		Name:  "skip-existing",
+		Usage: "skip objects already at the destination with the same size",
+	},
+	cli.DurationFlag{
+		Name:  "stall-timeout",
+		Usage: "cancel and retry a transfer when no bytes have moved for this long e.g. 30s, disabled by default",
+	},
+	cli.IntFlag{
+		Name:  "stall-retries",
+		Usage: "number of times a stalled transfer is retried",
+		Value: 3,
+	},
+	cli.StringFlag{
+		Name:  "transform-cmd",
+		Usage: "program computing the destination bucket, key and metadata of each object, see README",
+	},
+	cli.BoolFlag{
+		Name:  "watch",
+		Usage: "keep migrating objects created in the source bucket as their notifications arrive instead of object_listing.txt",
+	},
+	cli.DurationFlag{
+		Name:  "watch-relist",
+		Usage: "interval at which --watch re-lists recently modified objects to catch missed notifications",
+		Value: 5 * time.Minute,
+	},
+	cli.DurationFlag{
+		Name:  "watch-overlap",
+		Usage: "how far each --watch re-list reaches back before the previous one",
+		Value: time.Minute,
+	},
+	cli.StringFlag{
+		Name:  "watch-since",
+		Usage: "time in RFC3339 format the first --watch re-list reaches back to e.g. 2021-03-01T22:00:00Z, by default when object_listing.txt was generated",
+	},
+	cli.StringFlag{
+		Name:  "max-bandwidth",
+		Usage: "bandwidth per second of all transfers together e.g. 500MiB, unlimited by default",
+	},
+	cli.StringFlag{
+		Name:  "bandwidth-schedule",
+		Usage: "bandwidth by time of day e.g. \"22:00-06:00=unlimited,06:00-22:00=20%\", percentages are of --max-bandwidth",
+	},
+	cli.BoolFlag{
+		Name:  "all-versions",
+		Usage: "migrate every version of each object oldest first, implies --version-map",
+	},
+	cli.StringSliceFlag{
+		Name:  "dst-kms-key",
+		Usage: "SSE-KMS key to encrypt the copies in a destination bucket with e.g. dstbucket1=key-b, a key without bucket applies to all others, can be repeated",
+	},
+	cli.StringFlag{
+		Name:  "dst-sse",
+		Usage: "encrypt the copies in the destination buckets without a --dst-kms-key with SSE-S3 when set to s3 or with SSE-KMS when set to kms:keyID",
+	},
+	cli.StringFlag{
+		Name:  "src-sse-c-key",
+		Usage: "base64 encoded 32 byte key to read SSE-C encrypted source objects with, their copies are encrypted with --dst-sse or --dst-kms-key",
+	},
+	cli.StringFlag{
+		Name:   "cse-key",
+		Usage:  "base64 encoded 32 byte master key to encrypt the objects with on this host before they are uploaded",
+		EnvVar: EnvCSEKey,
+	},
+	cli.StringFlag{
+		Name:   "src-buckets",
+		Usage:  "comma separated source buckets or bucket/prefix pairs to migrate in turn, listed instead of object_listing.txt",
+		EnvVar: EnvMinIOSourceBuckets,
+	},
+	cli.BoolFlag{
+		Name:  "list-source",
+		Usage: "migrate objects as they are listed from the source bucket instead of reading object_listing.txt",
+	},
+	cli.StringSliceFlag{
+		Name:  "list-prefix",
+		Usage: "only list objects under this prefix with --list-source, can be repeated",
+	},
+	cli.BoolFlag{
+		Name:  "resume-from-dest",
+		Usage: "list the destination first and skip objects already there, for resuming without success files",
+	},
+	cli.BoolFlag{
+		Name:  "forward-checksums",
+		Usage: "send the CRC32, CRC32C, SHA1 or SHA256 checksum of each source object with its upload, objects up to 5GiB are uploaded in one part for it, larger ones fail",
+	},
+	cli.StringFlag{
+		Name:  "dedup",
+		Usage: "list the destination first and report or skip objects whose content, by ETag and size, is already there under another key",
+	},
+	cli.StringFlag{
+		Name:  "part-size",
+		Usage: "part size of multipart uploads e.g. 16MiB, every running upload buffers one part, 128MiB by default",
+	},
+	cli.StringFlag{
+		Name:  "read-ahead",
+		Usage: "how far reading the source may run ahead of the upload of each object e.g. 4MiB, disabled by default",
+	},
+	cli.BoolFlag{
+		Name:  "presigned",
+		Usage: "move the data with plain HTTP requests to presigned URLs",
+	},
+	cli.DurationFlag{
+		Name:  "presign-expiry",
+		Usage: "how long the presigned URLs of --presigned are valid",
+		Value: time.Hour,
+	},
+	cli.StringFlag{
+		Name:  "transfer-proxy",
+		Usage: "HTTP proxy the --presigned requests go through e.g. http://proxy:3128, HTTP_PROXY and HTTPS_PROXY by default",
+	},
+	cli.StringFlag{
+		Name:  "acl",
+		Usage: "apply to reapply the ACL of source objects to their copies, report to only record the bucket policies they would need",
+	},
+}
+
 var migrateCmd = cli.Command{
 	Name:   "migrate",
 	Usage:  "copy objects from one MinIO to another",
 	Action: migrateAction,
-	Flags:  append(allFlags, migrateFlags...),
+	Flags:  append(append(append(append(append(allFlags, sourceFlags...), routeFlags...), migrateFlags...), migrateOnlyFlags...), append(append(append(storageClassFlags, overwriteFlags...), normalizeFlags...), listingFlags...)...),
 	CustomHelpTemplate: `NAME:
 	{{.HelpName}} - {{.Usage}}
 
 USAGE:
-	{{.HelpName}} [--skip, --fake]
+	{{.HelpName}} [FLAGS]
 
 FLAGS:
    {{range .VisibleFlags}}{{.}}
@@ -103,11 +369,196 @@ EXAMPLES:
    $ export MINIO_DEST_BUCKET_4=dstbucket4
    $ export MINIO_SOURCE_BUCKET=srcbucket
    $ moveobject migrate --data-dir /tmp/ --fake --log
+
+4. Migrate objects in "object_listing.txt" and record the source to destination version ID mapping
+   $ export MINIO_ENDPOINT=https://minio:9000
+   $ export MINIO_ACCESS_KEY=minio
+   $ export MINIO_SECRET_KEY=minio123
+   $ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+   $ export MINIO_SOURCE_ACCESS_KEY=minio
+   $ export MINIO_SOURCE_SECRET_KEY=minio123
+   $ export MINIO_DEST_BUCKET_1=dstbucket1
+   $ export MINIO_DEST_BUCKET_2=dstbucket2
+   $ export MINIO_DEST_BUCKET_3=dstbucket3
+   $ export MINIO_DEST_BUCKET_4=dstbucket4
+   $ export MINIO_SOURCE_BUCKET=srcbucket
+   $ moveobject migrate --data-dir /tmp/ --version-map
+
+5. Migrate objects in "object_listing.txt" keeping at most 256MiB in flight to the destination
+   $ export MINIO_ENDPOINT=https://minio:9000
+   $ export MINIO_ACCESS_KEY=minio
+   $ export MINIO_SECRET_KEY=minio123
+   $ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+   $ export MINIO_SOURCE_ACCESS_KEY=minio
+   $ export MINIO_SOURCE_SECRET_KEY=minio123
+   $ export MINIO_DEST_BUCKET_1=dstbucket1
+   $ export MINIO_DEST_BUCKET_2=dstbucket2
+   $ export MINIO_DEST_BUCKET_3=dstbucket3
+   $ export MINIO_DEST_BUCKET_4=dstbucket4
+   $ export MINIO_SOURCE_BUCKET=srcbucket
+   $ moveobject migrate --data-dir /tmp/ --dst-max-inflight 256MiB
+
+6. Repeat the migration every 15 minutes until the cut-over, skipping objects migrated by earlier runs
+   $ export MINIO_ENDPOINT=https://minio:9000
+   $ export MINIO_ACCESS_KEY=minio
+   $ export MINIO_SECRET_KEY=minio123
+   $ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+   $ export MINIO_SOURCE_ACCESS_KEY=minio
+   $ export MINIO_SOURCE_SECRET_KEY=minio123
+   $ export MINIO_DEST_BUCKET_1=dstbucket1
+   $ export MINIO_DEST_BUCKET_2=dstbucket2
+   $ export MINIO_DEST_BUCKET_3=dstbucket3
+   $ export MINIO_DEST_BUCKET_4=dstbucket4
+   $ export MINIO_SOURCE_BUCKET=srcbucket
+   $ moveobject migrate --data-dir /tmp/ --skip-succeeded --every 15m --until 2021-03-01T22:00:00Z
+
+7. Migrate the second of four disjoint shards of "object_listing.txt", run 0/4 to 3/4 on four hosts
+   $ export MINIO_ENDPOINT=https://minio:9000
+   $ export MINIO_ACCESS_KEY=minio
+   $ export MINIO_SECRET_KEY=minio123
+   $ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+   $ export MINIO_SOURCE_ACCESS_KEY=minio
+   $ export MINIO_SOURCE_SECRET_KEY=minio123
+   $ export MINIO_DEST_BUCKET_1=dstbucket1
+   $ export MINIO_DEST_BUCKET_2=dstbucket2
+   $ export MINIO_DEST_BUCKET_3=dstbucket3
+   $ export MINIO_DEST_BUCKET_4=dstbucket4
+   $ export MINIO_SOURCE_BUCKET=srcbucket
+   $ moveobject migrate --data-dir /tmp/ --shard 1/4
+
+8. Migrate object keys published to the kafka topic "migrate-keys" until interrupted
+   $ export MINIO_ENDPOINT=https://minio:9000
+   $ export MINIO_ACCESS_KEY=minio
+   $ export MINIO_SECRET_KEY=minio123
+   $ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+   $ export MINIO_SOURCE_ACCESS_KEY=minio
+   $ export MINIO_SOURCE_SECRET_KEY=minio123
+   $ export MINIO_DEST_BUCKET_1=dstbucket1
+   $ export MINIO_DEST_BUCKET_2=dstbucket2
+   $ export MINIO_DEST_BUCKET_3=dstbucket3
+   $ export MINIO_DEST_BUCKET_4=dstbucket4
+   $ export MINIO_SOURCE_BUCKET=srcbucket
+   $ moveobject migrate --data-dir /tmp/ --kafka-brokers kafka1:9092,kafka2:9092 --kafka-topic migrate-keys
+
+9. Migrate object keys pushed to the redis list "migrate-keys" and publish results to "migrate-results"
+   $ export MINIO_ENDPOINT=https://minio:9000
+   $ export MINIO_ACCESS_KEY=minio
+   $ export MINIO_SECRET_KEY=minio123
+   $ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+   $ export MINIO_SOURCE_ACCESS_KEY=minio
+   $ export MINIO_SOURCE_SECRET_KEY=minio123
+   $ export MINIO_DEST_BUCKET_1=dstbucket1
+   $ export MINIO_DEST_BUCKET_2=dstbucket2
+   $ export MINIO_DEST_BUCKET_3=dstbucket3
+   $ export MINIO_DEST_BUCKET_4=dstbucket4
+   $ export MINIO_SOURCE_BUCKET=srcbucket
+   $ moveobject migrate --data-dir /tmp/ --redis-addr redis:6379 --redis-queue migrate-keys --redis-results migrate-results
+
+10. Mirror the source every hour, removing destination objects that were deleted at the source
+   $ export MINIO_ENDPOINT=https://minio:9000
+   $ export MINIO_ACCESS_KEY=minio
+   $ export MINIO_SECRET_KEY=minio123
+   $ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+   $ export MINIO_SOURCE_ACCESS_KEY=minio
+   $ export MINIO_SOURCE_SECRET_KEY=minio123
+   $ export MINIO_DEST_BUCKET_1=dstbucket1
+   $ export MINIO_DEST_BUCKET_2=dstbucket2
+   $ export MINIO_DEST_BUCKET_3=dstbucket3
+   $ export MINIO_DEST_BUCKET_4=dstbucket4
+   $ export MINIO_SOURCE_BUCKET=srcbucket
+   $ moveobject migrate --data-dir /tmp/ --skip-succeeded --remove --every 1h
+
+11. Migrate objects as they are created at the source until interrupted, e.g. during cut-over
+   $ export MINIO_ENDPOINT=https://minio:9000
+   $ export MINIO_ACCESS_KEY=minio
+   $ export MINIO_SECRET_KEY=minio123
+   $ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+   $ export MINIO_SOURCE_ACCESS_KEY=minio
+   $ export MINIO_SOURCE_SECRET_KEY=minio123
+   $ export MINIO_DEST_BUCKET_1=dstbucket1
+   $ export MINIO_DEST_BUCKET_2=dstbucket2
+   $ export MINIO_DEST_BUCKET_3=dstbucket3
+   $ export MINIO_DEST_BUCKET_4=dstbucket4
+   $ export MINIO_SOURCE_BUCKET=srcbucket
+   $ moveobject migrate --data-dir /tmp/ --watch --watch-relist 10m --watch-overlap 2m
+
+12. Migrate at full speed at night and with 20% of 1GiB/s during the day, in the local time of this host
+   $ export MINIO_ENDPOINT=https://minio:9000
+   $ export MINIO_ACCESS_KEY=minio
+   $ export MINIO_SECRET_KEY=minio123
+   $ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+   $ export MINIO_SOURCE_ACCESS_KEY=minio
+   $ export MINIO_SOURCE_SECRET_KEY=minio123
+   $ export MINIO_DEST_BUCKET_1=dstbucket1
+   $ export MINIO_DEST_BUCKET_2=dstbucket2
+   $ export MINIO_DEST_BUCKET_3=dstbucket3
+   $ export MINIO_DEST_BUCKET_4=dstbucket4
+   $ export MINIO_SOURCE_BUCKET=srcbucket
+   $ moveobject migrate --data-dir /tmp/ --max-bandwidth 1GiB --bandwidth-schedule "22:00-06:00=unlimited,06:00-22:00=20%"
+
+13. Migrate the whole version history of every object to versioned buckets, recording the version IDs in version_map.txt
+   $ export MINIO_ENDPOINT=https://minio:9000
+   $ export MINIO_ACCESS_KEY=minio
+   $ export MINIO_SECRET_KEY=minio123
+   $ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+   $ export MINIO_SOURCE_ACCESS_KEY=minio
+   $ export MINIO_SOURCE_SECRET_KEY=minio123
+   $ export MINIO_DEST_BUCKET_1=dstbucket1
+   $ export MINIO_DEST_BUCKET_2=dstbucket2
+   $ export MINIO_DEST_BUCKET_3=dstbucket3
+   $ export MINIO_DEST_BUCKET_4=dstbucket4
+   $ export MINIO_SOURCE_BUCKET=srcbucket
+   $ moveobject migrate --data-dir /tmp/ --all-versions
+
+14. Re-encrypt SSE-KMS objects with key-b at the destination, and with key-c in dstbucket4
+   $ export MINIO_ENDPOINT=https://minio:9000
+   $ export MINIO_ACCESS_KEY=minio
+   $ export MINIO_SECRET_KEY=minio123
+   $ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+   $ export MINIO_SOURCE_ACCESS_KEY=minio
+   $ export MINIO_SOURCE_SECRET_KEY=minio123
+   $ export MINIO_DEST_BUCKET_1=dstbucket1
+   $ export MINIO_DEST_BUCKET_2=dstbucket2
+   $ export MINIO_DEST_BUCKET_3=dstbucket3
+   $ export MINIO_DEST_BUCKET_4=dstbucket4
+   $ export MINIO_SOURCE_BUCKET=srcbucket
+   $ moveobject migrate --data-dir /tmp/ --dst-kms-key key-b --dst-kms-key dstbucket4=key-c
+
+15. Drain all buckets of a tenant in one run, the outputs of each are written to /tmp/<bucket>/
+   $ export MINIO_ENDPOINT=https://minio:9000
+   $ export MINIO_ACCESS_KEY=minio
+   $ export MINIO_SECRET_KEY=minio123
+   $ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+   $ export MINIO_SOURCE_ACCESS_KEY=minio
+   $ export MINIO_SOURCE_SECRET_KEY=minio123
+   $ export MINIO_DEST_BUCKET_1=dstbucket1
+   $ export MINIO_DEST_BUCKET_2=dstbucket2
+   $ export MINIO_DEST_BUCKET_3=dstbucket3
+   $ export MINIO_DEST_BUCKET_4=dstbucket4
+   $ moveobject migrate --data-dir /tmp/ --src-buckets "tenant-photos,tenant-docs,shared/tenant1/"
+
+16. Migrate the objects under prefixes 42/ and 43/ while they are listed, without object_listing.txt
+   $ export MINIO_ENDPOINT=https://minio:9000
+   $ export MINIO_ACCESS_KEY=minio
+   $ export MINIO_SECRET_KEY=minio123
+   $ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+   $ export MINIO_SOURCE_ACCESS_KEY=minio
+   $ export MINIO_SOURCE_SECRET_KEY=minio123
+   $ export MINIO_DEST_BUCKET_1=dstbucket1
+   $ export MINIO_DEST_BUCKET_2=dstbucket2
+   $ export MINIO_DEST_BUCKET_3=dstbucket3
+   $ export MINIO_DEST_BUCKET_4=dstbucket4
+   $ export MINIO_SOURCE_BUCKET=srcbucket
+   $ moveobject migrate --data-dir /tmp/ --list-source --list-prefix 42/ --list-prefix 43/
 `,
 }
 var minioClient *miniogo.Client
 var minioSrcClient *miniogo.Client
 
+// sameCluster is set when source and destination endpoints are identical,
+// objects are then copied server side instead of download+upload.
+var sameCluster bool
+
 const (
 
 	// EnvMinIOEndpoint MinIO endpoint
@@ -130,6 +581,18 @@ const (
 	// EnvMinIOSourceBucket bucket on source MinIO.
 	EnvMinIOSourceBucket = "MINIO_SOURCE_BUCKET"
 
+	// EnvMinIOSourceBuckets buckets on source MinIO.
+	EnvMinIOSourceBuckets = "MINIO_SOURCE_BUCKETS"
+
+	// EnvSlackWebhook Slack incoming webhook URL.
+	EnvSlackWebhook = "MOVEOBJECT_SLACK_WEBHOOK"
+	// EnvTeamsWebhook Microsoft Teams incoming webhook URL.
+	EnvTeamsWebhook = "MOVEOBJECT_TEAMS_WEBHOOK"
+	// EnvSMTPPassword SMTP password of the run summary email.
+	EnvSMTPPassword = "MOVEOBJECT_SMTP_PASSWORD"
+	// EnvCSEKey client side encryption master key.
+	EnvCSEKey = "MOVEOBJECT_CSE_KEY"
+
 	// EnvMinIODestBucket1 bucket on dest MinIO.
 	EnvMinIODestBucket1 = "MINIO_DEST_BUCKET_1"
 
@@ -143,9 +606,121 @@ const (
 	EnvMinIODestBucket4 = "MINIO_DEST_BUCKET_4"
 )
 
+// destBucketFlags returns the destination bucket flags of the command.
+func destBucketFlags(ctx *cli.Context) []string {
+	if hasFlag(ctx, "dst-bucket-1") {
+		return []string{"dst-bucket-1", "dst-bucket-2", "dst-bucket-3", "dst-bucket-4"}
+	}
+	return []string{"bucket"}
+}
+
 func checkArgsAndInit(ctx *cli.Context) {
 	debugFlag = ctx.Bool("debug")
 	logFlag = ctx.Bool("log")
+	if err := openSyslog(ctx.String("syslog"), ctx.String("syslog-tag")); err != nil {
+		console.Fatalln(err)
+	}
+	opTimeout = ctx.Duration("op-timeout")
+	useCachedListing = ctx.Bool("use-cached-listing")
+	if err := parseListFlags(ctx); err != nil {
+		console.Fatalln(err)
+	}
+	ignoreRecordHeader = ctx.Bool("ignore-header")
+	cachedListingMaxAge = ctx.Duration("cached-listing-max-age")
+	probeInterval = ctx.Duration("probe-interval")
+	notifyURL = ctx.String("notify-url")
+	slackWebhook, teamsWebhook = ctx.String("slack-webhook"), ctx.String("teams-webhook")
+	chatInterval = ctx.Duration("chat-interval")
+	smtpServer, smtpUser, smtpPassword = ctx.String("smtp-server"), ctx.String("smtp-user"), ctx.String("smtp-password")
+	mailFrom, mailTo = ctx.String("mail-from"), parseMailTo(ctx.String("mail-to"))
+	if err := checkMailFlags(); err != nil {
+		console.Fatalln(err)
+	}
+	runFlags = collectFlags(ctx)
+	srcPrefix, dstPrefix = ctx.String("src-prefix"), ctx.String("dst-prefix")
+	if err := checkPrefixes(srcPrefix, dstPrefix); err != nil {
+		console.Fatalln(err)
+	}
+	storageClass = ctx.String("storage-class")
+	skipObjectLock = ctx.Bool("skip-object-lock")
+	var err error
+	if keyForm, err = parseKeyForm(ctx.String("normalize-keys")); err != nil {
+		console.Fatalln(err)
+	}
+	if hasFlag(ctx, "levels") {
+		stripLevels, stripFromDepth = ctx.Int("levels"), ctx.Int("from-depth")
+		if stripLevels < 1 || stripFromDepth < 0 {
+			console.Fatalln("--levels should be at least 1 and --from-depth should not be negative")
+		}
+		if remapPrefix() && (ctx.IsSet("levels") || ctx.IsSet("from-depth")) {
+			console.Fatalln("--src-prefix and --dst-prefix relocate the keys, they cannot be combined with --levels or --from-depth")
+		}
+	}
+	if name := ctx.String("prefix-file"); name != "" {
+		if remapPrefix() || ctx.IsSet("start") || ctx.IsSet("end") {
+			console.Fatalln("--prefix-file replaces the numbered prefixes, it cannot be combined with --start, --end, --src-prefix or --dst-prefix")
+		}
+		if filePrefixes, err = readPrefixFile(name); err != nil {
+			console.Fatalln(err)
+		}
+	}
+	if overwrite, err = parseOverwritePolicy(ctx); err != nil {
+		console.Fatalln(err)
+	}
+	if checksumAlgo, err = parseChecksum(ctx.String("checksum")); err != nil {
+		console.Fatalln(err)
+	}
+	if d := ctx.Duration("sts-duration"); d > 0 {
+		stsDuration = d
+	}
+	if forwardChecksums = ctx.Bool("forward-checksums"); forwardChecksums && ctx.String("signature") == "v2" {
+		console.Fatalln("--forward-checksums signs the checksums with signature v4, it cannot be combined with --signature v2")
+	}
+	// A destination alias bucket applies to all four buckets of migrate.
+	if err = applyMcAlias(ctx, "dst", "", destBucketFlags(ctx)...); err != nil {
+		console.Fatalln(err)
+	}
+	if err = applyMcAlias(ctx, "src", "src-", "src-bucket"); err != nil {
+		console.Fatalln(err)
+	}
+	if route, routeTiers, err = parseRoute(ctx.String("route"), ctx.String("route-size-tiers")); err != nil {
+		console.Fatalln(err)
+	}
+	if shardIndex, shardCount, err = parseShard(ctx.String("shard")); err != nil {
+		console.Fatalln(err)
+	}
+	if ctx.Duration("stats-interval") > 0 {
+		statsInterval = ctx.Duration("stats-interval")
+	}
+	statusInterval = ctx.Duration("status-interval")
+	if ctx.Int("flush-every") < 0 {
+		console.Fatalln(fmt.Errorf("--flush-every should not be negative"))
+	}
+	flushEvery = uint64(ctx.Int("flush-every"))
+
+	if ctx.Int("limit") < 0 {
+		console.Fatalln(fmt.Errorf("--limit should not be negative"))
+	}
+	runLimit = uint64(ctx.Int("limit"))
+	if retries = ctx.Int("retries"); retries < 0 {
+		console.Fatalln(fmt.Errorf("--retries should not be negative"))
+	}
+	if maxRPS = ctx.Int("max-rps"); maxRPS < 0 {
+		console.Fatalln(fmt.Errorf("--max-rps should not be negative"))
+	}
+	adaptive = ctx.Bool("adaptive")
+	if adaptiveLatency = ctx.Duration("adaptive-latency"); adaptive && adaptiveLatency <= 0 {
+		console.Fatalln(fmt.Errorf("--adaptive-latency should be greater than 0"))
+	}
+	startAdaptive()
+	switch reportFormat = ctx.String("report"); reportFormat {
+	case "", "html", "csv":
+	default:
+		console.Fatalln(fmt.Errorf("unknown --report %q, should be one of html or csv", reportFormat))
+	}
+	if addr := ctx.String("pprof-addr"); addr != "" {
+		startPprof(addr)
+	}
 
 	dirPath = ctx.String("data-dir")
 
@@ -153,7 +728,21 @@ func checkArgsAndInit(ctx *cli.Context) {
 		console.Fatalln(fmt.Errorf("path to working dir required, please set --data-dir flag"))
 		return
 	}
+	if err = lockDataDir(ctx.Command.Name); err != nil {
+		console.Fatalln(err)
+	}
+	if err = startRun(ctx.Command.Name); err != nil {
+		console.Fatalln(fmt.Errorf("unable to create the run directory: %v", err))
+	}
+	watchPauseSignals()
+	watchWorkersFile(dirPath)
+	if !ctx.Bool("no-log-file") {
+		if err = openRunLog(ctx.String("log-max-size"), ctx.Int("log-keep")); err != nil {
+			console.Fatalln(err)
+		}
+	}
 
+	initConsole()
 	console.SetColor("Request", color.New(color.FgCyan))
 	console.SetColor("Method", color.New(color.Bold, color.FgWhite))
 	console.SetColor("Host", color.New(color.Bold, color.FgGreen))
@@ -165,32 +754,35 @@ func checkArgsAndInit(ctx *cli.Context) {
 }
 
 func initMinioClients(ctx *cli.Context) error {
-	mURL := os.Getenv(EnvMinIOEndpoint)
+	mURL := ctx.String("endpoint")
 	if mURL == "" {
-		return fmt.Errorf("MINIO_ENDPOINT, MINIO_ACCESS_KEY, MINIO_SECRET_KEY and MINIO_BUCKET need to be set")
+		return fmt.Errorf("--endpoint, --access-key, --secret-key and --bucket or MINIO_ENDPOINT, MINIO_ACCESS_KEY, MINIO_SECRET_KEY and MINIO_BUCKET need to be set")
 	}
 	target, err := url.Parse(mURL)
 	if err != nil {
 		return fmt.Errorf("unable to parse input arg %s: %v", mURL, err)
 	}
 
-	accessKey := os.Getenv(EnvMinIOAccessKey)
-	secretKey := os.Getenv(EnvMinIOSecretKey)
-	minioDstBucket1 = os.Getenv(EnvMinIODestBucket1)
-	minioDstBucket2 = os.Getenv(EnvMinIODestBucket2)
-	minioDstBucket3 = os.Getenv(EnvMinIODestBucket3)
-	minioDstBucket4 = os.Getenv(EnvMinIODestBucket4)
+	accessKey := ctx.String("access-key")
+	secretKey := ctx.String("secret-key")
+	minioDstBucket1 = ctx.String("dst-bucket-1")
+	minioDstBucket2 = ctx.String("dst-bucket-2")
+	minioDstBucket3 = ctx.String("dst-bucket-3")
+	minioDstBucket4 = ctx.String("dst-bucket-4")
 
-	if accessKey == "" || secretKey == "" || minioDstBucket1 == "" || minioDstBucket2 == "" || minioDstBucket3 == "" || minioDstBucket4 == "" {
+	if needsKeys(ctx.String("credentials")) && (accessKey == "" || secretKey == "") || minioDstBucket1 == "" || minioDstBucket2 == "" || minioDstBucket3 == "" || minioDstBucket4 == "" {
 		console.Fatalln(fmt.Errorf("one or more of AccessKey:%s SecretKey: %s DestBucket1:%s DestBucket2:%s DestBucket3:%s DestBucket4:%s ", accessKey, secretKey, minioDstBucket1, minioDstBucket2, minioDstBucket3, minioDstBucket4), "are missing in MinIO configuration")
 	}
 
-	srcAccessKey := os.Getenv(EnvMinIOSourceAccessKey)
-	srcSecretKey := os.Getenv(EnvMinIOSourceSecretKey)
-	srcEndpoint := os.Getenv(EnvMinIOSourceEndpoint)
-	minioSrcBucket = os.Getenv(EnvMinIOSourceBucket)
+	srcAccessKey := ctx.String("src-access-key")
+	srcSecretKey := ctx.String("src-secret-key")
+	srcEndpoint := ctx.String("src-endpoint")
+	minioSrcBucket = ctx.String("src-bucket")
+	if len(srcBuckets) > 0 {
+		minioSrcBucket = srcBuckets[0].bucket
+	}
 
-	if srcAccessKey == "" || srcEndpoint == "" || srcSecretKey == "" || minioSrcBucket == "" {
+	if needsKeys(ctx.String("src-credentials")) && (srcAccessKey == "" || srcSecretKey == "") || srcEndpoint == "" || minioSrcBucket == "" {
 		console.Fatalln(fmt.Errorf("one or more of Source's AccessKey:%s SecretKey: %s Endpoint:%s Bucket:%s ", srcAccessKey, srcSecretKey, srcEndpoint, minioSrcBucket), "are missing in MinIO configuration")
 	}
 
@@ -198,40 +790,35 @@ func initMinioClients(ctx *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("unable to parse input arg %s: %v", srcEndpoint, err)
 	}
+	sameCluster = src.Scheme == target.Scheme && src.Host == target.Host
+	if sameCluster && forwardChecksums {
+		logMsg("source and destination endpoints are the same, streaming the objects to forward their checksums")
+	} else if sameCluster {
+		logMsg("source and destination endpoints are the same, using server side copy")
+	}
 
+	tr, err := newTransport(ctx)
+	if err != nil {
+		return err
+	}
+	if dstAddrs, err = parseEndpoints(ctx.String("dst-endpoints"), target); err != nil {
+		return err
+	}
+	spreadConnections(tr, target, dstAddrs)
+	creds, err := newCredentials(ctx.String("credentials"), mURL, accessKey, secretKey, ctx.String("signature"), tr)
+	if err != nil {
+		return err
+	}
+	lookup, err := newBucketLookup(ctx.String("lookup"))
+	if err != nil {
+		return err
+	}
 	options := miniogo.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
-		Secure: target.Scheme == "https",
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			DialContext: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			MaxIdleConns:          256,
-			MaxIdleConnsPerHost:   16,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 10 * time.Second,
-			TLSClientConfig: &tls.Config{
-				RootCAs: mustGetSystemCertPool(),
-				// Can't use SSLv3 because of POODLE and BEAST
-				// Can't use TLSv1.0 because of POODLE and BEAST using CBC cipher
-				// Can't use TLSv1.1 because of RC4 cipher usage
-				MinVersion:         tls.VersionTLS12,
-				NextProtos:         []string{"http/1.1"},
-				InsecureSkipVerify: ctx.GlobalBool("insecure"),
-			},
-			// Set this value so that the underlying transport round-tripper
-			// doesn't try to auto decode the body of objects with
-			// content-encoding set to `gzip`.
-			//
-			// Refer:
-			//    https://golang.org/src/net/http/transport.go?h=roundTrip#L1843
-			DisableCompression: true,
-		},
+		Creds:        creds,
+		Secure:       target.Scheme == "https",
+		Transport:    sendChecksums(observeDestination(limitRequests(tr)), creds),
 		Region:       "us-east-1",
-		BucketLookup: 0,
+		BucketLookup: lookup,
 	}
 
 	minioClient, err = miniogo.New(target.Host, &options)
@@ -239,66 +826,290 @@ func initMinioClients(ctx *cli.Context) error {
 		console.Fatalln(err)
 	}
 
+	srcTr, err := newTransport(ctx)
+	if err != nil {
+		return err
+	}
+	srcCreds, err := newCredentials(ctx.String("src-credentials"), srcEndpoint, srcAccessKey, srcSecretKey, ctx.String("src-signature"), srcTr)
+	if err != nil {
+		return err
+	}
+	srcLookup, err := newBucketLookup(ctx.String("src-lookup"))
+	if err != nil {
+		return err
+	}
 	srcOptions := miniogo.Options{
-		Creds:  credentials.NewStaticV4(srcAccessKey, srcSecretKey, ""),
-		Secure: src.Scheme == "https",
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			DialContext: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			MaxIdleConns:          256,
-			MaxIdleConnsPerHost:   16,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 10 * time.Second,
-			TLSClientConfig: &tls.Config{
-				RootCAs:            mustGetSystemCertPool(),
-				MinVersion:         tls.VersionTLS12,
-				NextProtos:         []string{"http/1.1"},
-				InsecureSkipVerify: ctx.GlobalBool("insecure"),
-			},
-			DisableCompression: true,
-		},
+		Creds:        srcCreds,
+		Secure:       src.Scheme == "https",
+		Transport:    captureChecksums(limitRequests(srcTr)),
 		Region:       "us-east-1",
-		BucketLookup: 0,
+		BucketLookup: srcLookup,
 	}
 
 	minioSrcClient, err = miniogo.New(src.Host, &srcOptions)
 	if err != nil {
 		console.Fatalln(err)
 	}
+	runPreflight(ctx, true)
 	return nil
 }
 
 func migrateAction(cliCtx *cli.Context) error {
 	checkArgsAndInit(cliCtx)
-	ctx := context.Background()
+	var err error
+	if srcBuckets, err = parseSourceBuckets(cliCtx.String("src-buckets")); err != nil {
+		console.Fatalln(err)
+	}
+	if len(srcBuckets) > 0 && (cliCtx.Int("skip") > 0 || cliCtx.Bool("remove") || cliCtx.Bool("watch") || cliCtx.String("kafka-brokers") != "" || cliCtx.String("redis-queue") != "") {
+		console.Fatalln("--src-buckets lists the source buckets itself, it cannot be combined with --skip, --remove, --watch, --kafka-brokers or --redis-queue")
+	}
+	if cliCtx.String("redis-addr") == "" && (cliCtx.String("redis-queue") != "" || cliCtx.String("redis-results") != "") {
+		console.Fatalln("--redis-queue and --redis-results need --redis-addr")
+	}
+	listSource, listPrefixes = cliCtx.Bool("list-source"), cliCtx.StringSlice("list-prefix")
+	resumeFromDest = cliCtx.Bool("resume-from-dest")
+	if resumeFromDest && cliCtx.String("transform-cmd") != "" {
+		console.Fatalln("--resume-from-dest cannot tell the destination keys chosen by --transform-cmd, use --skip-existing instead")
+	}
+	if resumeFromDest && (cliCtx.Bool("watch") || cliCtx.String("kafka-brokers") != "" || cliCtx.String("redis-queue") != "") {
+		console.Fatalln("--resume-from-dest filters object_listing.txt or a listed source, it cannot be combined with --watch, --kafka-brokers or --redis-queue")
+	}
+	if len(listPrefixes) > 0 && !listSource {
+		console.Fatalln("--list-prefix needs --list-source")
+	}
+	if tunedListing() && !listSource && len(srcBuckets) == 0 {
+		console.Fatalln("--max-keys, --delimiter and --start-after tune the listing of --list-source or --src-buckets")
+	}
+	if listSource && (len(srcBuckets) > 0 || cliCtx.Int("skip") > 0 || cliCtx.Bool("watch") || cliCtx.String("kafka-brokers") != "" || cliCtx.String("redis-queue") != "") {
+		console.Fatalln("--list-source lists the source bucket itself, it cannot be combined with --src-buckets, --skip, --watch, --kafka-brokers or --redis-queue")
+	}
 	logMsg("Init minio client..")
 	if err := initMinioClients(cliCtx); err != nil {
 		logDMsg("Unable to  initialize MinIO client, exiting...%w", err)
 		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
 		console.Fatalln(err)
 	}
+	allVersions = cliCtx.Bool("all-versions")
+	versionMap = cliCtx.Bool("version-map") || allVersions
+	mirrorRemove = cliCtx.Bool("remove")
+	skipExisting = cliCtx.Bool("skip-existing")
+	switch dedupMode = cliCtx.String("dedup"); dedupMode {
+	case "", dedupReport, dedupSkip:
+	default:
+		console.Fatalln(fmt.Errorf("unknown --dedup %q, should be one of report or skip", dedupMode))
+	}
+	stallTimeout = cliCtx.Duration("stall-timeout")
+	stallRetries = cliCtx.Int("stall-retries")
+	if stallTimeout != 0 && stallTimeout < time.Second {
+		console.Fatalln(fmt.Errorf("--stall-timeout should be at least 1s"))
+	}
+	if mirrorRemove && (shardCount > 0 || cliCtx.String("kafka-brokers") != "" || cliCtx.String("redis-queue") != "") {
+		console.Fatalln("--remove needs the whole source, it cannot be combined with --shard, --kafka-brokers or --redis-queue")
+	}
+	if mirrorRemove && cliCtx.String("transform-cmd") != "" && removalPrefixes()[0] != "" {
+		console.Fatalln("--remove cannot tell where --transform-cmd moves the objects of --src-prefix, --list-prefix or --prefix-file")
+	}
+	if cliCtx.Bool("watch") && (mirrorRemove || cliCtx.IsSet("every") || cliCtx.String("kafka-brokers") != "" || cliCtx.String("redis-queue") != "") {
+		console.Fatalln("--watch runs until interrupted, it cannot be combined with --remove, --every, --kafka-brokers or --redis-queue")
+	}
+	if allVersions && (skipExisting || dedupMode == dedupSkip || overwrite != overwriteAlways || route == routeRoundRobin || route == routeSize) {
+		console.Fatalln("--all-versions writes every version in order, it cannot be combined with --skip-existing, --dedup skip, an overwrite policy or the round-robin and size routes")
+	}
+	if s := cliCtx.String("max-bandwidth"); s != "" {
+		if maxBandwidth, err = parseBandwidth(s); err != nil {
+			console.Fatalln(fmt.Errorf("invalid --max-bandwidth: %v", err))
+		}
+	}
+	if bandwidthSchedule, err = parseBandwidthSchedule(cliCtx.String("bandwidth-schedule")); err != nil {
+		console.Fatalln(err)
+	}
+	if partSize, err = parsePartSize(cliCtx.String("part-size")); err != nil {
+		console.Fatalln(fmt.Errorf("invalid --part-size: %v", err))
+	}
+	if s := cliCtx.String("read-ahead"); s != "" {
+		n, err := humanize.ParseBytes(s)
+		if err != nil || n > humanize.GiByte {
+			console.Fatalln(fmt.Errorf("invalid --read-ahead %q, should be a size up to 1GiB", s))
+		}
+		readAhead = int(n)
+	}
+	switch aclMode = cliCtx.String("acl"); aclMode {
+	case "", aclApply, aclReport:
+	default:
+		console.Fatalln(fmt.Errorf("unknown --acl %q, should be one of apply or report", aclMode))
+	}
+	if dstKMSKeys, err = parseKMSKeys(cliCtx.StringSlice("dst-kms-key")); err != nil {
+		console.Fatalln(err)
+	}
+	if cseKey, err = parseCSEKey(cliCtx.String("cse-key")); err != nil {
+		console.Fatalln(err)
+	}
+	if cseKey != nil && forwardChecksums {
+		console.Fatalln("--cse-key uploads other data than the source, the checksums of the source cannot be forwarded")
+	}
+	if err = parseEncryption(cliCtx); err != nil {
+		console.Fatalln(err)
+	}
+	if srcSSEC != nil && dstSSE == nil && dstKMSKeys[""] == nil {
+		console.Fatalln("--src-sse-c-key needs --dst-sse or a --dst-kms-key without bucket, the copies of SSE-C objects would be stored unencrypted")
+	}
+	if presigned = cliCtx.Bool("presigned"); presigned {
+		if len(dstKMSKeys) > 0 || dstSSE != nil || srcSSEC != nil || aclMode == aclApply || cseKey != nil || forwardChecksums {
+			console.Fatalln("--presigned only signs the URLs, it cannot be combined with --dst-kms-key, --dst-sse, --src-sse-c-key, --acl apply, --cse-key or --forward-checksums")
+		}
+		if err = initPresigned(cliCtx); err != nil {
+			console.Fatalln(err)
+		}
+	} else if cliCtx.String("transfer-proxy") != "" {
+		console.Fatalln("--transfer-proxy needs --presigned")
+	}
+	if srcInflight, err = newInflightLimiter(cliCtx.String("src-max-inflight")); err != nil {
+		console.Fatalln(err)
+	}
+	if dstInflight, err = newInflightLimiter(cliCtx.String("dst-max-inflight")); err != nil {
+		console.Fatalln(err)
+	}
+	if command := cliCtx.String("transform-cmd"); command != "" {
+		if transformer, err = startTransform(command); err != nil {
+			console.Fatalln(err)
+		}
+		defer transformer.close()
+	}
+	initRedis(cliCtx)
+	return runScheduled(cliCtx, func() error {
+		return migrateSources(cliCtx)
+	})
+}
+
+// migrateOnce migrates all the objects in the listing once.
+func migrateOnce(cliCtx *cli.Context) (err error) {
+	ctx := context.Background()
+	resetLimit()
 	migrationState = newMigrationState(ctx)
+	start := time.Now()
+	defer func() { reportRun("migrate", migrationState, start, err) }()
+	if err = openConflictReport(conflictMigFile); err != nil {
+		return err
+	}
+	defer conflicts.close()
+	if err = openACLReport(); err != nil {
+		return err
+	}
+	defer acls.close()
+	if err = openReprotectReport(); err != nil {
+		return err
+	}
+	defer reprotected.close()
+	if err = loadContentIndex(ctx); err != nil {
+		return err
+	}
+	defer dedup.close()
 	migrationState.init(ctx)
+	stopProbe := startProbe(ctx, minioClient, minioDstBucket1)
+	// Every path out waits for the workers and then writes the checkpoint
+	// one last time, --every and --src-buckets run the next pass after.
+	defer func() {
+		migrationState.finish(ctx)
+		stopCheckpoint()
+		stopProbe()
+	}()
 	skip := cliCtx.Int("skip")
 	dryRun = cliCtx.Bool("fake")
+	if cliCtx.String("kafka-brokers") != "" {
+		if err = queueFromKafka(ctx, cliCtx); err != nil {
+			logDMsg("error consuming from kafka", err)
+			return err
+		}
+		migrationState.finish(ctx)
+		stopProbe()
+		logMsg("successfully completed migration.")
+		return nil
+	}
+	if cliCtx.Bool("watch") {
+		if err = queueFromWatch(ctx, cliCtx); err != nil {
+			logDMsg("error watching the source bucket", err)
+			return err
+		}
+		migrationState.finish(ctx)
+		stopProbe()
+		logMsg("successfully completed migration.")
+		return nil
+	}
+	if cliCtx.String("redis-queue") != "" {
+		if err = queueFromRedis(ctx, cliCtx); err != nil {
+			logDMsg("error consuming from redis", err)
+			return err
+		}
+		migrationState.finish(ctx)
+		stopProbe()
+		logMsg("successfully completed migration.")
+		return nil
+	}
+	var succeeded map[string]struct{}
+	if cliCtx.Bool("skip-succeeded") {
+		if succeeded, err = loadSucceeded(successMigFile); err != nil {
+			logDMsg("could not load "+successMigFile, err)
+			return err
+		}
+	}
+
+	if err = loadPresentKeys(ctx); err != nil {
+		return err
+	}
+	if sources := listedSources(); len(sources) > 0 {
+		for _, src := range sources {
+			if err = queueFromBucket(ctx, src, succeeded); err != nil {
+				logDMsg("error listing source bucket "+src.String(), err)
+				return err
+			}
+		}
+		migrationState.finish(ctx)
+		stopProbe()
+		if mirrorRemove {
+			if err = removeExtraneous(ctx); err != nil {
+				logDMsg("error removing extraneous destination objects", err)
+				return err
+			}
+		}
+		logMsg("successfully completed migration.")
+		return nil
+	}
 
 	file, err := os.Open(path.Join(dirPath, objListFile))
 	if err != nil {
 		logDMsg(fmt.Sprintf("could not open file :%s ", objListFile), err)
 		return err
 	}
+	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
+	scanner := newRecordScanner(file)
+	if err := scanner.checkHeader(objListFile); err != nil {
+		return err
+	}
+	if err := startCheckpoint(cliCtx, scanner, objListFile); err != nil {
+		return err
+	}
+	for checkpoint.scan(scanner) {
 		o := scanner.Text()
 		if skip > 0 {
 			skip--
 			continue
 		}
+		if !inShard(o) {
+			continue
+		}
+		if _, ok := succeeded[o]; ok {
+			logDMsg(fmt.Sprintf("skipping %s, already succeeded", o), nil)
+			continue
+		}
+		if presentAtDestination(o) {
+			logDMsg(fmt.Sprintf("skipping %s, already at the destination", o), nil)
+			continue
+		}
+		if !admit() {
+			break
+		}
+		checkpoint.queue(o)
 		migrationState.queueUploadTask(o)
 		logDMsg(fmt.Sprintf("adding %s to migration queue", o), nil)
 	}
@@ -307,6 +1118,14 @@ func migrateAction(cliCtx *cli.Context) error {
 		return err
 	}
 	migrationState.finish(ctx)
+	stopCheckpoint()
+	stopProbe()
+	if mirrorRemove {
+		if err = removeExtraneous(ctx); err != nil {
+			logDMsg("error removing extraneous destination objects", err)
+			return err
+		}
+	}
 	logMsg("successfully completed migration.")
 
 	return nil
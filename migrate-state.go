@@ -5,10 +5,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
 	"os"
-	"path"
 	"runtime"
-	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -18,33 +19,67 @@ import (
 )
 
 const (
-	versionListFile   = "version_listing.txt"
-	objListFile       = "object_listing.txt"
-	failMigFile       = "migration_fails.txt"
-	failMoveFile      = "move_fails.txt"
-	failCopyFile      = "copy_fails.txt"
-	failDeleteFile    = "delete_fails.txt"
-	successMigFile    = "migration_success.txt"
-	successMoveFile   = "move_success.txt"
-	successCopyFile   = "copy_success.txt"
-	successDeleteFile = "delete_success.txt"
+	versionListFile          = "version_listing.txt"
+	objListFile              = "object_listing.txt"
+	failMigFile              = "migration_fails.txt"
+	failMoveFile             = "move_fails.txt"
+	failCopyFile             = "copy_fails.txt"
+	failDeleteFile           = "delete_fails.txt"
+	failFixMetaFile          = "fix_metadata_fails.txt"
+	failCleanMarkersFile     = "clean_markers_fails.txt"
+	failPruneVersionsFile    = "prune_versions_fails.txt"
+	failUndoFile             = "undo_fails.txt"
+	failRenameFile           = "rename_fails.txt"
+	successMigFile           = "migration_success.txt"
+	successMoveFile          = "move_success.txt"
+	successCopyFile          = "copy_success.txt"
+	successDeleteFile        = "delete_success.txt"
+	successFixMetaFile       = "fix_metadata_success.txt"
+	successCleanMarkersFile  = "clean_markers_success.txt"
+	successPruneVersionsFile = "prune_versions_success.txt"
+	successUndoFile          = "undo_success.txt"
+	successRenameFile        = "rename_success.txt"
+	versionMapFile           = "version_map.txt"
 )
 
 var dryRun bool
 
+// versionMap when set records the source version ID to destination
+// version ID mapping of every migrated object in versionMapFile.
+var versionMap bool
+
 type migrateState struct {
 	objectCh  chan string
 	failedCh  chan string
-	successCh chan string
+	successCh chan []string
+	versionCh chan []string
 	count     uint64
 	failCnt   uint64
+	bytes     uint64
 	wg        sync.WaitGroup
+
+	// pending tracks queued objects not yet processed and recorded.
+	pending sync.WaitGroup
+
+	// versionDone is closed once the version map is written.
+	versionDone chan struct{}
+	// writerDone is closed once the success and fail records are written.
+	writerDone chan struct{}
+	finishOnce sync.Once
+
+	stopProgress func()
 }
 
 func (m *migrateState) queueUploadTask(obj string) {
+	m.pending.Add(1)
 	m.objectCh <- obj
 }
 
+// drain waits until every queued object has been processed and recorded.
+func (m *migrateState) drain() {
+	m.pending.Wait()
+}
+
 var (
 	migrationState      *migrateState
 	migrationConcurrent = 100
@@ -55,9 +90,12 @@ func newMigrationState(ctx context.Context) *migrateState {
 		migrationConcurrent = runtime.GOMAXPROCS(0)
 	}
 	ms := &migrateState{
-		objectCh:  make(chan string, migrationConcurrent),
-		failedCh:  make(chan string, migrationConcurrent),
-		successCh: make(chan string, migrationConcurrent),
+		objectCh:    make(chan string, migrationConcurrent),
+		failedCh:    make(chan string, migrationConcurrent),
+		successCh:   make(chan []string, migrationConcurrent),
+		versionCh:   make(chan []string, migrationConcurrent),
+		versionDone: make(chan struct{}),
+		writerDone:  make(chan struct{}),
 	}
 
 	return ms
@@ -83,13 +121,31 @@ func (m *migrateState) getFailCount() uint64 {
 	return atomic.LoadUint64(&m.failCnt)
 }
 
-// addWorker creates a new worker to process tasks
-func (m *migrateState) addWorker(ctx context.Context) {
+// Increase bytes processed
+func (m *migrateState) addBytes(n int64) {
+	atomic.AddUint64(&m.bytes, uint64(n))
+}
+
+// Get total bytes processed
+func (m *migrateState) getBytes() uint64 {
+	return atomic.LoadUint64(&m.bytes)
+}
+
+// Get number of objects waiting for a worker
+func (m *migrateState) queueDepth() int {
+	return len(m.objectCh)
+}
+
+// addWorker creates worker id to process tasks
+func (m *migrateState) addWorker(ctx context.Context, id int) {
 	m.wg.Add(1)
 	// Add a new worker.
 	go func() {
 		defer m.wg.Done()
 		for {
+			if !intake.wait(ctx, id) {
+				return
+			}
 			select {
 			case <-ctx.Done():
 				return
@@ -98,38 +154,58 @@ func (m *migrateState) addWorker(ctx context.Context) {
 					return
 				}
 				logDMsg(fmt.Sprintf("Migrating...%s", obj), nil)
-				if err := migrateObject(ctx, obj); err != nil {
+				dest, err := migrateObject(ctx, obj)
+				publishResult(obj, err)
+				if err != nil {
 					m.incFailCount()
 					logMsg(fmt.Sprintf("error migrating object %s: %s", obj, err))
+					countFailure(failureReason(err))
+					noteError(obj, err)
 					m.failedCh <- obj
 					continue
 				}
-				m.successCh <- obj
+				m.successCh <- migrateRecord(dest, obj)
 				m.incCount()
 			}
 		}
 	}()
 }
+
+// finish waits for the queued objects to be processed, only its first
+// call does, so that it can be deferred for the error paths as well.
 func (m *migrateState) finish(ctx context.Context) {
-	time.Sleep(100 * time.Millisecond)
-	close(m.objectCh)
-	m.wg.Wait() // wait on workers to finish
-	close(m.failedCh)
-	close(m.successCh)
+	m.finishOnce.Do(func() {
+		time.Sleep(100 * time.Millisecond)
+		close(m.objectCh)
+		m.wg.Wait() // wait on workers to finish
+		m.stopProgress()
+		close(m.failedCh)
+		close(m.successCh)
+		close(m.versionCh)
+		if versionMap {
+			<-m.versionDone
+		}
+		<-m.writerDone
 
-	if !dryRun {
-		logMsg(fmt.Sprintf("Migrated %d objects, %d failures", m.getCount(), m.getFailCount()))
-	}
+		if !dryRun {
+			logMsg(fmt.Sprintf("Migrated %d objects, %d failures", m.getCount(), m.getFailCount()))
+		}
+	})
 }
 func (m *migrateState) init(ctx context.Context) {
 	if m == nil {
 		return
 	}
+	m.stopProgress = startProgress("Migrating", m)
 	for i := 0; i < migrationConcurrent; i++ {
-		m.addWorker(ctx)
+		m.addWorker(ctx, i)
+	}
+	if versionMap {
+		go m.writeVersionMap(ctx)
 	}
 	go func() {
-		f, err := os.OpenFile(path.Join(dirPath, failMigFile+time.Now().Format(".01-02-2006-15-04-05")), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		defer close(m.writerDone)
+		f, err := createRecordFile(failMigFile)
 		if err != nil {
 			logDMsg("could not create + failMigFile", err)
 			return
@@ -138,7 +214,7 @@ func (m *migrateState) init(ctx context.Context) {
 		defer fwriter.Flush()
 		defer f.Close()
 
-		s, err := os.OpenFile(path.Join(dirPath, successMigFile+time.Now().Format(".01-02-2006-15-04-05")), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		s, err := createRecordFile(successMigFile)
 		if err != nil {
 			logDMsg("could not create "+successMigFile, err)
 			return
@@ -147,80 +223,481 @@ func (m *migrateState) init(ctx context.Context) {
 		defer swriter.Flush()
 		defer s.Close()
 
-		for {
+		// Write until both channels are closed, records may still be
+		// buffered on one when the other is closed.
+		failedCh, successCh := m.failedCh, m.successCh
+		for failedCh != nil || successCh != nil {
 			select {
 			case <-ctx.Done():
 				return
-			case obj, ok := <-m.failedCh:
+			case obj, ok := <-failedCh:
 				if !ok {
-					return
+					failedCh = nil
+					continue
 				}
-				if _, err := f.WriteString(obj + "\n"); err != nil {
+				if err := writeRecord(f, obj); err != nil {
 					logMsg(fmt.Sprintf("Error writing to migration_fails.txt for "+obj, err))
-					os.Exit(1)
+					os.Exit(exitAborted)
 				}
-			case obj, ok := <-m.successCh:
+				m.recorded(obj)
+			case rec, ok := <-successCh:
 				if !ok {
-					return
+					successCh = nil
+					continue
 				}
-				if _, err := s.WriteString(obj + "\n"); err != nil {
-					logMsg(fmt.Sprintf("Error writing to migration_success.txt for "+obj, err))
-					os.Exit(1)
+				if err := writeRecord(s, rec...); err != nil {
+					logMsg(fmt.Sprintf("Error writing to migration_success.txt for %s: %s", rec[len(rec)-1], err))
+					os.Exit(exitAborted)
 				}
+				m.recorded(rec[len(rec)-1])
 			}
 		}
 	}()
 }
 
-func migrateObject(ctx context.Context, object string) error {
-	if !patternMatch(object) {
-		return errors.New("Object doesn't match the expected pattern " + object)
+// recorded marks object processed once its record is written, so that
+// neither the checkpoint nor a queue moves past an object whose record
+// could still be lost.
+func (m *migrateState) recorded(object string) {
+	noteProcessed(object)
+	ackRedisKey(object)
+	m.pending.Done()
+}
+
+// migrateRecord returns the success record of object,
+// "bucket,key,versionID,etag,object" when a copy was written to dest.
+func migrateRecord(dest destination, object string) []string {
+	if dest.bucket == "" {
+		return []string{object}
 	}
-	r, err := minioSrcClient.GetObject(ctx, minioSrcBucket, object, miniogo.GetObjectOptions{})
+	return []string{dest.bucket, dest.key, dest.versionID, dest.etag, object}
+}
+
+// recordVersion queues the source => destination version ID mapping of
+// object, this is a no-op unless versionMap is set.
+func (m *migrateState) recordVersion(object, srcVersionID, bucket string, info miniogo.UploadInfo) {
+	if !versionMap {
+		return
+	}
+	m.versionCh <- []string{srcVersionID, info.VersionID, bucket, info.Key, object}
+}
+
+// writeVersionMap persists the version ID mapping as
+// "srcVersionID,dstVersionID,dstBucket,dstObject,srcObject" records.
+func (m *migrateState) writeVersionMap(ctx context.Context) {
+	defer close(m.versionDone)
+	v, err := createOutputFile(versionMapFile)
 	if err != nil {
-		return err
+		logDMsg("could not create "+versionMapFile, err)
+		return
 	}
+	defer v.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case rec, ok := <-m.versionCh:
+			if !ok {
+				return
+			}
+			if err := writeRecord(v, rec...); err != nil {
+				logMsg(fmt.Sprintf("Error writing to version_map.txt for %s: %s", rec[len(rec)-1], err))
+				os.Exit(exitAborted)
+			}
+		}
+	}
+}
+
+// allVersions set by --all-versions migrates every version of an object
+// oldest first instead of only the latest.
+var allVersions bool
+
+// migrateObject copies object to its destination and returns it, a zero
+// destination when nothing was written.
+func migrateObject(ctx context.Context, object string) (dest destination, err error) {
+	if !patternMatch(object) {
+		return destination{}, errors.New("Object doesn't match the expected pattern " + object)
+	}
+	if allVersions {
+		return migrateVersions(ctx, object)
+	}
+	err = retryTransient(ctx, object, func() error {
+		return retryStalled(object, func() (err error) {
+			dest, err = migrateVersion(ctx, object, "")
+			return err
+		})
+	})
+	return dest, err
+}
 
-	stat, err := r.Stat()
+// migrateVersions copies all versions of object oldest first, so the
+// destination versions are created in the order of the source versions.
+// Delete markers are recreated by removing the object at the destination.
+// The returned destination names every version written as its versionID
+// like the success records of move, or none when the versions were routed
+// to more than one destination, undo cannot reverse those.
+func migrateVersions(ctx context.Context, object string) (destination, error) {
+	var versions []miniogo.ObjectInfo
+	opts := miniogo.ListObjectsOptions{
+		WithVersions: true,
+		Prefix:       object,
+	}
+	for version := range minioSrcClient.ListObjects(ctx, minioSrcBucket, opts) {
+		if version.Err != nil {
+			return destination{}, version.Err
+		}
+		if version.Key == object {
+			versions = append(versions, version)
+		}
+	}
+	sortOldestFirst(versions)
+	var last destination
+	var written []string
+	spread := false
+	for _, version := range versions {
+		var dest destination
+		if version.IsDeleteMarker {
+			if err := retryTransient(ctx, object, func() (err error) {
+				dest, err = recreateDeleteMarker(ctx, object, version)
+				return err
+			}); err != nil {
+				return destination{}, err
+			}
+			if dest.versionID != "" {
+				dest.versionID = deleteMarkerPrefix + dest.versionID
+			}
+		} else if err := retryTransient(ctx, object, func() error {
+			return retryStalled(object, func() (err error) {
+				dest, err = migrateVersion(ctx, object, version.VersionID)
+				return err
+			})
+		}); err != nil {
+			return destination{}, fmt.Errorf("version %s: %w", version.VersionID, err)
+		}
+		if dest.bucket == "" {
+			continue
+		}
+		if last.bucket != "" && (dest.bucket != last.bucket || dest.key != last.key) {
+			spread = true
+		}
+		last = dest
+		if dest.versionID != "" {
+			written = append(written, dest.versionID)
+		}
+	}
+	switch {
+	case spread:
+		last.versionID, last.etag = "", ""
+	case len(written) > 0:
+		last.versionID = strings.Join(written, " ")
+	}
+	return last, nil
+}
+
+// recreateDeleteMarker removes the copy of object so that a versioned
+// destination gets a delete marker in place of marker, and returns the
+// destination with the version ID of the new marker.
+func recreateDeleteMarker(ctx context.Context, object string, marker miniogo.ObjectInfo) (destination, error) {
+	dest, err := resolveDestination(object, marker)
+	if err != nil || dest.skip {
+		return destination{}, err
+	}
+	if dryRun {
+		logMsg(fmt.Sprintf("%s: delete marker %s", object, marker.VersionID))
+		return destination{}, nil
+	}
+	rctx, cancel := opContext(ctx)
+	defer cancel()
+	if err = minioClient.RemoveObject(rctx, dest.bucket, dest.key, miniogo.RemoveObjectOptions{}); err != nil {
+		return destination{}, err
+	}
+	// An unversioned destination has no marker, the copy is just gone.
+	if stat, err := minioClient.StatObject(rctx, dest.bucket, dest.key, miniogo.StatObjectOptions{}); stat.IsDeleteMarker {
+		dest.versionID = stat.VersionID
+	} else if err == nil {
+		return destination{}, fmt.Errorf("%s/%s has no delete marker", dest.bucket, dest.key)
+	}
+	return dest, nil
+}
+
+// migrateVersion copies versionID of object, the latest version when
+// empty.
+func migrateVersion(ctx context.Context, object, versionID string) (destination, error) {
+	ctx, watch := watchStall(ctx)
+	defer watch.stop()
+	var sums *objectChecksums
+	if forwardChecksums {
+		ctx, sums = withChecksums(ctx)
+	}
+	if skipExisting {
+		exists, err := existsAtDestination(ctx, object)
+		if err != nil {
+			return destination{}, err
+		}
+		if exists {
+			logDMsg("skipping "+object+", already at the destination", nil)
+			return destination{}, nil
+		}
+	}
+	stat, r, err := openSource(ctx, object, versionID)
 	if err != nil {
 		fmt.Println(err)
 		logMsg(migrateMsg(object, convert(object)))
-		return err
+		return destination{}, err
 	}
 	defer r.Close()
+	dest, err := resolveDestination(object, stat)
+	if err != nil {
+		return destination{}, err
+	}
+	if dest.skip {
+		logDMsg("skipping "+object+", skipped by --transform-cmd", nil)
+		return destination{}, nil
+	}
+	if err = checkNormalized(ctx, minioSrcClient, minioSrcBucket, object, dest.bucket, dest.key, uploadSize(stat.Size)); err != nil {
+		return destination{}, err
+	}
+	if match, ok := dedup.lookup(stat, dest.bucket, dest.key); ok {
+		dedup.record(object, match)
+		if dedupMode == dedupSkip {
+			logDMsg("skipping "+object+", same content as "+match, nil)
+			return destination{}, nil
+		}
+	}
 	if dryRun {
-		logMsg(migrateMsg(object, convert(object)))
-		return nil
+		logMsg(migrateMsg(object, dest.key))
+		return destination{}, nil
+	}
+	bucket := dest.bucket
+	reason, err := checkConflict(ctx, bucket, dest.key, stat)
+	if err != nil {
+		return destination{}, err
 	}
-	result := strings.SplitN(object, "/", 2)
-	if len(result) != 2 {
-		fmt.Println("Unable to get prefix for object: ", object)
-		return errors.New("Unable to get prefix for object: " + object)
+	if reason != "" {
+		conflicts.record(object, reason)
+		return destination{}, nil
 	}
-	prefix, err := strconv.Atoi(result[0])
+	dest.metadata = applySourceACL(ctx, object, stat, dest.metadata)
+	// A server side copy does not take the checksums along, with
+	// --forward-checksums the data is streamed on the same cluster too.
+	if sameCluster && !presigned && cseKey == nil && !forwardChecksums {
+		info, err := serverSideCopy(ctx, dest, object, stat)
+		if err != nil {
+			return destination{}, err
+		}
+		dest.versionID, dest.etag = info.VersionID, info.ETag
+		return dest, nil
+	}
+	srcReserved, err := srcInflight.acquire(ctx, stat.Size)
 	if err != nil {
-		fmt.Println(err)
-		return err
-	}
-
-	var bucket string
-	if prefix > -1 && prefix < 250 {
-		bucket = minioDstBucket1
-	} else if prefix > 249 && prefix < 500 {
-		bucket = minioDstBucket2
-	} else if prefix > 499 && prefix < 750 {
-		bucket = minioDstBucket3
-	} else if prefix > 749 && prefix < 1000 {
-		bucket = minioDstBucket4
-	} else {
-		fmt.Println("unknown prefix for object: ", object)
-		return errors.New("Unable to get prefix for object: " + object)
+		return destination{}, err
 	}
-	_, err = minioClient.PutObject(ctx, bucket, convert(object), r, stat.Size, miniogo.PutObjectOptions{})
+	defer srcInflight.release(srcReserved)
+	dstReserved, err := dstInflight.acquire(ctx, stat.Size)
 	if err != nil {
+		return destination{}, err
+	}
+	defer dstInflight.release(dstReserved)
+	opts := miniogo.PutObjectOptions{
+		StorageClass:         targetStorageClass(stat),
+		UserMetadata:         dest.metadata,
+		ServerSideEncryption: destinationEncryption(bucket),
+		PartSize:             uploadPartSize(stat.Size),
+		Progress:             watch.progress(),
+	}
+	sourceObjectLock(stat).applyToPut(&opts)
+	if sums != nil && len(sums.header()) > 0 {
+		// The checksums are of the whole object, they can only be
+		// sent with a single part upload.
+		if stat.Size > maxCopyObjectSize {
+			return destination{}, fmt.Errorf("%w, %s is larger than 5GiB and uploaded in parts", errChecksumNotForwarded, object)
+		}
+		opts.DisableMultipart = true
+	}
+	if presigned {
+		body, err := presignedGet(ctx, object, stat.VersionID)
+		if err != nil {
+			return destination{}, err
+		}
+		defer body.Close()
+		r = body
+	}
+	src, stopReadAhead := readAheadReader(watch.reader(ctx, r))
+	defer stopReadAhead()
+	reader := bandwidth.reader(src)
+	if cseKey != nil {
+		if reader, opts.UserMetadata, err = encryptReader(reader, stat.Size, opts.UserMetadata); err != nil {
+			return destination{}, err
+		}
+		opts.PartSize = uploadPartSize(uploadSize(stat.Size))
+	}
+	var sum hash.Hash
+	if checksumAlgo != "" {
+		// Hash the data on its way to the destination, encrypted
+		// with --cse-key.
+		sum = newChecksum()
+		reader = io.TeeReader(reader, sum)
+	}
+	var info miniogo.UploadInfo
+	if presigned {
+		info, err = presignedPut(ctx, dest, stat, reader, opts)
+	} else {
+		info, err = minioClient.PutObject(ctx, bucket, dest.key, reader, uploadSize(stat.Size), opts)
+	}
+	if err = watch.err(err); err != nil {
 		logDMsg("upload to minio client failed for "+object, err)
-		return err
+		return destination{}, err
+	}
+	if err = verifyUpload(stat, info); err != nil {
+		logDMsg("verification failed for "+object, err)
+		return destination{}, err
+	}
+	if sum != nil {
+		if err = verifyChecksum(ctx, bucket, dest.key, info.VersionID, encodeChecksum(sum)); err != nil {
+			logDMsg("checksum verification failed for "+object, err)
+			return destination{}, err
+		}
 	}
+	migrationState.recordVersion(object, stat.VersionID, bucket, info)
+	reprotected.record(object, bucket, dest.key, stat)
+	dedup.add(bucket, dest.key, info, stat.Size)
+	migrationState.addBytes(stat.Size)
 	logDMsg("Uploaded "+object+" successfully", nil)
+	dest.versionID, dest.etag = info.VersionID, info.ETag
+	return dest, nil
+}
+
+// openSource returns the stat of versionID of object and a reader of its
+// data, with --presigned only the stat and a reader that is never read.
+func openSource(ctx context.Context, object, versionID string) (miniogo.ObjectInfo, io.ReadCloser, error) {
+	if presigned {
+		statCtx, cancel := opContext(ctx)
+		defer cancel()
+		stat, err := minioSrcClient.StatObject(statCtx, minioSrcBucket, object, miniogo.StatObjectOptions{VersionID: versionID})
+		return stat, ioutil.NopCloser(strings.NewReader("")), err
+	}
+	r, stat, err := getSource(ctx, object, versionID)
+	if err != nil {
+		return miniogo.ObjectInfo{}, nil, err
+	}
+	return stat, r, nil
+}
+
+// skipExisting set by --skip-existing skips objects whose copy is already
+// at the destination.
+var skipExisting bool
+
+// existsAtDestination reports whether the copy of object is at the
+// destination with the size of the source, using HEAD requests only.
+func existsAtDestination(ctx context.Context, object string) (bool, error) {
+	srcStat, err := statSource(ctx, object, "")
+	if err != nil {
+		return false, err
+	}
+	key := convert(object)
+	buckets, err := candidateBuckets(object, srcStat.Size)
+	if transformer != nil {
+		var dest destination
+		if dest, err = resolveDestination(object, srcStat); err != nil || dest.skip {
+			return dest.skip, err
+		}
+		buckets, key = []string{dest.bucket}, dest.key
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, bucket := range buckets {
+		statCtx, cancel := opContext(ctx)
+		dstStat, err := minioClient.StatObject(statCtx, bucket, key, miniogo.StatObjectOptions{})
+		cancel()
+		if err != nil {
+			if miniogo.ToErrorResponse(err).Code == "NoSuchKey" {
+				continue
+			}
+			return false, err
+		}
+		if uploadSize(srcStat.Size) == dstStat.Size {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// verifyUpload compares the uploaded object against the source stat. ETags
+// are only compared when both sides are plain MD5 sums, a multipart ETag
+// depends on the part size used and cannot match across clusters and the
+// ETag of an SSE-S3 or SSE-KMS object is not the MD5 of its data, nor is
+// that of a --cse-key upload.
+func verifyUpload(stat miniogo.ObjectInfo, info miniogo.UploadInfo) error {
+	if info.Size != uploadSize(stat.Size) {
+		return fmt.Errorf("size mismatch, source %d bytes, uploaded %d bytes", uploadSize(stat.Size), info.Size)
+	}
+	srcETag := strings.Trim(stat.ETag, "\"")
+	dstETag := strings.Trim(info.ETag, "\"")
+	if strings.Contains(srcETag, "-") || strings.Contains(dstETag, "-") || isEncrypted(stat) || destinationEncryption(info.Bucket) != nil || cseKey != nil {
+		return nil
+	}
+	if srcETag != dstETag {
+		return fmt.Errorf("ETag mismatch, source %s, uploaded %s", srcETag, dstETag)
+	}
 	return nil
 }
+
+// maxCopyObjectSize - maximum size 5GiB of object per CopyObject request,
+// anything bigger needs to go through ComposeObject.
+const maxCopyObjectSize = 1024 * 1024 * 1024 * 5
+
+// serverSideCopy copies object from the source bucket to the destination
+// bucket without routing data through this host, only valid when source and
+// destination are the same cluster.
+func serverSideCopy(ctx context.Context, dest destination, object string, stat miniogo.ObjectInfo) (miniogo.UploadInfo, error) {
+	bucket := dest.bucket
+	src := miniogo.CopySrcOptions{
+		Bucket:     minioSrcBucket,
+		Object:     object,
+		VersionID:  stat.VersionID,
+		Encryption: sourceEncryption(stat),
+	}
+	dst := miniogo.CopyDestOptions{
+		Bucket:          bucket,
+		Object:          dest.key,
+		ReplaceMetadata: dest.metadata != nil,
+		UserMetadata:    dest.metadata,
+		Encryption:      destinationEncryption(bucket),
+	}
+	setStorageClass(&dst, stat)
+	sourceObjectLock(stat).applyToCopy(&dst)
+	var info miniogo.UploadInfo
+	var err error
+	if stat.Size > maxCopyObjectSize {
+		// One copy request per part, only the run bounds them all.
+		info, err = minioClient.ComposeObject(ctx, dst, src)
+	} else {
+		copyCtx, cancel := opContext(ctx)
+		info, err = minioClient.CopyObject(copyCtx, dst, src)
+		cancel()
+	}
+	if err != nil {
+		logDMsg("server side copy failed for "+object, err)
+		return info, err
+	}
+	if checksumAlgo != "" {
+		want, err := objectChecksum(ctx, minioSrcClient, minioSrcBucket, object, miniogo.GetObjectOptions{
+			VersionID:            stat.VersionID,
+			ServerSideEncryption: sourceEncryption(stat),
+		})
+		if err != nil {
+			return info, err
+		}
+		if err = verifyChecksum(ctx, bucket, dest.key, info.VersionID, want); err != nil {
+			logDMsg("checksum verification failed for "+object, err)
+			return info, err
+		}
+	}
+	migrationState.recordVersion(object, stat.VersionID, bucket, info)
+	reprotected.record(object, bucket, dest.key, stat)
+	dedup.add(bucket, dest.key, info, stat.Size)
+	migrationState.addBytes(stat.Size)
+	logDMsg("Copied "+object+" successfully", nil)
+	return info, nil
+}
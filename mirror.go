@@ -0,0 +1,240 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+const removedMigFile = "migration_removed.txt"
+
+// mirrorRemove set by --remove deletes destination objects that no longer
+// exist at the source after each migration.
+var mirrorRemove bool
+
+// removeExtraneous deletes the objects of the destination buckets that
+// are not the migrated copy of a current source object. Only the source
+// objects the migration selects are compared, and only the destination
+// prefixes they are moved under are considered, those must be dedicated to
+// the migration.
+func removeExtraneous(ctx context.Context) error {
+	// Stop the source listing when returning before it is drained.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	prefixes := removalPrefixes()
+	dstPrefixes, exact := destinationPrefixes(prefixes)
+	expected := make(map[string]struct{})
+	// The source is always listed live, a cached listing would miss the
+	// objects created since and remove their copies.
+	opts := miniogo.ListObjectsOptions{
+		WithVersions: true,
+		Recursive:    true,
+	}
+	for object := range minioSrcClient.ListObjects(ctx, minioSrcBucket, opts) {
+		if object.Err != nil {
+			return object.Err
+		}
+		if object.IsDeleteMarker || !object.IsLatest {
+			continue
+		}
+		// Copies of unselected objects moved under a widened prefix are
+		// kept as well.
+		if exact && (!hasAnyPrefix(object.Key, prefixes) || !patternMatch(object.Key)) {
+			continue
+		}
+		if transformer != nil {
+			dest, err := resolveDestination(object.Key, object)
+			if err != nil {
+				return err
+			}
+			if !dest.skip {
+				expected[path.Join(dest.bucket, dest.key)] = struct{}{}
+			}
+			continue
+		}
+		key, err := convertKey(object.Key)
+		if err != nil {
+			continue
+		}
+		buckets, err := candidateBuckets(object.Key, object.Size)
+		if err != nil {
+			continue
+		}
+		for _, bucket := range buckets {
+			expected[path.Join(bucket, key)] = struct{}{}
+		}
+	}
+	logMsg(fmt.Sprintf("%d objects expected at the destination", len(expected)))
+
+	f, err := createOutputFile(removedMigFile)
+	if err != nil {
+		logDMsg("could not create "+removedMigFile, err)
+		return err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	for _, bucket := range []string{minioDstBucket1, minioDstBucket2, minioDstBucket3, minioDstBucket4} {
+		// The same bucket may serve several prefix ranges.
+		if seen[bucket] {
+			continue
+		}
+		seen[bucket] = true
+		for _, prefix := range dstPrefixes {
+			if err := removeExtraneousIn(ctx, bucket, prefix, expected, f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removalPrefixes returns the source prefixes the migration selects
+// objects from, those of --list-prefix, --prefix-file or --src-prefix, an
+// empty prefix for the whole bucket.
+func removalPrefixes() []string {
+	switch {
+	case len(listPrefixes) > 0:
+		return listPrefixes
+	case len(filePrefixes) > 0:
+		return filePrefixes
+	case remapPrefix():
+		return []string{srcPrefix}
+	}
+	return []string{""}
+}
+
+// destinationPrefixes returns the destination prefixes the objects under
+// the source prefixes are moved under, without the ones nested in another.
+// exact is false when a prefix had to be widened, objects of unselected
+// sources may then be moved under the returned prefixes too.
+func destinationPrefixes(prefixes []string) (kept []string, exact bool) {
+	exact = true
+	var mapped []string
+	for _, prefix := range prefixes {
+		dst, ok, moved := destinationPrefix(prefix)
+		if !moved {
+			continue
+		}
+		exact = exact && ok
+		if keyForm != nil {
+			dst = keyForm.String(dst)
+		}
+		mapped = append(mapped, dst)
+	}
+	sort.Strings(mapped)
+	for _, prefix := range mapped {
+		if len(kept) == 0 || !strings.HasPrefix(prefix, kept[len(kept)-1]) {
+			kept = append(kept, prefix)
+		}
+	}
+	return kept, exact
+}
+
+// destinationPrefix returns the destination prefix convert moves the keys
+// under prefix to. When convert strips directories of prefix itself the
+// prefix is widened to the directories it keeps and exact is false, with
+// the default layout the depth of the keys is not known and the whole
+// bucket is returned. moved is false when no key under prefix is moved.
+func destinationPrefix(prefix string) (dst string, exact, moved bool) {
+	if remapPrefix() {
+		switch {
+		case strings.HasPrefix(prefix, srcPrefix):
+			return dstPrefix + strings.TrimPrefix(prefix, srcPrefix), true, true
+		case strings.HasPrefix(srcPrefix, prefix):
+			return dstPrefix, true, true
+		}
+		return "", true, false
+	}
+	if prefix == "" {
+		return "", true, true
+	}
+	if stripFromDepth == 0 {
+		return "", false, true
+	}
+	parts := strings.Split(prefix, "/")
+	dirs, rest := parts[:len(parts)-1], parts[len(parts)-1]
+	start := stripFromDepth - 1
+	end := start + stripLevels
+	switch {
+	case len(dirs) < start, len(dirs) == start && rest == "":
+		return prefix, true, true
+	case len(dirs) >= end:
+		kept := append(append([]string{}, dirs[:start]...), dirs[end:]...)
+		return strings.Join(append(kept, rest), "/"), true, true
+	case start == 0:
+		return "", false, true
+	}
+	return strings.Join(dirs[:start], "/") + "/", false, true
+}
+
+// hasAnyPrefix reports whether key is under one of prefixes.
+func hasAnyPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func removeExtraneousIn(ctx context.Context, bucket, prefix string, expected map[string]struct{}, f *os.File) error {
+	listCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var listErr error
+	removeCh := make(chan miniogo.ObjectInfo)
+	go func() {
+		defer close(removeCh)
+		opts := miniogo.ListObjectsOptions{Recursive: true, Prefix: prefix}
+		for object := range minioClient.ListObjects(listCtx, bucket, opts) {
+			if object.Err != nil {
+				listErr = object.Err
+				return
+			}
+			if _, ok := expected[path.Join(bucket, object.Key)]; ok {
+				continue
+			}
+			if err := writeRecord(f, bucket, object.Key); err != nil {
+				logMsg(fmt.Sprintf("Error writing to %s for %s: %s", removedMigFile, object.Key, err))
+				os.Exit(exitAborted)
+			}
+			if dryRun {
+				logMsg(fmt.Sprintf("%s/%s: not at the source, would be removed", bucket, object.Key))
+				continue
+			}
+			logDMsg(fmt.Sprintf("removing %s/%s, not at the source", bucket, object.Key), nil)
+			removeCh <- object
+		}
+	}()
+	var removeErr error
+	for rerr := range minioClient.RemoveObjects(ctx, bucket, removeCh, miniogo.RemoveObjectsOptions{}) {
+		logMsg(fmt.Sprintf("error removing %s/%s: %s", bucket, rerr.ObjectName, rerr.Err))
+		removeErr = rerr.Err
+	}
+	if listErr != nil {
+		return listErr
+	}
+	return removeErr
+}
@@ -0,0 +1,75 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestDestinationPrefixes maps the source prefixes --remove compares to
+// the destination prefixes their objects are moved under.
+func TestDestinationPrefixes(t *testing.T) {
+	defer func() {
+		srcPrefix, dstPrefix = "", ""
+		stripLevels, stripFromDepth = 1, 0
+	}()
+	for _, c := range []struct {
+		name               string
+		src, dst           string
+		levels, fromDepth  int
+		prefixes, expected []string
+		exact              bool
+	}{
+		{"whole bucket", "", "", 1, 0, []string{""}, []string{""}, true},
+		{"src prefix", "data/", "archive/", 1, 0, []string{"data/"}, []string{"archive/"}, true},
+		{"list prefix under src prefix", "data/", "archive/", 1, 0, []string{"data/2020/", "data/2021/x", "other/"}, []string{"archive/2020/", "archive/2021/x"}, true},
+		{"list prefix above src prefix", "data/a/", "archive/", 1, 0, []string{"data/"}, []string{"archive/"}, true},
+		{"from depth keeps the prefix", "", "", 1, 3, []string{"a/b/"}, []string{"a/b/"}, true},
+		{"from depth strips in front", "", "", 1, 2, []string{"a/b/c/", "a/b/d"}, []string{"a/c/", "a/d"}, true},
+		{"from depth strips the prefix", "", "", 2, 2, []string{"a/b/"}, []string{"a/"}, false},
+		{"default layout", "", "", 1, 0, []string{"42/abc/"}, []string{""}, false},
+		{"nested prefixes", "data/", "archive/", 1, 0, []string{"data/x/", "data/"}, []string{"archive/"}, true},
+	} {
+		srcPrefix, dstPrefix = c.src, c.dst
+		stripLevels, stripFromDepth = c.levels, c.fromDepth
+		got, exact := destinationPrefixes(c.prefixes)
+		if !reflect.DeepEqual(got, c.expected) || exact != c.exact {
+			t.Errorf("%s: got %q exact %v, expected %q exact %v", c.name, got, exact, c.expected, c.exact)
+		}
+	}
+}
+
+// TestRemovalPrefixes picks the source prefixes the migration selects.
+func TestRemovalPrefixes(t *testing.T) {
+	defer func() { listPrefixes, filePrefixes, srcPrefix = nil, nil, "" }()
+	if got := removalPrefixes(); !reflect.DeepEqual(got, []string{""}) {
+		t.Errorf("without prefixes got %q", got)
+	}
+	srcPrefix = "data/"
+	if got := removalPrefixes(); !reflect.DeepEqual(got, []string{"data/"}) {
+		t.Errorf("with --src-prefix got %q", got)
+	}
+	filePrefixes = []string{"a/", "b/"}
+	if got := removalPrefixes(); !reflect.DeepEqual(got, filePrefixes) {
+		t.Errorf("with --prefix-file got %q", got)
+	}
+	listPrefixes = []string{"data/x/"}
+	if got := removalPrefixes(); !reflect.DeepEqual(got, listPrefixes) {
+		t.Errorf("with --list-prefix got %q", got)
+	}
+}
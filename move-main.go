@@ -17,19 +17,19 @@
 package main
 
 import (
+	"bufio"
 	"context"
-	"crypto/tls"
 	"fmt"
-	"net"
-	"net/http"
+	"io"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/minio/cli"
 	miniogo "github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/minio/minio/pkg/console"
 )
 
@@ -48,13 +48,35 @@ var moveFlags = []cli.Flag{
 		Name:  "fake",
 		Usage: "perform a fake migration",
 	},
+	cli.StringFlag{
+		Name:  "shard",
+		Usage: "only process shard i of N of the listing e.g. 0/4, keys are partitioned by hash",
+	},
+	cli.BoolFlag{
+		Name:  "all-versions",
+		Usage: "move every version of each object oldest first and then remove them all from the original key",
+	},
+}
+
+// levelFlags choose the directory levels move strips, undo takes them to
+// find the moved objects.
+var levelFlags = []cli.Flag{
+	cli.IntFlag{
+		Name:  "levels",
+		Usage: "number of directory levels to strip from the keys",
+		Value: 1,
+	},
+	cli.IntFlag{
+		Name:  "from-depth",
+		Usage: "strip the levels starting at this directory depth, 1 being the top level, instead of those holding the objects",
+	},
 }
 
 var moveCmd = cli.Command{
 	Name:   "move",
 	Usage:  "move objects up one level",
 	Action: moveAction,
-	Flags:  append(allFlags, moveFlags...),
+	Flags:  append(append(append(append(append(append(append(append(allFlags, moveFlags...), levelFlags...), prefixFlags...), prefixFileFlags...), confirmFlags...), encryptionFlags...), normalizeFlags...), listingFlags...),
 	CustomHelpTemplate: `NAME:
 	 {{.HelpName}} - {{.Usage}}
  
@@ -79,59 +101,83 @@ var moveCmd = cli.Command{
 	$ export MINIO_SECRET_KEY=minio123
 	$ export MINIO_BUCKET=miniobucket
 	$ moveobject move --data-dir /tmp/ --fake --log --start 40 --end 99
+
+ 3. Move objects with starting prefix of 0 to ending prefix of 99 using the listing cached by a previous "list".
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject move --data-dir /tmp/ --start 0 --end 99 --use-cached-listing
+ 
+ 4. Move all objects under "2021/raw/" to "archive/2021/", --start and --end are ignored.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject move --data-dir /tmp/ --src-prefix 2021/raw/ --dst-prefix archive/2021/
+
+ 5. Move objects up one level under the prefixes listed in prefixes.txt instead of numbered ones.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject move --data-dir /tmp/ --prefix-file prefixes.txt
+
+ 6. Flatten the second and third directory levels, 1/a/b/c/2021/01/x becomes 1/c/2021/01/x.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject move --data-dir /tmp/ --levels 2 --from-depth 2
+
+ 7. Move objects with starting prefix of 0 to ending prefix of 99 along with all their versions.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject move --data-dir /tmp/ --start 0 --end 99 --all-versions
  `,
 }
 
 func initMinioClient(ctx *cli.Context) error {
-	mURL := os.Getenv(EnvMinIOEndpoint)
+	mURL := ctx.String("endpoint")
 	if mURL == "" {
-		return fmt.Errorf("MINIO_ENDPOINT, MINIO_ACCESS_KEY, MINIO_SECRET_KEY and MINIO_BUCKET need to be set")
+		return fmt.Errorf("--endpoint, --access-key, --secret-key and --bucket or MINIO_ENDPOINT, MINIO_ACCESS_KEY, MINIO_SECRET_KEY and MINIO_BUCKET need to be set")
 	}
 	target, err := url.Parse(mURL)
 	if err != nil {
 		return fmt.Errorf("unable to parse input arg %s: %v", mURL, err)
 	}
 
-	accessKey := os.Getenv(EnvMinIOAccessKey)
-	secretKey := os.Getenv(EnvMinIOSecretKey)
-	minioBucket = os.Getenv(EnvMinIOBucket)
+	accessKey := ctx.String("access-key")
+	secretKey := ctx.String("secret-key")
+	minioBucket = ctx.String("bucket")
 
-	if accessKey == "" || secretKey == "" || minioBucket == "" {
+	if needsKeys(ctx.String("credentials")) && (accessKey == "" || secretKey == "") || minioBucket == "" {
 		console.Fatalln(fmt.Errorf("one or more of AccessKey:%s SecretKey: %s Bucket:%s ", accessKey, secretKey, minioBucket), "are missing in MinIO configuration")
 	}
+	tr, err := newTransport(ctx)
+	if err != nil {
+		return err
+	}
+	if dstAddrs, err = parseEndpoints(ctx.String("dst-endpoints"), target); err != nil {
+		return err
+	}
+	spreadConnections(tr, target, dstAddrs)
+	creds, err := newCredentials(ctx.String("credentials"), mURL, accessKey, secretKey, ctx.String("signature"), tr)
+	if err != nil {
+		return err
+	}
+	lookup, err := newBucketLookup(ctx.String("lookup"))
+	if err != nil {
+		return err
+	}
 	options := miniogo.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
-		Secure: target.Scheme == "https",
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			DialContext: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			MaxIdleConns:          256,
-			MaxIdleConnsPerHost:   16,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 10 * time.Second,
-			TLSClientConfig: &tls.Config{
-				RootCAs: mustGetSystemCertPool(),
-				// Can't use SSLv3 because of POODLE and BEAST
-				// Can't use TLSv1.0 because of POODLE and BEAST using CBC cipher
-				// Can't use TLSv1.1 because of RC4 cipher usage
-				MinVersion:         tls.VersionTLS12,
-				NextProtos:         []string{"http/1.1"},
-				InsecureSkipVerify: ctx.GlobalBool("insecure"),
-			},
-			// Set this value so that the underlying transport round-tripper
-			// doesn't try to auto decode the body of objects with
-			// content-encoding set to `gzip`.
-			//
-			// Refer:
-			//    https://golang.org/src/net/http/transport.go?h=roundTrip#L1843
-			DisableCompression: true,
-		},
+		Creds:        creds,
+		Secure:       target.Scheme == "https",
+		Transport:    resumeListings(observeDestination(limitRequests(tr)), creds),
 		Region:       "us-east-1",
-		BucketLookup: 0,
+		BucketLookup: lookup,
 	}
 
 	api, err := miniogo.New(target.Host, &options)
@@ -141,10 +187,11 @@ func initMinioClient(ctx *cli.Context) error {
 
 	// Store the new api object.
 	minioClient = api
+	runPreflight(ctx, false)
 	return nil
 }
 
-func moveAction(cliCtx *cli.Context) error {
+func moveAction(cliCtx *cli.Context) (err error) {
 	checkArgsAndInit(cliCtx)
 	ctx := context.Background()
 	logMsg("Init minio client..")
@@ -153,32 +200,155 @@ func moveAction(cliCtx *cli.Context) error {
 		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
 		console.Fatalln(err)
 	}
+	if err = parseEncryption(cliCtx); err != nil {
+		console.Fatalln(err)
+	}
 	mvState = newMoveState(ctx)
 	mvState.init(ctx)
+	start := time.Now()
+	defer func() { reportRun("move", mvState, start, err) }()
+	stopProbe := startProbe(ctx, minioClient, minioBucket)
 	startPrefix := cliCtx.Int("start")
 	endPrefix := cliCtx.Int("end")
 	dryRun = cliCtx.Bool("fake")
-	for i := startPrefix; i <= endPrefix; i++ {
-		prefix := strconv.Itoa(i) + "/"
-		logMsg("Starting prefix " + prefix)
-		opts := miniogo.ListObjectsOptions{
-			WithVersions: true,
-			Recursive:    true,
-			Prefix:       prefix,
+	moveAllVersions = cliCtx.Bool("all-versions")
+	if useCachedListing && tunedListing() {
+		console.Fatalln(errCachedListing)
+	}
+	list := func(queue func(string), count bool) error {
+		listAll := func(queue func(string)) error {
+			return listMoveTasks(ctx, startPrefix, endPrefix, func(task string) {
+				if admit() {
+					queue(task)
+				}
+			})
 		}
-		for object := range minioClient.ListObjects(context.Background(), minioBucket, opts) {
+		if count || remapPrefix() {
+			return listAll(queue)
+		}
+		return spoolTasks(listAll, queue)
+	}
+	if err := queueConfirmed(cliCtx, "move", minioBucket, list, mvState.queueUploadTask); err != nil {
+		return err
+	}
+	mvState.finish(ctx)
+	stopProbe()
+	logMsg("successfully completed move.")
+
+	return nil
+}
+
+// taskSpoolPrefix names the file holding the tasks of a move or copy
+// listing until the listing completes.
+const taskSpoolPrefix = "listed-tasks-"
+
+// spoolTasks passes to queue the tasks list finds, only once list
+// completes. The moved and copied keys stay under the listed prefixes, so a
+// listing running alongside the moves would list them again and strip them
+// once more. The tasks are spooled to a file in the data directory instead of
+// being held in memory.
+func spoolTasks(list func(queue func(string)) error, queue func(string)) error {
+	f, err := ioutil.TempFile(dirPath, taskSpoolPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	var werr error
+	if err = list(func(task string) {
+		if werr == nil {
+			werr = writeRecord(w, task)
+		}
+	}); err != nil {
+		return err
+	}
+	if werr != nil {
+		return werr
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	scanner := newRecordScanner(f)
+	for scanner.Scan() {
+		queue(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// listMoveTasks passes to queue the latest version of all objects under the
+// numbered prefixes startPrefix to endPrefix, or under --src-prefix or the
+// prefixes of --prefix-file when set.
+func listMoveTasks(ctx context.Context, startPrefix, endPrefix int, queue func(string)) error {
+	if remapPrefix() {
+		return listMovePrefix(ctx, srcPrefix, queue)
+	}
+	if useCachedListing {
+		// Walk the cached listing once instead of once per prefix.
+		for object := range listBucket(ctx, minioClient, minioBucket) {
 			if object.Err != nil {
 				fmt.Println(object.Err)
 				return object.Err
 			}
-			if !object.IsDeleteMarker && object.IsLatest && patternMatch(object.Key) {
-				mvState.queueUploadTask(object.VersionID + "," + object.Key)
+			if len(filePrefixes) == 0 {
+				prefix, err := strconv.Atoi(strings.SplitN(object.Key, "/", 2)[0])
+				if err != nil || prefix < startPrefix || prefix > endPrefix {
+					continue
+				}
+			}
+			if movable(object) {
+				queue(formatRecord(object.VersionID, object.Key))
 				logDMsg(fmt.Sprintf("adding %s to move queue", object.Key+" : "+object.VersionID), nil)
 			}
 		}
+		return nil
 	}
-	mvState.finish(ctx)
-	logMsg("successfully completed move.")
+	for _, prefix := range filePrefixes {
+		if err := listMovePrefix(ctx, prefix, queue); err != nil {
+			return err
+		}
+	}
+	if len(filePrefixes) > 0 {
+		return nil
+	}
+	for i := startPrefix; i <= endPrefix; i++ {
+		if err := listMovePrefix(ctx, strconv.Itoa(i)+"/", queue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// movable reports whether object is listed for move, the latest version
+// of a key that is not a delete marker unless every version is moved.
+func movable(object miniogo.ObjectInfo) bool {
+	if !object.IsLatest || object.IsDeleteMarker && !moveAllVersions {
+		return false
+	}
+	return inShard(object.Key) && patternMatch(object.Key)
+}
 
+// listMovePrefix passes to queue the latest version of all objects under
+// prefix.
+func listMovePrefix(ctx context.Context, prefix string, queue func(string)) error {
+	logMsg("Starting prefix " + prefix)
+	opts := miniogo.ListObjectsOptions{
+		WithVersions: true,
+		Recursive:    true,
+		Prefix:       prefix,
+	}
+	for object := range listObjects(context.Background(), minioClient, minioBucket, opts) {
+		if object.Err != nil {
+			fmt.Println(object.Err)
+			return object.Err
+		}
+		if movable(object) {
+			queue(formatRecord(object.VersionID, object.Key))
+			logDMsg(fmt.Sprintf("adding %s to move queue", object.Key+" : "+object.VersionID), nil)
+		}
+	}
 	return nil
 }
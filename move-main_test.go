@@ -0,0 +1,57 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+)
+
+// TestSpoolTasks moves keys within the listed prefix while the listing
+// still runs, each moved key must be stripped once.
+func TestSpoolTasks(t *testing.T) {
+	dirPath = t.TempDir()
+	defer func() { dirPath = "" }()
+	stripLevels, stripFromDepth = 1, 2
+	defer func() { stripLevels, stripFromDepth = 1, 0 }()
+
+	// The bucket is listed in order, the moves add keys behind the
+	// listing that are still under the listed prefix photos/. The
+	// listing stops at 10 keys should the moved keys be listed again.
+	bucket := []string{"photos/2021/a/x.jpg", "photos/2021/b/y.jpg"}
+	list := func(queue func(string)) error {
+		for i := 0; i < len(bucket) && i < 10; i++ {
+			queue(bucket[i])
+		}
+		return nil
+	}
+	var moved []string
+	move := func(key string) {
+		moved = append(moved, key)
+		bucket = append(bucket, convert(key))
+	}
+	if err := spoolTasks(list, move); err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 2 {
+		t.Fatalf("moved %v, expected only the listed keys", moved)
+	}
+	for i, expected := range []string{"photos/a/x.jpg", "photos/b/y.jpg"} {
+		if got := bucket[2+i]; got != expected {
+			t.Errorf("%s moved to %s, expected %s", moved[i], got, expected)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path"
 	"runtime"
 	"strings"
 	"sync"
@@ -13,15 +12,22 @@ import (
 	"time"
 
 	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 )
 
 type moveState struct {
 	objectCh  chan string
 	failedCh  chan string
-	successCh chan string
+	successCh chan []string
 	count     uint64
 	failCnt   uint64
+	bytes     uint64
 	wg        sync.WaitGroup
+
+	// writerDone is closed once the records are written.
+	writerDone chan struct{}
+
+	stopProgress func()
 }
 
 func (m *moveState) queueUploadTask(obj string) {
@@ -38,9 +44,10 @@ func newMoveState(ctx context.Context) *moveState {
 		moveConcurrent = runtime.GOMAXPROCS(0)
 	}
 	ms := &moveState{
-		objectCh:  make(chan string, moveConcurrent),
-		failedCh:  make(chan string, moveConcurrent),
-		successCh: make(chan string, moveConcurrent),
+		objectCh:   make(chan string, moveConcurrent),
+		failedCh:   make(chan string, moveConcurrent),
+		successCh:  make(chan []string, moveConcurrent),
+		writerDone: make(chan struct{}),
 	}
 
 	return ms
@@ -66,13 +73,31 @@ func (m *moveState) getFailCount() uint64 {
 	return atomic.LoadUint64(&m.failCnt)
 }
 
-// addWorker creates a new worker to process tasks
-func (m *moveState) addWorker(ctx context.Context) {
+// Increase bytes processed
+func (m *moveState) addBytes(n int64) {
+	atomic.AddUint64(&m.bytes, uint64(n))
+}
+
+// Get total bytes processed
+func (m *moveState) getBytes() uint64 {
+	return atomic.LoadUint64(&m.bytes)
+}
+
+// Get number of objects waiting for a worker
+func (m *moveState) queueDepth() int {
+	return len(m.objectCh)
+}
+
+// addWorker creates worker id to process tasks
+func (m *moveState) addWorker(ctx context.Context, id int) {
 	m.wg.Add(1)
 	// Add a new worker.
 	go func() {
 		defer m.wg.Done()
 		for {
+			if !intake.wait(ctx, id) {
+				return
+			}
 			select {
 			case <-ctx.Done():
 				return
@@ -80,23 +105,37 @@ func (m *moveState) addWorker(ctx context.Context) {
 				if !ok {
 					return
 				}
-				result := strings.SplitN(object, ",", 2)
-				obj := result[1]
-				versionID := result[0]
+				result, err := parseRecord(object)
+				if err != nil || len(result) != 2 {
+					m.incFailCount()
+					logMsg(fmt.Sprintf("error parsing move task %s", object))
+					countFailure("InvalidTask")
+					noteProcessed(object)
+					m.failedCh <- object
+					continue
+				}
+				versionID, obj := result[0], result[1]
 				logDMsg(fmt.Sprintf("Moving...%s", obj), nil)
 				if !patternMatch(obj) {
 					m.incFailCount()
 					logMsg(fmt.Sprintf("error matching object %s", obj))
+					countFailure("PatternMismatch")
+					noteProcessed(obj)
 					m.failedCh <- obj
 					continue
 				}
-				if err := moveObject(ctx, obj, versionID); err != nil {
+				moved, err := moveObject(ctx, obj, versionID)
+				if err != nil {
 					m.incFailCount()
 					logMsg(fmt.Sprintf("error moving object %s: %s", obj, err))
+					countFailure(failureReason(err))
+					noteError(obj, err)
+					noteProcessed(obj)
 					m.failedCh <- obj
 					continue
 				}
-				m.successCh <- obj
+				noteProcessed(obj)
+				m.successCh <- moved.record(obj)
 				m.incCount()
 			}
 		}
@@ -107,8 +146,10 @@ func (m *moveState) finish(ctx context.Context) {
 	time.Sleep(100 * time.Millisecond)
 	close(m.objectCh)
 	m.wg.Wait() // wait on workers to finish
+	m.stopProgress()
 	close(m.failedCh)
 	close(m.successCh)
+	<-m.writerDone
 
 	if !dryRun {
 		logMsg(fmt.Sprintf("Moved %d objects, %d failures", m.getCount(), m.getFailCount()))
@@ -118,11 +159,13 @@ func (m *moveState) init(ctx context.Context) {
 	if m == nil {
 		return
 	}
+	m.stopProgress = startProgress("Moving", m)
 	for i := 0; i < moveConcurrent; i++ {
-		m.addWorker(ctx)
+		m.addWorker(ctx, i)
 	}
 	go func() {
-		f, err := os.OpenFile(path.Join(dirPath, failMoveFile+time.Now().Format(".01-02-2006-15-04-05")), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		defer close(m.writerDone)
+		f, err := createRecordFile(failMoveFile)
 		if err != nil {
 			logDMsg("could not create "+failMoveFile, err)
 			return
@@ -131,7 +174,7 @@ func (m *moveState) init(ctx context.Context) {
 		defer fwriter.Flush()
 		defer f.Close()
 
-		s, err := os.OpenFile(path.Join(dirPath, successMoveFile+time.Now().Format(".01-02-2006-15-04-05")), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		s, err := createRecordFile(successMoveFile)
 		if err != nil {
 			logDMsg("could not create "+successMoveFile, err)
 			return
@@ -140,35 +183,181 @@ func (m *moveState) init(ctx context.Context) {
 		defer swriter.Flush()
 		defer s.Close()
 
-		for {
+		failedCh, successCh := m.failedCh, m.successCh
+		for failedCh != nil || successCh != nil {
 			select {
 			case <-ctx.Done():
 				return
-			case obj, ok := <-m.failedCh:
+			case obj, ok := <-failedCh:
 				if !ok {
-					return
+					failedCh = nil
+					continue
 				}
-				if _, err := f.WriteString(obj + "\n"); err != nil {
+				if err := writeRecord(f, obj); err != nil {
 					logMsg(fmt.Sprintf("Error writing to move_fails.txt for "+obj, err))
-					os.Exit(1)
+					os.Exit(exitAborted)
 				}
-			case obj, ok := <-m.successCh:
+			case rec, ok := <-successCh:
 				if !ok {
-					return
+					successCh = nil
+					continue
 				}
-				if _, err := s.WriteString(obj + "\n"); err != nil {
-					logMsg(fmt.Sprintf("Error writing to move_success.txt for "+obj, err))
-					os.Exit(1)
+				if err := writeRecord(s, rec...); err != nil {
+					logMsg(fmt.Sprintf("Error writing to move_success.txt for %s: %s", rec[len(rec)-1], err))
+					os.Exit(exitAborted)
 				}
 			}
 		}
 	}()
 }
 
-func moveObject(ctx context.Context, object, versionID string) error {
+// moveAllVersions set by --all-versions moves every version of an object
+// oldest first instead of only the latest.
+var moveAllVersions bool
+
+// deleteMarkerPrefix marks the delete markers among the versions of a
+// move success record.
+const deleteMarkerPrefix = "marker:"
+
+// movedObject is what a move wrote at the new key of an object.
+type movedObject struct {
+	key  string
+	info miniogo.UploadInfo
+	// versions are the versions written by --all-versions oldest first,
+	// delete markers prefixed with deleteMarkerPrefix.
+	versions []string
+}
+
+// record returns the success record of object, "key,versionID,etag,object"
+// with the space separated versions of --all-versions as versionID.
+func (m movedObject) record(object string) []string {
+	versionID := m.info.VersionID
+	if len(m.versions) > 0 {
+		versionID = strings.Join(m.versions, " ")
+	}
+	return []string{m.key, versionID, m.info.ETag, object}
+}
+
+// moveObject moves object to its new key and returns what was written
+// there.
+func moveObject(ctx context.Context, object, versionID string) (moved movedObject, err error) {
+	if moved.key, err = convertKey(object); err != nil {
+		return moved, err
+	}
+	if err = retryTransient(ctx, object, func() error {
+		return checkNormalized(ctx, minioClient, minioBucket, object, minioBucket, moved.key, -1)
+	}); err != nil {
+		return moved, err
+	}
 	if dryRun {
 		logMsg(migrateMsg(object, object))
+		return moved, nil
+	}
+	if moveAllVersions {
+		return moveVersions(ctx, object, moved)
+	}
+	if err = retryTransient(ctx, object, func() (err error) {
+		moved.info, err = copyVersion(ctx, object, versionID)
+		return err
+	}); err != nil {
+		return moved, err
+	}
+	if err = retryTransient(ctx, object, func() error {
+		return removeVersion(ctx, object, versionID)
+	}); err != nil {
+		return moved, err
+	}
+	logDMsg("Uploaded "+object+" successfully", nil)
+	return moved, nil
+}
+
+// moveVersions copies all versions of object oldest first, so the new key
+// gets them in the order of the original, and only then removes them from
+// the original key. Delete markers are recreated by removing the new key.
+// Each version is retried on its own, a retry of the whole object would
+// copy the versions already copied once more.
+func moveVersions(ctx context.Context, object string, moved movedObject) (movedObject, error) {
+	var versions []miniogo.ObjectInfo
+	opts := miniogo.ListObjectsOptions{
+		WithVersions: true,
+		Prefix:       object,
+	}
+	if err := retryTransient(ctx, object, func() error {
+		versions = versions[:0]
+		for version := range minioClient.ListObjects(ctx, minioBucket, opts) {
+			if version.Err != nil {
+				return version.Err
+			}
+			if version.Key == object {
+				versions = append(versions, version)
+			}
+		}
 		return nil
+	}); err != nil {
+		return moved, err
+	}
+	sortOldestFirst(versions)
+	for _, version := range versions {
+		if version.IsDeleteMarker {
+			var markerID string
+			err := retryTransient(ctx, object, func() (err error) {
+				markerID, err = addDeleteMarker(ctx, moved.key)
+				return err
+			})
+			if err != nil {
+				logDMsg("recreating delete marker failed for "+object, err)
+				return moved, fmt.Errorf("delete marker %s: %w", version.VersionID, err)
+			}
+			moved.info = miniogo.UploadInfo{VersionID: markerID}
+			moved.versions = append(moved.versions, deleteMarkerPrefix+markerID)
+			continue
+		}
+		if err := retryTransient(ctx, object, func() (err error) {
+			moved.info, err = copyVersion(ctx, object, version.VersionID)
+			return err
+		}); err != nil {
+			return moved, fmt.Errorf("version %s: %w", version.VersionID, err)
+		}
+		moved.versions = append(moved.versions, moved.info.VersionID)
+	}
+	for _, version := range versions {
+		if err := retryTransient(ctx, object, func() error {
+			return removeVersion(ctx, object, version.VersionID)
+		}); err != nil {
+			return moved, fmt.Errorf("version %s: %w", version.VersionID, err)
+		}
+	}
+	logDMsg(fmt.Sprintf("Uploaded %d versions of %s successfully", len(versions), object), nil)
+	return moved, nil
+}
+
+// addDeleteMarker removes key without a version, which puts a delete
+// marker on it, and returns the version ID of the marker.
+func addDeleteMarker(ctx context.Context, key string) (string, error) {
+	rctx, cancel := opContext(ctx)
+	defer cancel()
+	if err := minioClient.RemoveObject(rctx, minioBucket, key, miniogo.RemoveObjectOptions{}); err != nil {
+		return "", err
+	}
+	stat, err := minioClient.StatObject(rctx, minioBucket, key, miniogo.StatObjectOptions{})
+	if !stat.IsDeleteMarker {
+		if err == nil {
+			err = fmt.Errorf("%s has no delete marker", key)
+		}
+		return "", err
+	}
+	return stat.VersionID, nil
+}
+
+// copyVersion copies versionID of object to its new key and returns the
+// version written.
+func copyVersion(ctx context.Context, object, versionID string) (miniogo.UploadInfo, error) {
+	var key encrypt.ServerSide
+	if srcSSEC != nil {
+		var err error
+		if _, key, err = statEncrypted(ctx, object, versionID); err != nil {
+			return miniogo.UploadInfo{}, err
+		}
 	}
 
 	src := miniogo.CopySrcOptions{
@@ -182,21 +371,28 @@ func moveObject(ctx context.Context, object, versionID string) error {
 		Bucket: minioBucket,
 		Object: convert(object),
 	}
+	applyEncryption(&src, &dst, key)
 
-	_, err := minioClient.CopyObject(ctx, dst, src)
+	copyCtx, cancel := opContext(ctx)
+	info, err := minioClient.CopyObject(copyCtx, dst, src)
+	cancel()
 	if err != nil {
 		logDMsg("upload to minio client failed for "+object, err)
-		return err
 	}
+	return info, err
+}
+
+// removeVersion removes versionID of object from its original key.
+func removeVersion(ctx context.Context, object, versionID string) error {
 	opts := miniogo.RemoveObjectOptions{
 		VersionID: versionID,
 	}
 
-	err = minioClient.RemoveObject(ctx, minioBucket, object, opts)
+	removeCtx, cancel := opContext(ctx)
+	err := minioClient.RemoveObject(removeCtx, minioBucket, object, opts)
+	cancel()
 	if err != nil {
 		logDMsg("removeObject failed for "+object, err)
-		return err
 	}
-	logDMsg("Uploaded "+object+" successfully", nil)
-	return nil
+	return err
 }
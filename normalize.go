@@ -0,0 +1,204 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio/pkg/console"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	normalizedKeysFile       = "normalized_keys.txt"
+	normalizedCollisionsFile = "normalized_collisions.txt"
+)
+
+// errKeyCollision fails an object whose normalized key is the key of
+// another source object.
+var errKeyCollision = errors.New("normalized key collides")
+
+// normalizeFlags normalize the Unicode form of the destination keys.
+var normalizeFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "normalize-keys",
+		Usage: "normalize the destination keys to Unicode form nfc or nfd, e.g. nfc for keys created on macOS",
+	},
+}
+
+// keyForm set by --normalize-keys is the Unicode normalization form of
+// the destination keys, nil to keep the keys as they are.
+var keyForm *norm.Form
+
+// parseKeyForm parses the --normalize-keys form.
+func parseKeyForm(s string) (*norm.Form, error) {
+	var form norm.Form
+	switch s {
+	case "":
+		return nil, nil
+	case "nfc":
+		form = norm.NFC
+	case "nfd":
+		form = norm.NFD
+	default:
+		return nil, fmt.Errorf("unknown --normalize-keys %q, should be nfc or nfd", s)
+	}
+	return &form, nil
+}
+
+var (
+	normalizedMu sync.Mutex
+	// normalizedKeys is the report of the run, created on the first key
+	// that changed.
+	normalizedKeys *os.File
+	// normalizedSeen maps the objects already in the report to their
+	// normalized key.
+	normalizedSeen = make(map[string]string)
+	// normalizedCollisions is the report of the collisions of the run,
+	// created on the first one.
+	normalizedCollisions *os.File
+	// claimed maps the normalized destination keys of the run to the
+	// object copied to them.
+	claimed = make(map[string]string)
+)
+
+// normalizeKey returns key in the --normalize-keys form and records
+// object when that changed it as a "key,object" record.
+func normalizeKey(object, key string) string {
+	if keyForm == nil || keyForm.IsNormalString(key) {
+		return key
+	}
+	normalized := keyForm.String(key)
+	normalizedMu.Lock()
+	defer normalizedMu.Unlock()
+	if _, ok := normalizedSeen[object]; ok {
+		return normalized
+	}
+	normalizedSeen[object] = normalized
+	if normalizedKeys == nil {
+		var err error
+		if normalizedKeys, err = createOutputFile(normalizedKeysFile); err != nil {
+			console.Errorln(fmt.Sprintf("unable to create %s: %v", normalizedKeysFile, err))
+			return normalized
+		}
+	}
+	if err := writeRecord(normalizedKeys, normalized, object); err != nil {
+		console.Errorln(fmt.Sprintf("unable to record %s in %s: %v", object, normalizedKeysFile, err))
+	}
+	return normalized
+}
+
+// checkNormalized returns errKeyCollision when the key of object changed
+// by --normalize-keys is also the key of another object of the run, or
+// when the source has the object under its normalized key already. The
+// copy would overwrite that of the other object. An object of another
+// size than size, or of any size when negative, at key in bucket is not
+// failed but recorded, it may be a copy of an earlier run. Collisions are
+// recorded in normalized_collisions.txt as "key,object,with" records.
+func checkNormalized(ctx context.Context, srcClient *miniogo.Client, srcBucket, object, bucket, key string, size int64) error {
+	normalizedMu.Lock()
+	normalized, ok := normalizedSeen[object]
+	if !ok || normalized != key {
+		// The key is unchanged or replaced by --transform-cmd.
+		normalizedMu.Unlock()
+		return nil
+	}
+	other, claimedBefore := claimed[path.Join(bucket, key)]
+	if !claimedBefore {
+		claimed[path.Join(bucket, key)] = object
+	}
+	normalizedMu.Unlock()
+	if claimedBefore {
+		if other == object {
+			// Another version of the same object.
+			return nil
+		}
+		return recordCollision(key, object, other, true)
+	}
+
+	if sameForm := keyForm.String(object); sameForm != object {
+		statCtx, cancel := opContext(ctx)
+		_, err := srcClient.StatObject(statCtx, srcBucket, sameForm, miniogo.StatObjectOptions{})
+		cancel()
+		if err == nil {
+			return recordCollision(key, object, sameForm, true)
+		}
+		if miniogo.ToErrorResponse(err).Code != "NoSuchKey" {
+			return err
+		}
+	}
+
+	statCtx, cancel := opContext(ctx)
+	dst, err := minioClient.StatObject(statCtx, bucket, key, miniogo.StatObjectOptions{})
+	cancel()
+	if err != nil {
+		if miniogo.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil
+		}
+		return err
+	}
+	if size < 0 || dst.Size != size {
+		return recordCollision(key, object, fmt.Sprintf("%s of %d bytes at the destination", path.Join(bucket, key), dst.Size), false)
+	}
+	return nil
+}
+
+// recordCollision records that key of object collides with other, and
+// fails object unless only recorded.
+func recordCollision(key, object, other string, fail bool) error {
+	normalizedMu.Lock()
+	defer normalizedMu.Unlock()
+	if normalizedCollisions == nil {
+		var err error
+		if normalizedCollisions, err = createOutputFile(normalizedCollisionsFile); err != nil {
+			console.Errorln(fmt.Sprintf("unable to create %s: %v", normalizedCollisionsFile, err))
+		}
+	}
+	if normalizedCollisions != nil {
+		if err := writeRecord(normalizedCollisions, key, object, other); err != nil {
+			console.Errorln(fmt.Sprintf("unable to record %s in %s: %v", object, normalizedCollisionsFile, err))
+		}
+	}
+	if !fail {
+		logMsg(fmt.Sprintf("%s: normalized key %s exists as %s", object, key, other))
+		return nil
+	}
+	return fmt.Errorf("%w, %s is also the key of %s", errKeyCollision, key, other)
+}
+
+// closeNormalizedKeys closes the reports at the end of a run.
+func closeNormalizedKeys() {
+	normalizedMu.Lock()
+	defer normalizedMu.Unlock()
+	if normalizedKeys != nil {
+		normalizedKeys.Close()
+		normalizedKeys = nil
+	}
+	if normalizedCollisions != nil {
+		normalizedCollisions.Close()
+		normalizedCollisions = nil
+	}
+	normalizedSeen = make(map[string]string)
+	claimed = make(map[string]string)
+}
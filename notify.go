@@ -0,0 +1,97 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio/pkg/console"
+)
+
+// runID identifies this invocation in notifications and artifacts.
+var runID = time.Now().UTC().Format("20060102T150405Z") + "-" + strconv.Itoa(os.Getpid())
+
+// notifyURL receives a JSON runSummary when a run finishes or aborts.
+var notifyURL string
+
+// runSummary is the end of run summary written to summary.json and sent
+// to --notify-url.
+type runSummary struct {
+	RunID          string            `json:"runId"`
+	Command        string            `json:"command"`
+	Status         string            `json:"status"`
+	Flags          map[string]string `json:"flags,omitempty"`
+	Objects        uint64            `json:"objects"`
+	Failures       uint64            `json:"failures"`
+	FailureReasons map[string]uint64 `json:"failureReasons,omitempty"`
+	Bytes          uint64            `json:"bytes"`
+	Start          time.Time         `json:"start"`
+	End            time.Time         `json:"end"`
+	Duration       string            `json:"duration"`
+	Files          []string          `json:"files,omitempty"`
+	Error          string            `json:"error,omitempty"`
+}
+
+func newRunSummary(command string, c progressCounter, start time.Time, runErr error) runSummary {
+	end := time.Now().UTC()
+	s := runSummary{
+		RunID:    runID,
+		Command:  command,
+		Status:   "completed",
+		Objects:  c.getCount(),
+		Failures: c.getFailCount(),
+		Bytes:    c.getBytes(),
+		Start:    start.UTC(),
+		End:      end,
+		Duration: end.Sub(start).Round(time.Second).String(),
+	}
+	if runErr != nil {
+		s.Status = "aborted"
+		s.Error = runErr.Error()
+	}
+	return s
+}
+
+// notifyRun posts the run summary to --notify-url, if set.
+func notifyRun(s runSummary) {
+	if notifyURL == "" {
+		return
+	}
+	body, err := json.Marshal(s)
+	if err != nil {
+		console.Errorln(fmt.Sprintf("unable to encode run summary: %v", err))
+		return
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(notifyURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		console.Errorln(fmt.Sprintf("unable to notify %s: %v", notifyURL, err))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		console.Errorln(fmt.Sprintf("unable to notify %s: %s", notifyURL, resp.Status))
+		return
+	}
+	logDMsg("notified "+notifyURL+" of run "+runID, nil)
+}
@@ -0,0 +1,90 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"time"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+// skipObjectLock set by --skip-object-lock drops the legal hold and
+// retention of source objects instead of applying them to the copies.
+var skipObjectLock bool
+
+// objectLock is the legal hold and retention of an object.
+type objectLock struct {
+	mode            miniogo.RetentionMode
+	retainUntilDate time.Time
+	legalHold       miniogo.LegalHoldStatus
+}
+
+// sourceObjectLock returns the legal hold and retention reported in the
+// headers of stat, empty when the object is not locked or --skip-object-lock
+// is set.
+func sourceObjectLock(stat miniogo.ObjectInfo) (lock objectLock) {
+	if skipObjectLock {
+		return lock
+	}
+	if mode := miniogo.RetentionMode(stat.Metadata.Get("X-Amz-Object-Lock-Mode")); mode.IsValid() {
+		until, err := time.Parse(time.RFC3339, stat.Metadata.Get("X-Amz-Object-Lock-Retain-Until-Date"))
+		if err != nil {
+			logDMsg("invalid retain until date of "+stat.Key, err)
+		} else {
+			lock.mode, lock.retainUntilDate = mode, until
+		}
+	}
+	if hold := miniogo.LegalHoldStatus(stat.Metadata.Get("X-Amz-Object-Lock-Legal-Hold")); hold.IsValid() {
+		lock.legalHold = hold
+	}
+	return lock
+}
+
+// applyToPut sets the lock on the upload options.
+func (l objectLock) applyToPut(opts *miniogo.PutObjectOptions) {
+	opts.Mode = l.mode
+	opts.RetainUntilDate = l.retainUntilDate
+	opts.LegalHold = l.legalHold
+}
+
+// applyToCopy sets the lock on the copy destination.
+func (l objectLock) applyToCopy(dst *miniogo.CopyDestOptions) {
+	dst.Mode = l.mode
+	dst.RetainUntilDate = l.retainUntilDate
+	dst.LegalHold = l.legalHold
+}
+
+// applyToObject sets the lock on versionID of an uploaded object, for
+// uploads that cannot carry it.
+func (l objectLock) applyToObject(ctx context.Context, bucket, object, versionID string) error {
+	if l.mode != "" {
+		mode, until := l.mode, l.retainUntilDate
+		opts := miniogo.PutObjectRetentionOptions{Mode: &mode, RetainUntilDate: &until, VersionID: versionID}
+		if err := minioClient.PutObjectRetention(ctx, bucket, object, opts); err != nil {
+			return err
+		}
+	}
+	if l.legalHold != "" {
+		hold := l.legalHold
+		opts := miniogo.PutObjectLegalHoldOptions{Status: &hold, VersionID: versionID}
+		if err := minioClient.PutObjectLegalHold(ctx, bucket, object, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
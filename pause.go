@@ -0,0 +1,155 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// intake lets an operator quiesce a run, e.g. during a production
+// incident: while it is paused the workers finish the objects they have
+// but take no new ones, so the checkpoint stays at the first object not
+// done and the run continues from there once resumed. It also caps the
+// number of workers taking objects, see setWorkers and setAdaptive.
+var intake = newIntakeGate()
+
+type intakeGate struct {
+	mu     sync.Mutex
+	paused bool
+	// workers is the number of workers allowed to take objects, zero
+	// for all of them, and adaptive the number --adaptive allows.
+	workers, adaptive int
+	// pool is the number of workers seen waiting, the size of the worker
+	// pool of the run.
+	pool int
+	// changed is closed and replaced whenever paused or workers change.
+	changed chan struct{}
+}
+
+func newIntakeGate() *intakeGate {
+	return &intakeGate{changed: make(chan struct{})}
+}
+
+// update applies f to g under the lock and wakes the waiting workers, it
+// returns what f returns.
+func (g *intakeGate) update(f func() bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !f() {
+		return false
+	}
+	close(g.changed)
+	g.changed = make(chan struct{})
+	return true
+}
+
+// pause stops the workers from taking new objects, it returns false when
+// intake is already paused.
+func (g *intakeGate) pause() bool {
+	return g.update(func() bool {
+		if g.paused {
+			return false
+		}
+		g.paused = true
+		return true
+	})
+}
+
+// resume releases the waiting workers, it returns false when intake is
+// not paused.
+func (g *intakeGate) resume() bool {
+	return g.update(func() bool {
+		if !g.paused {
+			return false
+		}
+		g.paused = false
+		return true
+	})
+}
+
+// setWorkers lets only the workers with an id below n take objects, the
+// others finish the object they have and wait. Zero lets all of them,
+// it returns false when n is the current limit.
+func (g *intakeGate) setWorkers(n int) bool {
+	return g.update(func() bool {
+		if g.workers == n {
+			return false
+		}
+		g.workers = n
+		return true
+	})
+}
+
+// setAdaptive is setWorkers for --adaptive, the lower of both limits
+// applies.
+func (g *intakeGate) setAdaptive(n int) bool {
+	return g.update(func() bool {
+		if g.adaptive == n {
+			return false
+		}
+		g.adaptive = n
+		return true
+	})
+}
+
+// poolSize returns the size of the worker pool of the run, zero before
+// the workers started.
+func (g *intakeGate) poolSize() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.pool
+}
+
+// limit returns the number of workers allowed to take objects, zero for
+// all of them. g.mu is held.
+func (g *intakeGate) limit() int {
+	n := g.workers
+	if g.adaptive > 0 && (n == 0 || g.adaptive < n) {
+		n = g.adaptive
+	}
+	return n
+}
+
+// isPaused returns whether intake is paused.
+func (g *intakeGate) isPaused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// wait blocks while worker id may not take objects, it returns false when
+// ctx is done first.
+func (g *intakeGate) wait(ctx context.Context, id int) bool {
+	for {
+		g.mu.Lock()
+		if id >= g.pool {
+			g.pool = id + 1
+		}
+		if n := g.limit(); !g.paused && (n == 0 || id < n) {
+			g.mu.Unlock()
+			return true
+		}
+		ch := g.changed
+		g.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
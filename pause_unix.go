@@ -0,0 +1,43 @@
+//go:build !windows
+// +build !windows
+
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchPauseSignals pauses intake on SIGUSR1 and resumes it on SIGUSR2
+// for the lifetime of the process.
+func watchPauseSignals() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range sigCh {
+			switch {
+			case sig == syscall.SIGUSR1 && intake.pause():
+				logMsg("intake paused by SIGUSR1, objects in flight are finished, send SIGUSR2 to resume")
+			case sig == syscall.SIGUSR2 && intake.resume():
+				logMsg("intake resumed by SIGUSR2")
+			}
+		}
+	}()
+}
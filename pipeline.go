@@ -0,0 +1,161 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+)
+
+var (
+	// partSize set by --part-size is the part size of multipart uploads,
+	// every running upload buffers one part in memory. Zero keeps the
+	// client default of 128MiB.
+	partSize uint64
+	// readAhead set by --read-ahead is how far reading the source may run
+	// ahead of the upload, zero reads only as the upload asks for data.
+	readAhead int
+)
+
+const (
+	minPartSize   = 5 * humanize.MiByte
+	maxPartsCount = 10000
+)
+
+// parsePartSize parses --part-size, empty for the client default.
+func parsePartSize(s string) (uint64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	size, err := humanize.ParseBytes(s)
+	if err != nil {
+		return 0, err
+	}
+	if size < minPartSize || size > maxCopyObjectSize {
+		return 0, errors.New("--part-size should be between 5MiB and 5GiB")
+	}
+	return size, nil
+}
+
+// uploadPartSize returns the part size to upload an object of size bytes
+// with, raised to the next MiB when --part-size would need more than the
+// maximum number of parts.
+func uploadPartSize(size int64) uint64 {
+	if partSize == 0 {
+		return 0
+	}
+	if min := uint64(size+maxPartsCount-1) / maxPartsCount; min > partSize {
+		return (min + humanize.MiByte - 1) / humanize.MiByte * humanize.MiByte
+	}
+	return partSize
+}
+
+// errUploadDone stops a read-ahead whose upload has returned.
+var errUploadDone = errors.New("upload done")
+
+// readAheadChunk is the size of the buffers a read-ahead fills.
+const readAheadChunk = 256 * humanize.KiByte
+
+// readAheadReader reads r in a goroutine into a ring of buffers holding up
+// to readAhead bytes ahead of the consumer, so that downloading the next
+// chunks overlaps uploading the current one with bounded memory. The
+// returned function stops the goroutine.
+func readAheadReader(r io.Reader) (io.Reader, func()) {
+	if readAhead <= 0 {
+		return r, func() {}
+	}
+	chunk := readAheadChunk
+	if readAhead < 2*chunk {
+		chunk = (readAhead + 1) / 2
+	}
+	count := readAhead / chunk
+	a := &aheadReader{
+		filled: make(chan []byte, count),
+		free:   make(chan []byte, count),
+		done:   make(chan struct{}),
+	}
+	for i := 0; i < count; i++ {
+		a.free <- make([]byte, chunk)
+	}
+	go a.fill(r)
+	var once sync.Once
+	return a, func() {
+		once.Do(func() { close(a.done) })
+	}
+}
+
+// aheadReader passes on the buffers filled by fill and hands them back
+// once read.
+type aheadReader struct {
+	filled chan []byte
+	free   chan []byte
+	done   chan struct{}
+	// err ends the data once filled is closed.
+	err error
+	// buf is the buffer being read, cur its unread part.
+	buf, cur []byte
+}
+
+func (a *aheadReader) fill(r io.Reader) {
+	defer close(a.filled)
+	for {
+		var buf []byte
+		select {
+		case buf = <-a.free:
+		case <-a.done:
+			a.err = errUploadDone
+			return
+		}
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			select {
+			case a.filled <- buf[:n]:
+			case <-a.done:
+				a.err = errUploadDone
+				return
+			}
+		}
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			a.err = err
+			return
+		}
+	}
+}
+
+func (a *aheadReader) Read(p []byte) (int, error) {
+	for len(a.cur) == 0 {
+		if a.buf != nil {
+			// free holds every buffer, this never blocks.
+			a.free <- a.buf[:cap(a.buf)]
+			a.buf = nil
+		}
+		buf, ok := <-a.filled
+		if !ok {
+			return 0, a.err
+		}
+		a.buf, a.cur = buf, buf
+	}
+	n := copy(p, a.cur)
+	a.cur = a.cur[n:]
+	return n, nil
+}
@@ -0,0 +1,48 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	_ "net/http/pprof" // registers the /debug/pprof handlers
+	"runtime"
+
+	"github.com/minio/minio/pkg/console"
+)
+
+// startPprof serves the /debug/pprof profiles on addr for the lifetime of
+// the process, e.g.
+//
+//	go tool pprof http://localhost:6060/debug/pprof/heap
+func startPprof(addr string) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		console.Fatalln(fmt.Errorf("unable to listen on --pprof-addr %s: %v", addr, err))
+	}
+	// Sample blocking and lock contention so the block and mutex
+	// profiles have data.
+	runtime.SetBlockProfileRate(1000)
+	runtime.SetMutexProfileFraction(100)
+	logMsg("serving pprof on http://" + l.Addr().String() + "/debug/pprof/")
+	go func() {
+		if err := http.Serve(l, http.DefaultServeMux); err != nil {
+			logDMsg("pprof server stopped", err)
+		}
+	}()
+}
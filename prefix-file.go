@@ -0,0 +1,111 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+var prefixFileFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "prefix-file",
+		Usage: "file of prefixes to process one per line instead of the numbered prefixes --start to --end, for key spaces that are not numbered",
+	},
+}
+
+// filePrefixes set by --prefix-file replace the numbered prefixes of list
+// and move.
+var filePrefixes []string
+
+// readPrefixFile returns the prefixes of name, one per line, in sorted
+// order. Empty lines and lines starting with # are skipped, as are
+// prefixes under another prefix of the file so that no key is listed
+// twice.
+func readPrefixFile(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var prefixes []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		prefix := strings.TrimSpace(scanner.Text())
+		if prefix == "" || strings.HasPrefix(prefix, "#") {
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("no prefixes found in %s", name)
+	}
+	sort.Strings(prefixes)
+	kept := prefixes[:1]
+	for _, prefix := range prefixes[1:] {
+		if !strings.HasPrefix(prefix, kept[len(kept)-1]) {
+			kept = append(kept, prefix)
+		}
+	}
+	return kept, nil
+}
+
+// listFilePrefixes lists the prefixes of --prefix-file one after the
+// other with opts.
+func listFilePrefixes(ctx context.Context, client *miniogo.Client, bucket string, opts miniogo.ListObjectsOptions) <-chan miniogo.ObjectInfo {
+	objCh := make(chan miniogo.ObjectInfo, 1)
+	go func() {
+		defer close(objCh)
+		for _, prefix := range filePrefixes {
+			logMsg("Starting prefix " + prefix)
+			opts.Prefix = prefix
+			for object := range listObjects(ctx, client, bucket, opts) {
+				select {
+				case objCh <- object:
+				case <-ctx.Done():
+					return
+				}
+				if object.Err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return objCh
+}
+
+// inFilePrefixes reports whether key is under one of the prefixes of
+// --prefix-file.
+func inFilePrefixes(key string) bool {
+	for _, prefix := range filePrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
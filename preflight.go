@@ -0,0 +1,204 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio/pkg/console"
+)
+
+// preflightRetryInterval is the pause between probes of an unreachable
+// destination while --wait-for-dest has not expired.
+const preflightRetryInterval = 5 * time.Second
+
+// errUnreachable marks preflight failures worth retrying, the endpoint
+// could not be reached at all as opposed to rejecting the request.
+var errUnreachable = errors.New("endpoint unreachable")
+
+// runPreflight probes every endpoint and bucket the command is about to
+// use and exits with a diagnostic on the first one that fails, so that a
+// misconfiguration is reported once instead of once per object. The
+// destination is retried for up to --wait-for-dest while unreachable.
+func runPreflight(cliCtx *cli.Context, src bool) {
+	if cliCtx.Bool("skip-preflight") {
+		return
+	}
+	tr, err := newTransport(cliCtx)
+	if err != nil {
+		console.Fatalln(err)
+	}
+	wait := cliCtx.Duration("wait-for-dest")
+	if src {
+		sources := []string{minioSrcBucket}
+		for _, sb := range srcBuckets {
+			sources = append(sources, sb.bucket)
+		}
+		for _, bucket := range uniqueBuckets(sources...) {
+			if err := probeEndpoint(minioSrcClient, nil, tr.TLSClientConfig, bucket, 0); err != nil {
+				console.Fatalln(fmt.Errorf("source %s: %v", minioSrcClient.EndpointURL(), err))
+			}
+		}
+		for _, bucket := range uniqueBuckets(destBuckets()...) {
+			if err := probeEndpoint(minioClient, dstAddrs, tr.TLSClientConfig, bucket, wait); err != nil {
+				console.Fatalln(fmt.Errorf("destination %s: %v", minioClient.EndpointURL(), err))
+			}
+		}
+		return
+	}
+	if err := probeEndpoint(minioClient, dstAddrs, tr.TLSClientConfig, minioBucket, wait); err != nil {
+		console.Fatalln(fmt.Errorf("%s: %v", minioClient.EndpointURL(), err))
+	}
+}
+
+// probeEndpoint connects to the endpoint of clnt, completes the TLS
+// handshake with each of addrs when set and lists at most one object of
+// bucket, which exercises the credentials and the bucket permissions. Unreachable endpoints are
+// retried until wait has passed.
+func probeEndpoint(clnt *miniogo.Client, addrs []string, tlsConfig *tls.Config, bucket string, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for {
+		err := handshakeAll(clnt.EndpointURL(), addrs, tlsConfig)
+		if err == nil {
+			err = listOne(clnt, bucket)
+		}
+		if err == nil {
+			logDMsg(fmt.Sprintf("preflight of %s/%s passed", clnt.EndpointURL(), bucket), nil)
+			return nil
+		}
+		if !errors.Is(err, errUnreachable) || time.Now().Add(preflightRetryInterval).After(deadline) {
+			return err
+		}
+		logMsg(fmt.Sprintf("waiting for %s: %v", clnt.EndpointURL(), err))
+		time.Sleep(preflightRetryInterval)
+	}
+}
+
+// handshakeAll dials endpoint, or each of addrs that connections to it
+// are spread across, directly so that DNS, connection and certificate
+// problems are reported as such rather than after the client has
+// exhausted its retries. Endpoints behind an HTTP proxy are left to the
+// listing.
+func handshakeAll(endpoint *url.URL, addrs []string, tlsConfig *tls.Config) error {
+	if proxy, err := http.ProxyFromEnvironment(&http.Request{URL: endpoint}); err != nil || proxy != nil {
+		return nil
+	}
+	if len(addrs) == 0 {
+		port := endpoint.Port()
+		if port == "" {
+			port = "80"
+			if endpoint.Scheme == "https" {
+				port = "443"
+			}
+		}
+		return handshake(endpoint, net.JoinHostPort(endpoint.Hostname(), port), tlsConfig)
+	}
+	for _, addr := range addrs {
+		if err := handshake(endpoint, addr, tlsConfig); err != nil {
+			return fmt.Errorf("%s: %w", addr, err)
+		}
+	}
+	return nil
+}
+
+func handshake(endpoint *url.URL, addr string, tlsConfig *tls.Config) error {
+	conn, err := net.DialTimeout("tcp", addr, checkConnectTimeout)
+	if err != nil {
+		return diagnose(err, "")
+	}
+	defer conn.Close()
+	if endpoint.Scheme != "https" {
+		return nil
+	}
+	config := tlsConfig.Clone()
+	config.ServerName = endpoint.Hostname()
+	tlsConn := tls.Client(conn, config)
+	tlsConn.SetDeadline(time.Now().Add(checkConnectTimeout))
+	if err = tlsConn.Handshake(); err != nil {
+		return diagnose(err, "")
+	}
+	return nil
+}
+
+func listOne(clnt *miniogo.Client, bucket string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), checkConnectTimeout)
+	defer cancel()
+	for object := range clnt.ListObjects(ctx, bucket, miniogo.ListObjectsOptions{MaxKeys: 1}) {
+		if object.Err != nil {
+			return diagnose(object.Err, bucket)
+		}
+		break
+	}
+	return nil
+}
+
+// diagnose turns err into a message naming the likely misconfiguration.
+func diagnose(err error, bucket string) error {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		recordHeader     tls.RecordHeaderError
+		dnsErr           *net.DNSError
+		opErr            *net.OpError
+	)
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return fmt.Errorf("TLS certificate is signed by an unknown authority, add the CA to the system pool or use --insecure: %v", err)
+	case errors.As(err, &hostname), errors.As(err, &invalid):
+		return fmt.Errorf("TLS certificate is not valid for this endpoint: %v", err)
+	case errors.As(err, &recordHeader):
+		return fmt.Errorf("TLS handshake failed, the endpoint may be serving plain http: %v", err)
+	case errors.As(err, &dnsErr):
+		return fmt.Errorf("%w: unable to resolve %s", errUnreachable, dnsErr.Name)
+	case errors.As(err, &opErr), errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %v", errUnreachable, err)
+	}
+	switch miniogo.ToErrorResponse(err).Code {
+	case "InvalidAccessKeyId":
+		return fmt.Errorf("access key is not known to the endpoint")
+	case "SignatureDoesNotMatch":
+		return fmt.Errorf("secret key or signature version is wrong: %v", err)
+	case "AccessDenied":
+		return fmt.Errorf("credentials are not allowed to list bucket %s", bucket)
+	case "NoSuchBucket":
+		return fmt.Errorf("bucket %s does not exist", bucket)
+	}
+	return err
+}
+
+func uniqueBuckets(buckets ...string) []string {
+	seen := make(map[string]bool, len(buckets))
+	var unique []string
+	for _, bucket := range buckets {
+		if !seen[bucket] {
+			seen[bucket] = true
+			unique = append(unique, bucket)
+		}
+	}
+	return unique
+}
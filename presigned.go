@@ -0,0 +1,153 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+var (
+	// presigned set by --presigned moves the data with plain HTTP requests
+	// to presigned URLs, only the URLs are signed with the credentials.
+	presigned bool
+	// presignExpiry set by --presign-expiry is how long the URLs are valid.
+	presignExpiry time.Duration
+	// transferClient sends the presigned requests, through --transfer-proxy
+	// when set.
+	transferClient *http.Client
+)
+
+// initPresigned creates the client of the presigned transfers.
+func initPresigned(ctx *cli.Context) error {
+	presignExpiry = ctx.Duration("presign-expiry")
+	if presignExpiry < time.Second || presignExpiry > 7*24*time.Hour {
+		return fmt.Errorf("--presign-expiry should be between 1s and 168h")
+	}
+	tr, err := newTransport(ctx)
+	if err != nil {
+		return err
+	}
+	if proxy := ctx.String("transfer-proxy"); proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid --transfer-proxy %q", proxy)
+		}
+		tr.Proxy = http.ProxyURL(u)
+	}
+	transferClient = &http.Client{Transport: limitRequests(tr)}
+	return nil
+}
+
+// presignedGet opens versionID of object through a presigned GET URL.
+func presignedGet(ctx context.Context, object, versionID string) (io.ReadCloser, error) {
+	params := make(url.Values)
+	if versionID != "" {
+		params.Set("versionId", versionID)
+	}
+	u, err := minioSrcClient.PresignedGetObject(ctx, minioSrcBucket, object, presignExpiry, params)
+	if err != nil {
+		return nil, err
+	}
+	ctx, opened := openContext(ctx)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		opened()
+		return nil, err
+	}
+	resp, err := transferClient.Do(req)
+	opened()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("presigned GET of %s failed: %s", object, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// presignedPut uploads the object stat describes from r to dest through a
+// presigned PUT URL with the headers of opts. The x-amz- headers, user
+// metadata and storage class among them, are only accepted when signed, so
+// the URL signs them as query parameters. The object lock cannot be sent
+// that way and is applied to the uploaded version afterwards. Objects are
+// limited to a single PUT of 5GiB.
+func presignedPut(ctx context.Context, dest destination, stat miniogo.ObjectInfo, r io.Reader, opts miniogo.PutObjectOptions) (miniogo.UploadInfo, error) {
+	if stat.Size > maxCopyObjectSize {
+		return miniogo.UploadInfo{}, fmt.Errorf("%s is larger than 5GiB, too large for a presigned PUT", stat.Key)
+	}
+	lock := objectLock{mode: opts.Mode, retainUntilDate: opts.RetainUntilDate, legalHold: opts.LegalHold}
+	opts.Mode, opts.RetainUntilDate, opts.LegalHold = "", time.Time{}, ""
+	if opts.ContentType == "" {
+		opts.ContentType = stat.ContentType
+	}
+	params := make(url.Values)
+	header := make(http.Header)
+	for k, v := range opts.Header() {
+		if strings.HasPrefix(strings.ToLower(k), "x-amz-") {
+			params[strings.ToLower(k)] = v
+		} else {
+			header[k] = v
+		}
+	}
+	u, err := minioClient.Presign(ctx, http.MethodPut, dest.bucket, dest.key, presignExpiry, params)
+	if err != nil {
+		return miniogo.UploadInfo{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), r)
+	if err != nil {
+		return miniogo.UploadInfo{}, err
+	}
+	req.Header = header
+	req.ContentLength = stat.Size
+	if stat.Size == 0 {
+		req.Body = http.NoBody
+	}
+	resp, err := transferClient.Do(req)
+	if err != nil {
+		return miniogo.UploadInfo{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return miniogo.UploadInfo{}, fmt.Errorf("presigned PUT of %s failed: %s", dest.key, resp.Status)
+	}
+	versionID := resp.Header.Get("X-Amz-Version-Id")
+	if err = lock.applyToObject(ctx, dest.bucket, dest.key, versionID); err != nil {
+		return miniogo.UploadInfo{}, fmt.Errorf("could not lock %s after the presigned PUT: %w", dest.key, err)
+	}
+	// Report what the destination stored, not what was sent.
+	info, err := minioClient.StatObject(ctx, dest.bucket, dest.key, miniogo.StatObjectOptions{VersionID: versionID})
+	if err != nil {
+		return miniogo.UploadInfo{}, err
+	}
+	return miniogo.UploadInfo{
+		Bucket:    dest.bucket,
+		Key:       dest.key,
+		ETag:      strings.Trim(info.ETag, "\""),
+		Size:      info.Size,
+		VersionID: info.VersionID,
+	}, nil
+}
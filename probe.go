@@ -0,0 +1,109 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio/pkg/console"
+)
+
+// probePrefix is where canary objects are written on the destination.
+const probePrefix = ".moveobject-probe/"
+
+// probeInterval interval between read-after-write probes, zero disables
+// the probe.
+var probeInterval time.Duration
+
+// startProbe periodically writes, reads back and deletes a canary object in
+// bucket until the returned function is called, alerting on errors or
+// stale reads so that a misbehaving destination is noticed during the run.
+func startProbe(ctx context.Context, client *miniogo.Client, bucket string) func() {
+	if probeInterval <= 0 {
+		return func() {}
+	}
+	host, _ := os.Hostname()
+	object := probePrefix + host + "-" + strconv.Itoa(os.Getpid())
+	ctx, cancel := context.WithCancel(ctx)
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		ticker := time.NewTicker(probeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := probeObject(ctx, client, bucket, object); err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					console.Errorln(fmt.Sprintf("destination probe on %s/%s failed: %v", bucket, object, err))
+					continue
+				}
+				logDMsg("destination probe on "+bucket+"/"+object+" succeeded", nil)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-doneCh
+	}
+}
+
+// probeObject writes a unique payload, reads it back and removes the
+// version written, a versioned bucket is left without a delete marker.
+func probeObject(ctx context.Context, client *miniogo.Client, bucket, object string) error {
+	ctx, cancel := opContext(ctx)
+	defer cancel()
+
+	payload := []byte(time.Now().UTC().Format(time.RFC3339Nano))
+	info, err := client.PutObject(ctx, bucket, object, bytes.NewReader(payload), int64(len(payload)), miniogo.PutObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("write: %v", err)
+	}
+	err = readProbe(ctx, client, bucket, object, payload)
+	if rerr := client.RemoveObject(ctx, bucket, object, miniogo.RemoveObjectOptions{VersionID: info.VersionID}); rerr != nil && err == nil {
+		err = fmt.Errorf("delete: %v", rerr)
+	}
+	return err
+}
+
+// readProbe reads object back and fails unless it holds payload.
+func readProbe(ctx context.Context, client *miniogo.Client, bucket, object string, payload []byte) error {
+	r, err := client.GetObject(ctx, bucket, object, miniogo.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("read: %v", err)
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return fmt.Errorf("read: %v", err)
+	}
+	if !bytes.Equal(data, payload) {
+		return fmt.Errorf("stale read, wrote %q but read %q", payload, data)
+	}
+	return nil
+}
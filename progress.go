@@ -0,0 +1,125 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// isTerminal is false when stdout is redirected to a file or a pipe,
+// output is then kept free of colors and carriage-return animations.
+var isTerminal = stdoutIsTerminal()
+
+func stdoutIsTerminal() bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// initConsole switches off colors when stdout is not a terminal.
+func initConsole() {
+	if !isTerminal {
+		color.NoColor = true
+	}
+}
+
+// progressInterval refresh rate of the progress line on a terminal.
+const progressInterval = time.Second
+
+// statsInterval interval between plain stats log lines, set by
+// --stats-interval.
+var statsInterval = 30 * time.Second
+
+// progressCounter is implemented by all the state machines.
+type progressCounter interface {
+	getCount() uint64
+	getFailCount() uint64
+	getBytes() uint64
+	queueDepth() int
+}
+
+// statsLine formats throughput since the previous sample.
+type statsLine struct {
+	count, bytes uint64
+	at           time.Time
+}
+
+func (s *statsLine) next(op string, c progressCounter) string {
+	now := time.Now()
+	count, bytes := c.getCount(), c.getBytes()
+	elapsed := now.Sub(s.at).Seconds()
+	objRate := float64(count-s.count) / elapsed
+	byteRate := float64(bytes-s.bytes) / elapsed
+	s.count, s.bytes, s.at = count, bytes, now
+	return fmt.Sprintf("%s: %d objects, %d failures, %.1f objects/sec, %.2f MB/sec, queue depth %d",
+		op, count, c.getFailCount(), objRate, byteRate/1e6, c.queueDepth())
+}
+
+// startProgress reports progress of op until the returned function is
+// called, as an in-place updated line on a terminal and as periodic plain
+// log lines otherwise or when log lines would interleave with it.
+func startProgress(op string, c progressCounter) func() {
+	animate := isTerminal && !logFlag && !debugFlag
+	interval := progressInterval
+	if !animate {
+		interval = statsInterval
+	}
+	doneCh := make(chan struct{})
+	stoppedCh := make(chan struct{})
+	sampleTimeline(c, true)
+	stopStatus := startStatus(op, c)
+	stopChat := startChat(op, c)
+	go func() {
+		defer close(stoppedCh)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		stats := &statsLine{at: time.Now()}
+		for {
+			select {
+			case <-doneCh:
+				if animate {
+					fmt.Print("\r\033[K")
+				}
+				return
+			case <-ticker.C:
+				sampleTimeline(c, false)
+				line := stats.next(op, c)
+				toRunLog(line)
+				if animate {
+					fmt.Print("\r\033[K" + line)
+				} else {
+					fmt.Println(time.Now().Format(time.RFC3339), line)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(doneCh)
+		<-stoppedCh
+		stopStatus()
+		stopChat()
+	}
+}
@@ -0,0 +1,171 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio/pkg/console"
+)
+
+var pruneVersionsFlags = []cli.Flag{
+	cli.IntFlag{
+		Name:  "days",
+		Usage: "remove versions that have been non-current for more than this many days",
+	},
+	cli.IntFlag{
+		Name:  "keep",
+		Usage: "keep only this many most recent versions of each object, including the current one",
+	},
+	cli.StringFlag{
+		Name:  "pattern",
+		Usage: "only prune objects whose key matches this regular expression",
+	},
+	cli.BoolFlag{
+		Name:  "fake",
+		Usage: "perform a fake prune",
+	},
+	cli.StringFlag{
+		Name:  "shard",
+		Usage: "only process shard i of N of the listing e.g. 0/4, keys are partitioned by hash",
+	},
+}
+
+var pruneVersionsCmd = cli.Command{
+	Name:   "prune-versions",
+	Usage:  "remove old non-current versions of objects",
+	Action: pruneVersionsAction,
+	Flags:  append(allFlags, pruneVersionsFlags...),
+	CustomHelpTemplate: `NAME:
+	 {{.HelpName}} - {{.Usage}}
+ 
+ USAGE:
+	 {{.HelpName}} [--days, --keep, --pattern, --fake]
+ 
+ DESCRIPTION:
+	The current version of an object is never removed. When both --days and
+	--keep are set a version is only removed if it satisfies both.
+ 
+ FLAGS:
+	{{range .VisibleFlags}}{{.}}
+	{{end}}
+ 
+ EXAMPLES:
+ 1. Remove versions in MinIO that have been non-current for more than 30 days.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject prune-versions --data-dir /tmp/ --days 30
+ 
+ 2. Perform a dry run for keeping only the last 3 versions of objects under prefix 42/.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject prune-versions --data-dir /tmp/ --keep 3 --pattern '^42/' --fake --log
+ `,
+}
+
+func pruneVersionsAction(cliCtx *cli.Context) (err error) {
+	checkArgsAndInit(cliCtx)
+	ctx := context.Background()
+	days := cliCtx.Int("days")
+	keep := cliCtx.Int("keep")
+	if days <= 0 && keep <= 0 {
+		console.Fatalln("one of --days or --keep must be set")
+	}
+	var pattern *regexp.Regexp
+	if p := cliCtx.String("pattern"); p != "" {
+		if pattern, err = regexp.Compile(p); err != nil {
+			console.Fatalln(fmt.Errorf("invalid --pattern %q: %v", p, err))
+		}
+	}
+	logMsg("Init minio client..")
+	if err := initMinioClient(cliCtx); err != nil {
+		logDMsg("Unable to  initialize MinIO client, exiting...%w", err)
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
+		console.Fatalln(err)
+	}
+	pruneState = newPruneVersionsState(ctx)
+	pruneState.init(ctx)
+	start := time.Now()
+	defer func() { reportRun("prune-versions", pruneState, start, err) }()
+	dryRun = cliCtx.Bool("fake")
+	if err := queuePrunableVersions(ctx, pattern, days, keep); err != nil {
+		return err
+	}
+	pruneState.finish(ctx)
+	logMsg("successfully completed prune-versions.")
+
+	return nil
+}
+
+// queuePrunableVersions lists all versions of the bucket and queues the
+// non-current versions that are older than days, or beyond the keep most
+// recent versions of their object. A zero days or keep disables that
+// condition.
+func queuePrunableVersions(ctx context.Context, pattern *regexp.Regexp, days, keep int) error {
+	cutoff := time.Now().AddDate(0, 0, -days)
+	var (
+		key      string
+		position int
+		// newer is when the previous, newer version was written, which
+		// is when the current one became non-current.
+		newer time.Time
+	)
+	opts := miniogo.ListObjectsOptions{
+		WithVersions: true,
+		Recursive:    true,
+	}
+	// Versions of a key are listed together, newest first.
+	for object := range minioClient.ListObjects(ctx, minioBucket, opts) {
+		if object.Err != nil {
+			fmt.Println(object.Err)
+			return object.Err
+		}
+		if object.Key != key {
+			key, position = object.Key, 0
+		}
+		position++
+		nonCurrentSince := newer
+		newer = object.LastModified
+		if object.IsLatest || !inShard(object.Key) || !patternMatch(object.Key) {
+			continue
+		}
+		if pattern != nil && !pattern.MatchString(object.Key) {
+			continue
+		}
+		if days > 0 && nonCurrentSince.After(cutoff) {
+			continue
+		}
+		if keep > 0 && position <= keep {
+			continue
+		}
+		if !admit() {
+			break
+		}
+		pruneState.queueUploadTask(formatRecord(object.VersionID, object.Key))
+		logDMsg(fmt.Sprintf("adding %s to prune queue", object.Key+" : "+object.VersionID), nil)
+	}
+	return nil
+}
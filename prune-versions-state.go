@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+)
+
+var pruneState *taskPool
+
+func newPruneVersionsState(ctx context.Context) *taskPool {
+	return newTaskPool(poolTasks{
+		action: "Pruning versions",
+		// Tasks are "versionID,key" records.
+		fields: 2,
+		key:    1,
+		run: func(ctx context.Context, fields []string) error {
+			return removeObjectVersion(ctx, fields[1], fields[0])
+		},
+		failFile:    failPruneVersionsFile,
+		successFile: successPruneVersionsFile,
+		done:        "Removed %d versions, %d failures",
+	})
+}
@@ -0,0 +1,76 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxRPS set by --max-rps caps the requests per second sent to the source
+// and the destination together, zero is unlimited.
+var maxRPS int
+
+// requestLimiter paces the requests of all workers to maxRPS.
+type requestLimiter struct {
+	mu sync.Mutex
+	// next is when the next request may be sent.
+	next time.Time
+}
+
+var requests = &requestLimiter{}
+
+// reserve returns how long the caller has to wait before sending a
+// request, up to a second worth of unused requests can be sent as a burst.
+func (l *requestLimiter) reserve() time.Duration {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.next.Before(now.Add(-time.Second)) {
+		l.next = now.Add(-time.Second)
+	}
+	l.next = l.next.Add(time.Second / time.Duration(maxRPS))
+	return l.next.Sub(now)
+}
+
+// limitRequests returns rt paced by --max-rps, retries of the client count
+// as requests of their own.
+func limitRequests(rt http.RoundTripper) http.RoundTripper {
+	if maxRPS <= 0 {
+		return rt
+	}
+	return &rateLimitedTransport{rt: rt, l: requests}
+}
+
+type rateLimitedTransport struct {
+	rt http.RoundTripper
+	l  *requestLimiter
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if d := t.l.reserve(); d > 0 {
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+	return t.rt.RoundTrip(req)
+}
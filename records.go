@@ -0,0 +1,286 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Listing, success, fail and version map files hold one CSV record per
+// object, fields are quoted when they contain commas, quotes or newlines
+// so that any object key survives a round trip. Files written before
+// quoting was introduced read the same as long as their keys contain no
+// commas.
+
+// writeRecord writes fields to w as a single record.
+func writeRecord(w io.Writer, fields ...string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(fields); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatRecord returns fields encoded as a record without the trailing
+// newline, used to pass multi-field tasks through a queue.
+func formatRecord(fields ...string) string {
+	var b bytes.Buffer
+	writeRecord(&b, fields...)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// parseRecord decodes a record produced by formatRecord.
+func parseRecord(s string) ([]string, error) {
+	return newCSVReader(strings.NewReader(s)).Read()
+}
+
+// Listing, success and fail files written by moveobject start with a
+// header record "#moveobject,version,bucket,generated,filters" naming the
+// bucket the objects were listed from, so that a file is not replayed
+// against another bucket by accident. Files without a header, e.g.
+// written by mc, are accepted as they are.
+const (
+	recordHeaderMarker  = "#moveobject"
+	recordFormatVersion = 1
+)
+
+// filterFlags are the flags that narrow down the objects of a run, they
+// are recorded in the header of the files the run writes.
+var filterFlags = []string{"src-prefix", "prefix", "list-prefix", "prefix-file", "pattern", "shard", "limit", "min-versions"}
+
+// ignoreRecordHeader set by --ignore-header accepts files whose header
+// names another bucket.
+var ignoreRecordHeader bool
+
+// recordHeader describes the run that wrote a file.
+type recordHeader struct {
+	version   int
+	bucket    string
+	generated time.Time
+	filters   string
+}
+
+// recordBucket returns the bucket the objects of the current command are
+// read from.
+func recordBucket() string {
+	if minioSrcBucket != "" {
+		return minioSrcBucket
+	}
+	return minioBucket
+}
+
+// runFilters returns the filter flags set for this run as
+// "name=value;...".
+func runFilters() string {
+	var filters []string
+	for _, name := range filterFlags {
+		if value, ok := runFlags[name]; ok {
+			filters = append(filters, name+"="+value)
+		}
+	}
+	return strings.Join(filters, ";")
+}
+
+// writeRecordHeader writes the header of a file listing objects of
+// recordBucket.
+func writeRecordHeader(w io.Writer) error {
+	return writeRecord(w, recordHeaderMarker, strconv.Itoa(recordFormatVersion), recordBucket(),
+		time.Now().UTC().Format(time.RFC3339), runFilters())
+}
+
+// createRecordFile creates a timestamped output file like
+// createOutputFile and writes the header to it.
+func createRecordFile(name string) (*os.File, error) {
+	f, err := createOutputFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if err = writeRecordHeader(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	syncOnFlush(f)
+	return f, nil
+}
+
+func parseRecordHeader(fields []string) (*recordHeader, error) {
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("malformed header %q", strings.Join(fields, ","))
+	}
+	version, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed header version %q", fields[1])
+	}
+	generated, err := time.Parse(time.RFC3339, fields[3])
+	if err != nil {
+		return nil, fmt.Errorf("malformed header time %q", fields[3])
+	}
+	return &recordHeader{version: version, bucket: fields[2], generated: generated, filters: fields[4]}, nil
+}
+
+// recordScanner reads records with the same calling pattern as
+// bufio.Scanner.
+type recordScanner struct {
+	src io.Reader
+	cnt *countingReader
+	br  *bufio.Reader
+	r   *csv.Reader
+
+	header *recordHeader
+	// dataStart is the offset of the first record after the header.
+	dataStart int64
+	// pending is the first record when it is not a header.
+	pending []string
+	fields  []string
+	err     error
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// newRecordScanner reads the header of r right away so that it can be
+// checked before the first record is used.
+func newRecordScanner(r io.Reader) *recordScanner {
+	s := &recordScanner{src: r, cnt: &countingReader{r: r}}
+	// csv.Reader reads through br as is, so the bytes buffered in br are
+	// all that separates the count from the end of the last record.
+	s.br = bufio.NewReader(s.cnt)
+	s.r = newCSVReader(s.br)
+	fields, err := s.r.Read()
+	switch {
+	case err != nil:
+		s.err = err
+	case fields[0] == recordHeaderMarker:
+		s.header, s.err = parseRecordHeader(fields)
+		s.dataStart = s.Offset()
+	default:
+		s.pending = fields
+	}
+	return s
+}
+
+func newCSVReader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	return cr
+}
+
+// Offset returns the byte offset just past the current record.
+func (s *recordScanner) Offset() int64 {
+	return s.cnt.n - int64(s.br.Buffered())
+}
+
+// seek continues reading at offset, which should be the start of a record
+// past the header. The input has to be an io.Seeker.
+func (s *recordScanner) seek(offset int64) error {
+	seeker, ok := s.src.(io.Seeker)
+	if !ok {
+		return fmt.Errorf("input is not seekable")
+	}
+	if offset < s.dataStart {
+		offset = s.dataStart
+	}
+	if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	s.cnt.n = offset
+	s.br.Reset(s.cnt)
+	s.r = newCSVReader(s.br)
+	s.pending, s.fields, s.err = nil, nil, nil
+	return nil
+}
+
+// Scan advances to the next record, it returns false at the end of the
+// input or on error.
+func (s *recordScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	if s.pending != nil {
+		s.fields, s.pending = s.pending, nil
+		return true
+	}
+	s.fields, s.err = s.r.Read()
+	return s.err == nil
+}
+
+// Header returns the header of the file, nil when it has none.
+func (s *recordScanner) Header() *recordHeader {
+	return s.header
+}
+
+// checkHeader returns an error when the file name read by s was written
+// by a newer moveobject or for another bucket than recordBucket.
+func (s *recordScanner) checkHeader(name string) error {
+	if s.err != nil && s.err != io.EOF {
+		return fmt.Errorf("unable to read %s: %v", name, s.err)
+	}
+	h := s.header
+	if h == nil {
+		return nil
+	}
+	if h.version > recordFormatVersion {
+		return fmt.Errorf("%s has format version %d, this moveobject reads up to %d", name, h.version, recordFormatVersion)
+	}
+	if bucket := recordBucket(); h.bucket != bucket && !ignoreRecordHeader {
+		filters := ""
+		if h.filters != "" {
+			filters = " with " + h.filters
+		}
+		return fmt.Errorf("%s was generated %s from bucket %s%s, not %s, pass --ignore-header to use it anyway",
+			name, h.generated.Format(time.RFC3339), h.bucket, filters, bucket)
+	}
+	return nil
+}
+
+// Fields returns all the fields of the current record.
+func (s *recordScanner) Fields() []string {
+	return s.fields
+}
+
+// Text returns the object key of the current record, which is always
+// its last field.
+func (s *recordScanner) Text() string {
+	return s.fields[len(s.fields)-1]
+}
+
+// Err returns the first non-EOF error encountered.
+func (s *recordScanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
@@ -0,0 +1,171 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/console"
+)
+
+// redisPopTimeout is how long a BLMOVE waits before checking for
+// interruption, in seconds.
+const redisPopTimeout = 1
+
+var (
+	redisPool      *redis.Pool
+	redisResultKey string
+	// redisProcessingKey is the list holding the keys popped from
+	// --redis-queue until their object is done, empty unless consuming.
+	redisProcessingKey string
+)
+
+// objectResult is published to --redis-results for every object.
+type objectResult struct {
+	Object string    `json:"object"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	RunID  string    `json:"runId"`
+	Time   time.Time `json:"time"`
+}
+
+// initRedis sets up the redis connection pool when --redis-addr is set.
+func initRedis(cliCtx *cli.Context) {
+	addr := cliCtx.String("redis-addr")
+	if addr == "" {
+		return
+	}
+	redisResultKey = cliCtx.String("redis-results")
+	redisPool = &redis.Pool{
+		MaxIdle:     4,
+		IdleTimeout: time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+	}
+}
+
+// publishResult pushes the outcome of object to the --redis-results
+// list, this is a no-op unless result publishing is configured.
+func publishResult(object string, err error) {
+	if redisPool == nil || redisResultKey == "" {
+		return
+	}
+	res := objectResult{
+		Object: object,
+		Status: "success",
+		RunID:  runID,
+		Time:   time.Now().UTC(),
+	}
+	if err != nil {
+		res.Status = "failed"
+		res.Error = err.Error()
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return
+	}
+	conn := redisPool.Get()
+	defer conn.Close()
+	if _, err = conn.Do("RPUSH", redisResultKey, data); err != nil {
+		console.Errorln(fmt.Sprintf("unable to publish result of %s to redis: %v", object, err))
+	}
+}
+
+// ackRedisKey removes object from the processing list once its worker is
+// done with it, this is a no-op unless consuming from --redis-queue.
+func ackRedisKey(object string) {
+	if redisPool == nil || redisProcessingKey == "" {
+		return
+	}
+	conn := redisPool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("LREM", redisProcessingKey, 1, object); err != nil {
+		console.Errorln(fmt.Sprintf("unable to remove %s from redis list %s: %v", object, redisProcessingKey, err))
+	}
+}
+
+// queueFromRedis feeds object keys popped from the --redis-queue list to
+// the migration workers until interrupted. Each key is moved atomically to
+// the --redis-processing list and only removed from it when its object is
+// done, the keys a previous run left there are queued again first.
+func queueFromRedis(ctx context.Context, cliCtx *cli.Context) error {
+	queue := cliCtx.String("redis-queue")
+	processing := cliCtx.String("redis-processing")
+	if processing == "" {
+		processing = queue + ":processing"
+	}
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	// Put the keys of an interrupted run back at the head of the queue in
+	// their original order.
+	requeued := 0
+	for {
+		_, err := redis.String(conn.Do("LMOVE", processing, queue, "RIGHT", "LEFT"))
+		if err == redis.ErrNil {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		requeued++
+	}
+	if requeued > 0 {
+		logMsg(fmt.Sprintf("queued %d unfinished object keys from redis list %s again", requeued, processing))
+	}
+	redisProcessingKey = processing
+
+	stopCh := make(chan os.Signal, 1)
+	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stopCh)
+
+	logMsg(fmt.Sprintf("consuming object keys from redis list %s", queue))
+	for {
+		select {
+		case <-stopCh:
+			logMsg("stopping redis consumer")
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		// Stop before popping a key --limit would leave out, only the
+		// keys actually popped count toward it.
+		if limitReached() {
+			return nil
+		}
+		o, err := redis.String(conn.Do("BLMOVE", queue, processing, "LEFT", "RIGHT", redisPopTimeout))
+		if err == redis.ErrNil {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		admit()
+		migrationState.queueUploadTask(o)
+		logDMsg(fmt.Sprintf("adding %s to migration queue", o), nil)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/console"
+)
+
+var renameFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "mapping-file",
+		Usage: "file of \"old-key,new-key\" records naming the new key of each object",
+	},
+	cli.BoolFlag{
+		Name:  "fake",
+		Usage: "perform a fake rename",
+	},
+}
+
+var renameCmd = cli.Command{
+	Name:   "rename",
+	Usage:  "rename objects as listed in a mapping file",
+	Action: renameAction,
+	Flags:  append(append(append(append(allFlags, renameFlags...), overwriteFlags...), confirmFlags...), encryptionFlags...),
+	CustomHelpTemplate: `NAME:
+	 {{.HelpName}} - {{.Usage}}
+ 
+ USAGE:
+	 {{.HelpName}} --mapping-file FILE [--fake]
+ 
+ DESCRIPTION:
+	Each object of the mapping file is copied server side to its new key in
+	the same bucket and then removed from its old key, for renames the
+	rules of move and copy cannot express.
+ 
+ FLAGS:
+	{{range .VisibleFlags}}{{.}}
+	{{end}}
+ 
+ EXAMPLES:
+ 1. Rename the objects listed in "renames.csv" in MinIO.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject rename --data-dir /tmp/ --mapping-file renames.csv
+
+ 2. Perform a dry run of the renames in "renames.csv" that leaves existing keys alone.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject rename --data-dir /tmp/ --mapping-file renames.csv --no-overwrite --fake --log
+ `,
+}
+
+// readMappingFile returns the "old-key,new-key" records of name as rename
+// tasks. Keys renamed twice or onto the same new key are refused.
+func readMappingFile(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var tasks []string
+	from := make(map[string]struct{})
+	to := make(map[string]string)
+	scanner := newRecordScanner(file)
+	if err := scanner.checkHeader(name); err != nil {
+		return nil, err
+	}
+	for scanner.Scan() {
+		fields := scanner.Fields()
+		if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+			return nil, fmt.Errorf("invalid record %q in %s, expected old-key,new-key", formatRecord(fields...), name)
+		}
+		object, key := fields[0], fields[1]
+		if object == key {
+			logDMsg(fmt.Sprintf("skipping %s, renamed to itself", object), nil)
+			continue
+		}
+		if _, ok := from[object]; ok {
+			return nil, fmt.Errorf("%s is renamed more than once in %s", object, name)
+		}
+		if prev, ok := to[key]; ok {
+			return nil, fmt.Errorf("%s and %s are both renamed to %s in %s", prev, object, key, name)
+		}
+		from[object], to[key] = struct{}{}, object
+		if !admit() {
+			break
+		}
+		tasks = append(tasks, formatRecord(object, key))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func renameAction(cliCtx *cli.Context) (err error) {
+	checkArgsAndInit(cliCtx)
+	ctx := context.Background()
+	dryRun = cliCtx.Bool("fake")
+	mappingFile := cliCtx.String("mapping-file")
+	if mappingFile == "" {
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name)
+		console.Fatalln("--mapping-file is required")
+	}
+	logMsg("Init minio client..")
+	if err := initMinioClient(cliCtx); err != nil {
+		logDMsg("Unable to  initialize MinIO client, exiting...%w", err)
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
+		console.Fatalln(err)
+	}
+	if err = parseEncryption(cliCtx); err != nil {
+		console.Fatalln(err)
+	}
+	tasks, err := readMappingFile(mappingFile)
+	if err != nil {
+		logDMsg("could not read "+mappingFile, err)
+		return err
+	}
+	confirmDestructive(cliCtx, "rename", len(tasks), minioBucket)
+
+	renState = newRenameState(ctx)
+	renState.init(ctx)
+	start := time.Now()
+	defer func() { reportRun("rename", renState, start, err) }()
+	if err = openConflictReport(conflictRenameFile); err != nil {
+		return err
+	}
+	defer conflicts.close()
+	stopProbe := startProbe(ctx, minioClient, minioBucket)
+	logMsg("renaming objects in " + mappingFile)
+	for _, task := range tasks {
+		renState.queueUploadTask(task)
+		logDMsg(fmt.Sprintf("adding %s to rename queue", task), nil)
+	}
+	renState.finish(ctx)
+	stopProbe()
+	logMsg("successfully completed rename.")
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+var renState *taskPool
+
+func newRenameState(ctx context.Context) *taskPool {
+	return newTaskPool(poolTasks{
+		action: "Renaming",
+		// Tasks are "old-key,new-key" records.
+		fields: 2,
+		run: func(ctx context.Context, fields []string) error {
+			return renameObject(ctx, fields[0], fields[1])
+		},
+		failFile:    failRenameFile,
+		successFile: successRenameFile,
+		done:        "Renamed %d objects, %d failures",
+	})
+}
+
+// renameObject copies the latest version of object to key and removes
+// that version from object.
+func renameObject(ctx context.Context, object, key string) error {
+	stat, sse, err := statEncrypted(ctx, object, "")
+	if err != nil {
+		return err
+	}
+	reason, err := checkConflict(ctx, minioBucket, key, stat)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		conflicts.record(object, reason)
+		return nil
+	}
+	if dryRun {
+		logMsg(migrateMsg(object, key))
+		return nil
+	}
+
+	src := miniogo.CopySrcOptions{
+		Bucket:    minioBucket,
+		Object:    object,
+		VersionID: stat.VersionID,
+	}
+	dst := miniogo.CopyDestOptions{
+		Bucket: minioBucket,
+		Object: key,
+	}
+	applyEncryption(&src, &dst, sse)
+
+	copyCtx, cancel := opContext(ctx)
+	_, err = minioClient.CopyObject(copyCtx, dst, src)
+	cancel()
+	if err != nil {
+		logDMsg("copy to "+key+" failed for "+object, err)
+		return err
+	}
+	removeCtx, cancel := opContext(ctx)
+	err = minioClient.RemoveObject(removeCtx, minioBucket, object, miniogo.RemoveObjectOptions{VersionID: stat.VersionID})
+	cancel()
+	if err != nil {
+		logDMsg("removeObject failed for "+object, err)
+		return err
+	}
+	logDMsg("Renamed "+object+" to "+key+" successfully", nil)
+	return nil
+}
@@ -0,0 +1,199 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio/pkg/console"
+)
+
+// summaryFile is rewritten at the end of every run.
+const summaryFile = "summary.json"
+
+var (
+	reportMu    sync.Mutex
+	outputFiles []string
+	failures    = make(map[string]uint64)
+	runFlags    map[string]string
+)
+
+// createOutputFile creates a timestamped file named name in the run
+// directory and records it for the run summary.
+func createOutputFile(name string) (*os.File, error) {
+	p, err := runPath(name + time.Now().Format(".01-02-2006-15-04-05"))
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	reportMu.Lock()
+	outputFiles = append(outputFiles, p)
+	reportMu.Unlock()
+	return f, nil
+}
+
+// failureReason classifies err for the failure breakdown of the summary.
+func failureReason(err error) string {
+	// Errors of a single version are wrapped.
+	var resp miniogo.ErrorResponse
+	if errors.As(err, &resp) && resp.Code != "" {
+		return resp.Code
+	}
+	if errors.Is(err, errTransferStalled) {
+		return "Stalled"
+	}
+	if errors.Is(err, errChecksumMismatch) {
+		return "ChecksumMismatch"
+	}
+	if errors.Is(err, errChecksumNotForwarded) {
+		return "ChecksumNotForwarded"
+	}
+	if errors.Is(err, errKeyCollision) {
+		return "KeyCollision"
+	}
+	if errors.Is(err, errNothingToStrip) {
+		return "NothingToStrip"
+	}
+	if errors.Is(err, errCopyReplaced) {
+		return "CopyReplaced"
+	}
+	if errors.Is(err, errCopyNotRecorded) {
+		return "CopyNotRecorded"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "Timeout"
+	}
+	return "Other"
+}
+
+// countFailure adds a failure to the breakdown of the summary.
+func countFailure(reason string) {
+	reportMu.Lock()
+	failures[reason]++
+	reportMu.Unlock()
+}
+
+// sensitiveFlags are left out of the summary.
+var sensitiveFlags = map[string]bool{
+	"secret-key":     true,
+	"src-secret-key": true,
+	"src-sse-c-key":  true,
+	"dst-sse-c-key":  true,
+	"slack-webhook":  true,
+	"teams-webhook":  true,
+	"smtp-password":  true,
+	"cse-key":        true,
+}
+
+// collectFlags returns the flags set on the command line or through the
+// environment.
+func collectFlags(ctx *cli.Context) map[string]string {
+	flags := make(map[string]string)
+	for _, f := range ctx.Command.Flags {
+		name := strings.TrimSpace(strings.Split(f.GetName(), ",")[0])
+		if !ctx.IsSet(name) {
+			continue
+		}
+		var value string
+		switch f.(type) {
+		case cli.BoolFlag:
+			value = strconv.FormatBool(ctx.Bool(name))
+		case cli.IntFlag:
+			value = strconv.Itoa(ctx.Int(name))
+		case cli.Int64Flag:
+			value = strconv.FormatInt(ctx.Int64(name), 10)
+		case cli.DurationFlag:
+			value = ctx.Duration(name).String()
+		case cli.StringSliceFlag:
+			value = strings.Join(ctx.StringSlice(name), ",")
+		default:
+			value = ctx.String(name)
+		}
+		if sensitiveFlags[name] {
+			value = "REDACTED"
+		}
+		flags[name] = value
+	}
+	return flags
+}
+
+// reportRun writes the run summary to summary.json in the run directory,
+// with a copy in data-dir for the latest run, records the end of the run in
+// the manifest and posts the summary to --notify-url.
+func reportRun(command string, c progressCounter, start time.Time, runErr error) {
+	closeFailureClasses()
+	closeNormalizedKeys()
+	s := newRunSummary(command, c, start, runErr)
+	reportMu.Lock()
+	s.Flags = runFlags
+	s.FailureReasons = make(map[string]uint64, len(failures))
+	for reason, n := range failures {
+		s.FailureReasons[reason] = n
+	}
+	s.Files = append([]string(nil), outputFiles...)
+	// The next pass of --every or --src-buckets reports its own.
+	failures = make(map[string]uint64)
+	outputFiles = nil
+	reportMu.Unlock()
+	sort.Strings(s.Files)
+	setExitCode(s)
+
+	if reportFormat != "" {
+		sampleTimeline(c, true)
+		name, err := writeRunReport(s)
+		if err != nil {
+			console.Errorln(fmt.Sprintf("unable to write run report: %v", err))
+		} else {
+			s.Files = append(s.Files, name)
+		}
+	}
+
+	body, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		console.Errorln(fmt.Sprintf("unable to encode run summary: %v", err))
+		return
+	}
+	name, err := runPath(summaryFile)
+	for _, name := range []string{name, path.Join(dirPath, summaryFile)} {
+		if err == nil {
+			err = ioutil.WriteFile(name, append(body, '\n'), 0600)
+		}
+	}
+	if err != nil {
+		console.Errorln(fmt.Sprintf("unable to write %s: %v", summaryFile, err))
+	}
+	endRun(s)
+	notifyRun(s)
+	chatRun(s)
+	mailRun(s)
+}
@@ -0,0 +1,150 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+const reprotectedFile = "reprotected.txt"
+
+// isSSEC returns whether stat is of an object encrypted with SSE-C.
+func isSSEC(stat miniogo.ObjectInfo) bool {
+	return stat.Metadata.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != ""
+}
+
+// getSource opens versionID of object in the source bucket, again with
+// --src-sse-c-key when the plain request fails.
+func getSource(ctx context.Context, object, versionID string) (*miniogo.Object, miniogo.ObjectInfo, error) {
+	opts := miniogo.GetObjectOptions{VersionID: versionID}
+	if forwardChecksums {
+		opts.Set(checksumModeHeader, "ENABLED")
+	}
+	rctx, opened := openContext(ctx)
+	r, err := minioSrcClient.GetObject(rctx, minioSrcBucket, object, opts)
+	if err != nil {
+		opened()
+		return nil, miniogo.ObjectInfo{}, err
+	}
+	stat, err := r.Stat()
+	opened()
+	if err == nil {
+		return r, stat, nil
+	}
+	r.Close()
+	if srcSSEC == nil {
+		return nil, miniogo.ObjectInfo{}, err
+	}
+	opts.ServerSideEncryption = srcSSEC
+	rctx, opened = openContext(ctx)
+	kr, kerr := minioSrcClient.GetObject(rctx, minioSrcBucket, object, opts)
+	if kerr != nil {
+		opened()
+		return nil, miniogo.ObjectInfo{}, err
+	}
+	stat, kerr = kr.Stat()
+	opened()
+	if kerr != nil {
+		kr.Close()
+		return nil, miniogo.ObjectInfo{}, err
+	}
+	return kr, stat, nil
+}
+
+// statSource stats versionID of object in the source bucket, again with
+// --src-sse-c-key when the plain stat fails.
+func statSource(ctx context.Context, object, versionID string) (miniogo.ObjectInfo, error) {
+	sctx, cancel := opContext(ctx)
+	defer cancel()
+	opts := miniogo.StatObjectOptions{VersionID: versionID}
+	stat, err := minioSrcClient.StatObject(sctx, minioSrcBucket, object, opts)
+	if err == nil || srcSSEC == nil {
+		return stat, err
+	}
+	opts.ServerSideEncryption = srcSSEC
+	kctx, kcancel := opContext(ctx)
+	defer kcancel()
+	if kstat, kerr := minioSrcClient.StatObject(kctx, minioSrcBucket, object, opts); kerr == nil {
+		return kstat, nil
+	}
+	return stat, err
+}
+
+// sourceEncryption returns the key to read the object stat describes with,
+// nil when it is not SSE-C encrypted.
+func sourceEncryption(stat miniogo.ObjectInfo) encrypt.ServerSide {
+	if isSSEC(stat) {
+		return srcSSEC
+	}
+	return nil
+}
+
+// reprotectReport writes "encryption,bucket,object,srcObject" records of
+// the SSE-C source objects whose copies are encrypted with SSE-S3 or
+// SSE-KMS instead.
+type reprotectReport struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+var reprotected *reprotectReport
+
+// openReprotectReport creates the report in the run directory when
+// --src-sse-c-key is set.
+func openReprotectReport() error {
+	reprotected = nil
+	if srcSSEC == nil {
+		return nil
+	}
+	f, err := createOutputFile(reprotectedFile)
+	if err != nil {
+		logDMsg("could not create "+reprotectedFile, err)
+		return err
+	}
+	reprotected = &reprotectReport{f: f}
+	return nil
+}
+
+// record notes the copy of object in bucket/key when the source stat
+// describes was SSE-C encrypted.
+func (r *reprotectReport) record(object, bucket, key string, stat miniogo.ObjectInfo) {
+	if r == nil || !isSSEC(stat) {
+		return
+	}
+	scheme := "none"
+	if sse := destinationEncryption(bucket); sse != nil {
+		scheme = "SSE-" + string(sse.Type())
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := writeRecord(r.f, scheme, bucket, key, object); err != nil {
+		logMsg(fmt.Sprintf("Error writing to %s for %s: %s", reprotectedFile, object, err))
+		os.Exit(exitAborted)
+	}
+}
+
+func (r *reprotectReport) close() {
+	if r != nil {
+		r.f.Close()
+	}
+}
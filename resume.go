@@ -0,0 +1,77 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// resumeFromDest set by --resume-from-dest skips input objects whose copy
+// is already listed at the destination.
+var resumeFromDest bool
+
+// presentKeys holds "bucket/key" of every object listed at the
+// destination by loadPresentKeys.
+var presentKeys map[string]struct{}
+
+// loadPresentKeys lists the destination buckets into presentKeys when
+// --resume-from-dest is set.
+func loadPresentKeys(ctx context.Context) error {
+	presentKeys = nil
+	if !resumeFromDest {
+		return nil
+	}
+	keys := make(map[string]struct{})
+	seen := make(map[string]bool)
+	for _, bucket := range destBuckets() {
+		if seen[bucket] {
+			continue
+		}
+		seen[bucket] = true
+		for object := range listBucket(ctx, minioClient, bucket) {
+			if object.Err != nil {
+				return fmt.Errorf("unable to list destination bucket %s: %w", bucket, object.Err)
+			}
+			if object.IsLatest && !object.IsDeleteMarker {
+				keys[bucket+"/"+object.Key] = struct{}{}
+			}
+		}
+	}
+	logMsg(fmt.Sprintf("%d objects already at the destination", len(keys)))
+	presentKeys = keys
+	return nil
+}
+
+// presentAtDestination returns whether the copy of object was listed at the
+// destination, in any of the buckets its route allows.
+func presentAtDestination(object string) bool {
+	if presentKeys == nil {
+		return false
+	}
+	buckets, err := candidateBuckets(object, -1)
+	if err != nil {
+		return false
+	}
+	key := convert(object)
+	for _, bucket := range buckets {
+		if _, ok := presentKeys[bucket+"/"+key]; ok {
+			return true
+		}
+	}
+	return false
+}
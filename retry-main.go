@@ -0,0 +1,216 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio/pkg/console"
+)
+
+var retryFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "operation, o",
+		Usage: "operation to retry, one of migrate, move, copy or delete",
+	},
+	cli.StringFlag{
+		Name:  "fail-file",
+		Usage: "failure file to retry, defaults to the latest one of the operation in data directory",
+	},
+	cli.BoolFlag{
+		Name:  "fake",
+		Usage: "perform a fake retry",
+	},
+}
+
+var retryCmd = cli.Command{
+	Name:   "retry",
+	Usage:  "retry objects recorded in a failure file",
+	Action: retryAction,
+	Flags:  append(append(allFlags, sourceFlags...), retryFlags...),
+	CustomHelpTemplate: `NAME:
+	 {{.HelpName}} - {{.Usage}}
+ 
+ USAGE:
+	 {{.HelpName}} --operation OPERATION [--fail-file FILE, --fake]
+ 
+ FLAGS:
+	{{range .VisibleFlags}}{{.}}
+	{{end}}
+ 
+ EXAMPLES:
+ 1. Retry the objects in the latest "migration_fails.txt" file, remaining failures go to a new failure file.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+	$ export MINIO_SOURCE_ACCESS_KEY=minio
+	$ export MINIO_SOURCE_SECRET_KEY=minio123
+	$ export MINIO_DEST_BUCKET_1=dstbucket1
+	$ export MINIO_DEST_BUCKET_2=dstbucket2
+	$ export MINIO_DEST_BUCKET_3=dstbucket3
+	$ export MINIO_DEST_BUCKET_4=dstbucket4
+	$ export MINIO_SOURCE_BUCKET=srcbucket
+	$ moveobject retry --data-dir /tmp/ --operation migrate
+
+ 2. Retry the objects of a specific move failure file.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject retry --data-dir /tmp/ --operation move --fail-file /tmp/move_fails.txt.03-01-2021-22-00-00
+ `,
+}
+
+// taskQueue is implemented by all the state machines.
+type taskQueue interface {
+	queueUploadTask(obj string)
+	finish(ctx context.Context)
+}
+
+// latestFile returns the most recently modified file in data-dir or its run
+// directories named name, with or without a timestamp suffix.
+func latestFile(name string) (string, error) {
+	files, err := artifactFiles(name + "*")
+	if err != nil {
+		return "", err
+	}
+	var latest string
+	var latestInfo os.FileInfo
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return "", err
+		}
+		if latestInfo == nil || fi.ModTime().After(latestInfo.ModTime()) {
+			latest, latestInfo = f, fi
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no %s found in %s", name, dirPath)
+	}
+	return latest, nil
+}
+
+func retryAction(cliCtx *cli.Context) error {
+	checkArgsAndInit(cliCtx)
+	ctx := context.Background()
+	dryRun = cliCtx.Bool("fake")
+
+	var failFile string
+	var initClient func(*cli.Context) error
+	switch op := cliCtx.String("operation"); op {
+	case "migrate":
+		failFile, initClient = failMigFile, initMinioClients
+	case "move":
+		failFile, initClient = failMoveFile, initMinioClient
+	case "copy":
+		failFile, initClient = failCopyFile, initMinioClient
+	case "delete":
+		failFile, initClient = failDeleteFile, initMinioClient
+	default:
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name)
+		console.Fatalln(fmt.Errorf("unknown operation %q, should be one of migrate, move, copy or delete", op))
+	}
+	if cliCtx.String("fail-file") != "" {
+		failFile = cliCtx.String("fail-file")
+	} else {
+		var err error
+		// Pick the failure file before the new run creates its own.
+		if failFile, err = latestFile(failFile); err != nil {
+			console.Fatalln(err)
+		}
+	}
+
+	logMsg("Init minio client..")
+	if err := initClient(cliCtx); err != nil {
+		logDMsg("Unable to  initialize MinIO client, exiting...%w", err)
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
+		console.Fatalln(err)
+	}
+
+	var state taskQueue
+	switch cliCtx.String("operation") {
+	case "migrate":
+		migrationState = newMigrationState(ctx)
+		migrationState.init(ctx)
+		state = migrationState
+	case "move":
+		mvState = newMoveState(ctx)
+		mvState.init(ctx)
+		state = mvState
+	case "copy":
+		cpState = newCopyState(ctx)
+		cpState.init(ctx)
+		state = cpState
+	case "delete":
+		delState = newDeleteState(ctx)
+		delState.init(ctx)
+		state = delState
+	}
+
+	file, err := os.Open(failFile)
+	if err != nil {
+		logDMsg(fmt.Sprintf("could not open file :%s ", failFile), err)
+		return err
+	}
+	defer file.Close()
+	logMsg("retrying objects in " + failFile)
+
+	scanner := newRecordScanner(file)
+	if err := scanner.checkHeader(failFile); err != nil {
+		return err
+	}
+	for scanner.Scan() {
+		o := scanner.Text()
+		if mvState != nil {
+			// move failures record only the key, move needs the
+			// version to relocate and remove.
+			statCtx, cancel := opContext(ctx)
+			stat, err := minioClient.StatObject(statCtx, minioBucket, o, miniogo.StatObjectOptions{})
+			cancel()
+			if err != nil {
+				logMsg(fmt.Sprintf("error looking up object %s: %s", o, err))
+				mvState.incFailCount()
+				mvState.failedCh <- o
+				continue
+			}
+			o = formatRecord(stat.VersionID, o)
+		}
+		if delState != nil {
+			// delete failures of a version record it with the key.
+			o = formatRecord(scanner.Fields()...)
+		}
+		if !admit() {
+			break
+		}
+		state.queueUploadTask(o)
+		logDMsg(fmt.Sprintf("adding %s to retry queue", o), nil)
+	}
+	if err := scanner.Err(); err != nil {
+		logDMsg(fmt.Sprintf("error processing file :%s ", failFile), err)
+		return err
+	}
+	state.finish(ctx)
+	logMsg("successfully completed retry.")
+
+	return nil
+}
@@ -0,0 +1,93 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+var (
+	// retries set by --retries is the number of times an operation failing
+	// with a transient error is retried in place before the object is
+	// recorded as failed.
+	retries = 3
+	// retryBackoff is the wait before the first retry, doubled for every
+	// further one up to retryMaxBackoff.
+	retryBackoff    = time.Second
+	retryMaxBackoff = 30 * time.Second
+)
+
+// isTransient returns whether err may go away when the operation is
+// retried, e.g. a dropped connection, a timeout, a 5xx response or an
+// expired token, for which the credentials are renewed. Permanent errors
+// like 404 and 403 and stalled transfers, which --stall-retries covers,
+// are not.
+func isTransient(err error) bool {
+	var resp miniogo.ErrorResponse
+	if errors.As(err, &resp) && (resp.Code != "" || resp.StatusCode != 0) {
+		switch resp.Code {
+		case "SlowDown", "InternalError", "ServiceUnavailable", "RequestTimeout", "OperationAborted":
+			return true
+		}
+		if isExpiredToken(err) {
+			return true
+		}
+		return resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+	}
+	if errors.Is(err, errTransferStalled) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
+
+// retryTransient calls fn again up to --retries times while it fails with a
+// transient error, waiting a jittered exponential backoff in between.
+func retryTransient(ctx context.Context, object string, fn func() error) error {
+	err := fn()
+	backoff := retryBackoff
+	for i := 0; i < retries && isTransient(err); i++ {
+		// The jitter keeps the workers that failed together, e.g. on a
+		// restart of the destination, from retrying together.
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		logMsg(fmt.Sprintf("retrying %s in %s after transient error: %s", object, wait.Round(time.Millisecond), err))
+		if isExpiredToken(err) {
+			expireCredentials()
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > retryMaxBackoff {
+			backoff = retryMaxBackoff
+		}
+		err = fn()
+	}
+	return err
+}
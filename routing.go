@@ -0,0 +1,154 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Destination bucket routing strategies of --route.
+const (
+	routePrefix     = "prefix"
+	routeHash       = "hash"
+	routeRoundRobin = "round-robin"
+	routeSize       = "size"
+)
+
+var (
+	// route set by --route chooses the destination bucket of objects.
+	route = routePrefix
+	// routeTiers set by --route-size-tiers are the upper bounds of the
+	// object sizes of the first three buckets.
+	routeTiers []int64
+	// routeNext is the next bucket of round-robin routing.
+	routeNext uint64
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// parseRoute validates the routing strategy and its size tiers.
+func parseRoute(strategy, tiers string) (string, []int64, error) {
+	switch strategy {
+	case "":
+		return routePrefix, nil, nil
+	case routePrefix, routeHash, routeRoundRobin:
+		return strategy, nil, nil
+	case routeSize:
+	default:
+		return "", nil, fmt.Errorf("unknown --route %q, should be one of prefix, hash, round-robin or size", strategy)
+	}
+	parts := strings.Split(tiers, ",")
+	if len(parts) != 3 {
+		return "", nil, fmt.Errorf("invalid --route-size-tiers %q, expected three sizes e.g. 1MiB,16MiB,256MiB", tiers)
+	}
+	bounds := make([]int64, 0, len(parts))
+	for _, p := range parts {
+		n, err := humanize.ParseBytes(strings.TrimSpace(p))
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid --route-size-tiers %q: %v", tiers, err)
+		}
+		if len(bounds) > 0 && int64(n) <= bounds[len(bounds)-1] {
+			return "", nil, fmt.Errorf("invalid --route-size-tiers %q, sizes should be increasing", tiers)
+		}
+		bounds = append(bounds, int64(n))
+	}
+	return routeSize, bounds, nil
+}
+
+// destBuckets returns the four destination buckets in order.
+func destBuckets() []string {
+	return []string{minioDstBucket1, minioDstBucket2, minioDstBucket3, minioDstBucket4}
+}
+
+// destBucket returns the destination bucket of object of size bytes
+// according to --route.
+func destBucket(object string, size int64) (string, error) {
+	buckets := destBuckets()
+	switch route {
+	case routeHash:
+		return buckets[crc32.Checksum([]byte(object), castagnoli)%uint32(len(buckets))], nil
+	case routeRoundRobin:
+		return buckets[(atomic.AddUint64(&routeNext, 1)-1)%uint64(len(buckets))], nil
+	case routeSize:
+		for i, bound := range routeTiers {
+			if size < bound {
+				return buckets[i], nil
+			}
+		}
+		return buckets[len(buckets)-1], nil
+	}
+	return prefixBucket(object)
+}
+
+// candidateBuckets returns the buckets the copy of object may be in, all
+// of them when the route cannot be recomputed. A negative size means the
+// size is unknown.
+func candidateBuckets(object string, size int64) ([]string, error) {
+	if route == routeRoundRobin || (route == routeSize && size < 0) {
+		var buckets []string
+		seen := make(map[string]bool)
+		for _, bucket := range destBuckets() {
+			if !seen[bucket] {
+				seen[bucket] = true
+				buckets = append(buckets, bucket)
+			}
+		}
+		return buckets, nil
+	}
+	bucket, err := destBucket(object, size)
+	if err != nil {
+		return nil, err
+	}
+	return []string{bucket}, nil
+}
+
+// prefixBucket returns the destination bucket of object by its numbered
+// prefix.
+func prefixBucket(object string) (string, error) {
+	result := strings.SplitN(object, "/", 2)
+	if len(result) != 2 {
+		fmt.Println("Unable to get prefix for object: ", object)
+		return "", errors.New("Unable to get prefix for object: " + object)
+	}
+	prefix, err := strconv.Atoi(result[0])
+	if err != nil {
+		fmt.Println(err)
+		return "", err
+	}
+
+	var bucket string
+	if prefix > -1 && prefix < 250 {
+		bucket = minioDstBucket1
+	} else if prefix > 249 && prefix < 500 {
+		bucket = minioDstBucket2
+	} else if prefix > 499 && prefix < 750 {
+		bucket = minioDstBucket3
+	} else if prefix > 749 && prefix < 1000 {
+		bucket = minioDstBucket4
+	} else {
+		fmt.Println("unknown prefix for object: ", object)
+		return "", errors.New("Unable to get prefix for object: " + object)
+	}
+	return bucket, nil
+}
@@ -0,0 +1,300 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	runReportFile = "run_report"
+	// timelineStep is the minimum interval between throughput samples.
+	timelineStep = 30 * time.Second
+	// largestFailed is the number of failed objects listed by size.
+	largestFailed = 20
+)
+
+// reportFormat set by --report is html or csv, empty writes no report.
+var reportFormat string
+
+// throughputSample is a point of the throughput timeline.
+type throughputSample struct {
+	at             time.Time
+	objects, bytes uint64
+}
+
+var timeline []throughputSample
+
+// sampleTimeline adds a throughput sample, at most one per timelineStep
+// unless force is set.
+func sampleTimeline(c progressCounter, force bool) {
+	if reportFormat == "" {
+		return
+	}
+	now := time.Now()
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	if !force && len(timeline) > 0 && now.Sub(timeline[len(timeline)-1].at) < timelineStep {
+		return
+	}
+	timeline = append(timeline, throughputSample{at: now, objects: c.getCount(), bytes: c.getBytes()})
+}
+
+// prefixCount counts the outcomes under a top level prefix.
+type prefixCount struct {
+	Prefix            string
+	Success, Failures int
+}
+
+type reasonCount struct {
+	Reason string
+	Count  uint64
+}
+
+type failedObject struct {
+	Key  string
+	Size int64
+}
+
+type rateRow struct {
+	At          string
+	ObjectsRate string
+	BytesRate   string
+}
+
+// runReport is rendered as HTML or CSV.
+type runReport struct {
+	Summary  runSummary
+	Prefixes []prefixCount
+	Reasons  []reasonCount
+	Largest  []failedObject
+	Timeline []rateRow
+}
+
+// outputKind returns "success" or "fail" for success and fail files.
+func outputKind(name string) string {
+	base := filepath.Base(name)
+	switch {
+	case strings.Contains(base, "_success.txt"):
+		return "success"
+	case strings.Contains(base, "_fails.txt"):
+		return "fail"
+	}
+	return ""
+}
+
+// topPrefix returns the first path segment of key.
+func topPrefix(key string) string {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i+1]
+	}
+	return key
+}
+
+// buildRunReport reads the success and fail files of the run back.
+func buildRunReport(s runSummary) (runReport, error) {
+	r := runReport{Summary: s}
+	prefixes := make(map[string]*prefixCount)
+	var failed []string
+	for _, name := range s.Files {
+		kind := outputKind(name)
+		if kind == "" {
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return r, err
+		}
+		scanner := newRecordScanner(f)
+		for scanner.Scan() {
+			key := scanner.Text()
+			p := prefixes[topPrefix(key)]
+			if p == nil {
+				p = &prefixCount{Prefix: topPrefix(key)}
+				prefixes[p.Prefix] = p
+			}
+			if kind == "success" {
+				p.Success++
+			} else {
+				p.Failures++
+				failed = append(failed, key)
+			}
+		}
+		f.Close()
+		if err = scanner.Err(); err != nil {
+			return r, err
+		}
+	}
+	for _, p := range prefixes {
+		r.Prefixes = append(r.Prefixes, *p)
+	}
+	sort.Slice(r.Prefixes, func(i, j int) bool { return r.Prefixes[i].Prefix < r.Prefixes[j].Prefix })
+
+	for reason, n := range s.FailureReasons {
+		r.Reasons = append(r.Reasons, reasonCount{Reason: reason, Count: n})
+	}
+	sort.Slice(r.Reasons, func(i, j int) bool {
+		if r.Reasons[i].Count != r.Reasons[j].Count {
+			return r.Reasons[i].Count > r.Reasons[j].Count
+		}
+		return r.Reasons[i].Reason < r.Reasons[j].Reason
+	})
+
+	// Sizes of failed objects are only known from an extended listing.
+	if sizes, extended, err := loadListing(); err == nil && extended {
+		for _, key := range failed {
+			r.Largest = append(r.Largest, failedObject{Key: key, Size: sizes[key]})
+		}
+		sort.Slice(r.Largest, func(i, j int) bool { return r.Largest[i].Size > r.Largest[j].Size })
+		if len(r.Largest) > largestFailed {
+			r.Largest = r.Largest[:largestFailed]
+		}
+	}
+
+	reportMu.Lock()
+	samples := append([]throughputSample(nil), timeline...)
+	reportMu.Unlock()
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		elapsed := cur.at.Sub(prev.at).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		r.Timeline = append(r.Timeline, rateRow{
+			At:          cur.at.UTC().Format(time.RFC3339),
+			ObjectsRate: fmt.Sprintf("%.1f", float64(cur.objects-prev.objects)/elapsed),
+			BytesRate:   humanize.IBytes(uint64(float64(cur.bytes-prev.bytes)/elapsed)) + "/s",
+		})
+	}
+	return r, nil
+}
+
+var runReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"bytes": func(n interface{}) string {
+		switch v := n.(type) {
+		case uint64:
+			return humanize.IBytes(v)
+		case int64:
+			return humanize.IBytes(uint64(v))
+		}
+		return fmt.Sprint(n)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>moveobject {{.Summary.Command}} {{.Summary.RunID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #eee; }
+</style>
+</head>
+<body>
+<h1>moveobject {{.Summary.Command}}: {{.Summary.Status}}</h1>
+<table>
+<tr><th>Run</th><td>{{.Summary.RunID}}</td></tr>
+<tr><th>Start</th><td>{{.Summary.Start}}</td></tr>
+<tr><th>Duration</th><td>{{.Summary.Duration}}</td></tr>
+<tr><th>Objects</th><td>{{.Summary.Objects}}</td></tr>
+<tr><th>Failures</th><td>{{.Summary.Failures}}</td></tr>
+<tr><th>Bytes</th><td>{{bytes .Summary.Bytes}}</td></tr>
+{{if .Summary.Error}}<tr><th>Error</th><td>{{.Summary.Error}}</td></tr>{{end}}
+</table>
+<h2>Prefixes</h2>
+<table>
+<tr><th>Prefix</th><th>Succeeded</th><th>Failed</th></tr>
+{{range .Prefixes}}<tr><td>{{.Prefix}}</td><td>{{.Success}}</td><td>{{.Failures}}</td></tr>
+{{end}}</table>
+<h2>Failure reasons</h2>
+<table>
+<tr><th>Reason</th><th>Objects</th></tr>
+{{range .Reasons}}<tr><td>{{.Reason}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+{{if .Largest}}<h2>Largest failed objects</h2>
+<table>
+<tr><th>Object</th><th>Size</th></tr>
+{{range .Largest}}<tr><td>{{.Key}}</td><td>{{bytes .Size}}</td></tr>
+{{end}}</table>
+{{end}}<h2>Throughput</h2>
+<table>
+<tr><th>Time</th><th>Objects/s</th><th>Bytes/s</th></tr>
+{{range .Timeline}}<tr><td>{{.At}}</td><td>{{.ObjectsRate}}</td><td>{{.BytesRate}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// writeCSV writes the report as "section,name,value,value" records.
+func (r runReport) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	s := r.Summary
+	cw.Write([]string{"section", "name", "value", "value"})
+	cw.Write([]string{"summary", "command", s.Command, ""})
+	cw.Write([]string{"summary", "status", s.Status, ""})
+	cw.Write([]string{"summary", "duration", s.Duration, ""})
+	cw.Write([]string{"summary", "objects", strconv.FormatUint(s.Objects, 10), ""})
+	cw.Write([]string{"summary", "failures", strconv.FormatUint(s.Failures, 10), ""})
+	cw.Write([]string{"summary", "bytes", strconv.FormatUint(s.Bytes, 10), ""})
+	for _, p := range r.Prefixes {
+		cw.Write([]string{"prefix", p.Prefix, strconv.Itoa(p.Success), strconv.Itoa(p.Failures)})
+	}
+	for _, reason := range r.Reasons {
+		cw.Write([]string{"failure-reason", reason.Reason, strconv.FormatUint(reason.Count, 10), ""})
+	}
+	for _, o := range r.Largest {
+		cw.Write([]string{"largest-failed", o.Key, strconv.FormatInt(o.Size, 10), ""})
+	}
+	for _, t := range r.Timeline {
+		cw.Write([]string{"throughput", t.At, t.ObjectsRate, t.BytesRate})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeRunReport writes the --report of the run to the run directory.
+func writeRunReport(s runSummary) (string, error) {
+	r, err := buildRunReport(s)
+	if err != nil {
+		return "", err
+	}
+	name, err := runPath(runReportFile + "." + reportFormat)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if reportFormat == "csv" {
+		return name, r.writeCSV(f)
+	}
+	return name, runReportTemplate.Execute(f, r)
+}
@@ -0,0 +1,114 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/minio/minio/pkg/console"
+)
+
+// rotatingLog is the log file of a run in its run directory, once it grows past
+// maxSize it is renamed with a .1 suffix, shifting older ones up to
+// .keep, and a new one is started.
+type rotatingLog struct {
+	mu      sync.Mutex
+	name    string
+	f       *os.File
+	size    int64
+	maxSize int64
+	keep    int
+}
+
+// runLog receives every log line of the run and the debug lines with
+// --debug, nil with --no-log-file.
+var runLog *rotatingLog
+
+// openRunLog creates moveobject.log in the run directory.
+func openRunLog(maxSize string, keep int) error {
+	n, err := humanize.ParseBytes(maxSize)
+	if err != nil || n == 0 {
+		return fmt.Errorf("invalid --log-max-size %q", maxSize)
+	}
+	if keep < 0 {
+		return fmt.Errorf("--log-keep should not be negative")
+	}
+	l := &rotatingLog{
+		maxSize: int64(n),
+		keep:    keep,
+	}
+	if l.name, err = runPath(runLogFile); err != nil {
+		return err
+	}
+	if l.f, err = os.OpenFile(l.name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); err != nil {
+		return err
+	}
+	reportMu.Lock()
+	outputFiles = append(outputFiles, l.name)
+	reportMu.Unlock()
+	runLog = l
+	return nil
+}
+
+// toRunLog appends msg to the run log with a timestamp.
+func toRunLog(msg string) {
+	if runLog == nil {
+		return
+	}
+	runLog.println(time.Now().Format(time.RFC3339) + " " + msg)
+}
+
+func (l *rotatingLog) println(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return
+	}
+	if l.size > 0 && l.size+int64(len(line))+1 > l.maxSize {
+		l.rotate()
+	}
+	n, err := fmt.Fprintln(l.f, line)
+	l.size += int64(n)
+	if err != nil {
+		console.Errorln(fmt.Sprintf("unable to write %s: %v", l.name, err))
+	}
+}
+
+// rotate shifts the rotated files up by one and starts a new file, the
+// oldest one beyond keep is removed.
+func (l *rotatingLog) rotate() {
+	l.f.Close()
+	os.Remove(fmt.Sprintf("%s.%d", l.name, l.keep))
+	for i := l.keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.name, i), fmt.Sprintf("%s.%d", l.name, i+1))
+	}
+	if l.keep > 0 {
+		os.Rename(l.name, l.name+".1")
+	} else {
+		os.Remove(l.name)
+	}
+	var err error
+	if l.f, err = os.OpenFile(l.name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600); err != nil {
+		console.Errorln(fmt.Sprintf("unable to rotate %s: %v", l.name, err))
+		l.f = nil
+	}
+	l.size = 0
+}
@@ -0,0 +1,200 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/minio/minio/pkg/console"
+)
+
+// Every invocation keeps its artifacts in data-dir/runs/<run ID>/ and is
+// recorded in data-dir/runs/manifest.jsonl when it starts and each time it
+// ends a pass, so that success, fail and report files can be traced back
+// to the run that wrote them.
+const (
+	runsDir          = "runs"
+	runManifestFile  = "manifest.jsonl"
+	runLogFile       = "moveobject.log"
+	runManifestStart = "start"
+	runManifestEnd   = "end"
+)
+
+// runEntry is a line of the run manifest.
+type runEntry struct {
+	RunID    string            `json:"runId"`
+	Event    string            `json:"event"`
+	Command  string            `json:"command"`
+	Time     time.Time         `json:"time"`
+	Dir      string            `json:"dir"`
+	Flags    map[string]string `json:"flags,omitempty"`
+	Status   string            `json:"status,omitempty"`
+	Objects  uint64            `json:"objects,omitempty"`
+	Failures uint64            `json:"failures,omitempty"`
+	Bytes    uint64            `json:"bytes,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Listing  *listingPrint     `json:"listing,omitempty"`
+}
+
+// listingPrint identifies the object_listing.txt a run read without
+// keeping a copy of it.
+type listingPrint struct {
+	SHA256  string    `json:"sha256"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+var (
+	runDirsMu sync.Mutex
+	runDirs   = make(map[string]bool)
+	// runManifest stays in the data-dir given on the command line when
+	// --src-buckets moves the output to subdirectories.
+	runManifest string
+)
+
+// runPath returns the path of name in the run directory of this
+// invocation under data-dir, creating the directory on first use.
+func runPath(name string) (string, error) {
+	dir := path.Join(dirPath, runsDir, runID)
+	runDirsMu.Lock()
+	defer runDirsMu.Unlock()
+	if !runDirs[dir] {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", err
+		}
+		runDirs[dir] = true
+	}
+	return path.Join(dir, name), nil
+}
+
+// startRun creates the run directory and records the start of command in
+// the manifest, with the hash, size and modification time of the listing
+// the run reads.
+func startRun(command string) error {
+	dir, err := runPath("")
+	if err != nil {
+		return err
+	}
+	runManifest = path.Join(dirPath, runsDir, runManifestFile)
+	entry := runEntry{
+		RunID:   runID,
+		Event:   runManifestStart,
+		Command: command,
+		Time:    time.Now().UTC(),
+		Dir:     dir,
+		Flags:   runFlags,
+	}
+	if _, err := os.Stat(path.Join(dirPath, objListFile)); err == nil {
+		if entry.Listing, err = fingerprintListing(path.Join(dirPath, objListFile)); err != nil {
+			logDMsg("unable to fingerprint "+objListFile, err)
+		}
+	}
+	return appendRunEntry(entry)
+}
+
+// fingerprintListing returns the hash, size and modification time of the
+// listing at name.
+func fingerprintListing(name string) (*listingPrint, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return &listingPrint{
+		SHA256:  hex.EncodeToString(h.Sum(nil)),
+		Size:    fi.Size(),
+		ModTime: fi.ModTime().UTC(),
+	}, nil
+}
+
+// endRun records the outcome of the run summarized by s in the manifest.
+func endRun(s runSummary) {
+	if runManifest == "" {
+		return
+	}
+	dir, err := runPath("")
+	if err == nil {
+		err = appendRunEntry(runEntry{
+			RunID:    runID,
+			Event:    runManifestEnd,
+			Command:  s.Command,
+			Time:     s.End,
+			Dir:      dir,
+			Status:   s.Status,
+			Objects:  s.Objects,
+			Failures: s.Failures,
+			Bytes:    s.Bytes,
+			Error:    s.Error,
+		})
+	}
+	if err != nil {
+		console.Errorln(fmt.Sprintf("unable to record run %s in %s: %v", runID, runManifestFile, err))
+	}
+}
+
+// appendRunEntry appends e to the manifest, a single write of a line to a
+// file opened for appending does not interleave with other runs.
+func appendRunEntry(e runEntry) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(runManifest, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(body, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// artifactFiles returns the files matching pattern in data-dir and in the
+// run directories under it, oldest run first. Files written before runs
+// had their own directory are still found in data-dir itself.
+func artifactFiles(pattern string) ([]string, error) {
+	legacy, err := filepath.Glob(filepath.Join(dirPath, pattern))
+	if err != nil {
+		return nil, err
+	}
+	runs, err := filepath.Glob(filepath.Join(dirPath, runsDir, "*", pattern))
+	if err != nil {
+		return nil, err
+	}
+	// Run IDs start with the UTC start time, so they sort by age.
+	sort.Strings(runs)
+	return append(legacy, runs...), nil
+}
@@ -0,0 +1,69 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/console"
+)
+
+// runScheduled runs job once, or with --every keeps repeating it at that
+// interval until --until has passed or the process is interrupted, which
+// lets the current run finish first. A failed run is logged and retried at
+// the next interval.
+func runScheduled(cliCtx *cli.Context, job func() error) error {
+	every := cliCtx.Duration("every")
+	if every <= 0 {
+		return job()
+	}
+	var until time.Time
+	if s := cliCtx.String("until"); s != "" {
+		var err error
+		if until, err = time.Parse(time.RFC3339, s); err != nil {
+			console.Fatalln(fmt.Errorf("unable to parse --until %q: %v", s, err))
+		}
+	}
+
+	stopCh := make(chan os.Signal, 1)
+	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stopCh)
+
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		logMsg(fmt.Sprintf("starting scheduled run at %s", time.Now().Format(time.RFC3339)))
+		if err := job(); err != nil {
+			console.Errorln(fmt.Sprintf("scheduled run failed: %v", err))
+		}
+		select {
+		case <-stopCh:
+			logMsg("stopping scheduled runs")
+			return nil
+		case next := <-ticker.C:
+			if !until.IsZero() && next.After(until) {
+				logMsg("reached --until, stopping scheduled runs")
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,247 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/signer"
+)
+
+// errChecksumNotForwarded fails an object with a checksum that cannot be
+// sent with its upload.
+var errChecksumNotForwarded = errors.New("checksum cannot be forwarded")
+
+// forwardChecksums set by --forward-checksums sends the x-amz-checksum-*
+// values of the source objects with their uploads, so that the destination
+// verifies the data against them and keeps them.
+var forwardChecksums bool
+
+// sdkChecksumHeaders are the additional checksums an object can carry.
+var sdkChecksumHeaders = []string{
+	"X-Amz-Checksum-Crc32",
+	"X-Amz-Checksum-Crc32c",
+	"X-Amz-Checksum-Sha1",
+	"X-Amz-Checksum-Sha256",
+}
+
+const (
+	// checksumModeHeader asks for the checksums of an object in the
+	// response to a HEAD or GET.
+	checksumModeHeader = "X-Amz-Checksum-Mode"
+	// streamingPayload is the payload hash of aws-chunked uploads.
+	streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+)
+
+// objectChecksums are the checksums of the source object of an upload.
+// minio-go keeps neither the checksums of a GET response in the object
+// info nor lets them be set on an upload, they are taken from the source
+// response and added to the destination request by the transports.
+type objectChecksums struct {
+	mu sync.Mutex
+	h  http.Header
+}
+
+type checksumsKey struct{}
+
+// withChecksums returns ctx carrying the checksums of the object about to
+// be read from the source and uploaded to the destination.
+func withChecksums(ctx context.Context) (context.Context, *objectChecksums) {
+	sums := &objectChecksums{}
+	return context.WithValue(ctx, checksumsKey{}, sums), sums
+}
+
+func checksumsFrom(ctx context.Context) *objectChecksums {
+	sums, _ := ctx.Value(checksumsKey{}).(*objectChecksums)
+	return sums
+}
+
+// capture keeps the full object checksums of resp. The composite checksums
+// of multipart uploads, "value-N", cannot be sent with a single upload.
+func (s *objectChecksums) capture(resp *http.Response) {
+	h := make(http.Header)
+	for _, name := range sdkChecksumHeaders {
+		if v := resp.Header.Get(name); v != "" && !strings.Contains(v, "-") {
+			h.Set(name, v)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.h) == 0 {
+		s.h = h
+	}
+}
+
+func (s *objectChecksums) header() http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.h
+}
+
+// captureChecksums returns rt keeping the checksums of the source HEAD and
+// GET responses for --forward-checksums. The object info is taken with a
+// HEAD before the upload reads the data.
+func captureChecksums(rt http.RoundTripper) http.RoundTripper {
+	if !forwardChecksums {
+		return rt
+	}
+	return &checksumCaptureTransport{rt: rt}
+}
+
+type checksumCaptureTransport struct {
+	rt http.RoundTripper
+}
+
+func (t *checksumCaptureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil || (req.Method != http.MethodGet && req.Method != http.MethodHead) || resp.StatusCode/100 != 2 {
+		return resp, err
+	}
+	if sums := checksumsFrom(req.Context()); sums != nil {
+		sums.capture(resp)
+	}
+	return resp, err
+}
+
+// sendChecksums returns rt adding the source checksums to the single part
+// uploads of the destination for --forward-checksums. The headers must be
+// signed, the request is signed again with the current value of creds.
+func sendChecksums(rt http.RoundTripper, creds *credentials.Credentials) http.RoundTripper {
+	if !forwardChecksums {
+		return rt
+	}
+	return &checksumSendTransport{rt: rt, creds: creds}
+}
+
+type checksumSendTransport struct {
+	rt    http.RoundTripper
+	creds *credentials.Credentials
+}
+
+func (t *checksumSendTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sums := checksumsFrom(req.Context())
+	// Parts of multipart uploads and copies carry a query or a copy
+	// source, only the PUT of a whole object is sent with checksums.
+	if sums == nil || req.Method != http.MethodPut || req.URL.RawQuery != "" || req.Header.Get("X-Amz-Copy-Source") != "" {
+		return t.rt.RoundTrip(req)
+	}
+	h := sums.header()
+	if len(h) == 0 {
+		return t.rt.RoundTrip(req)
+	}
+	r := req.Clone(req.Context())
+	for name := range h {
+		r.Header.Set(name, h.Get(name))
+	}
+	if r.Header.Get("X-Amz-Content-Sha256") == streamingPayload {
+		// The chunk signatures of a streaming upload chain from the
+		// signature of the headers, it is sent decoded instead.
+		size, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid decoded content length of streaming upload: %v", err)
+		}
+		r.Body = ioutil.NopCloser(&awsChunkedReader{r: bufio.NewReader(req.Body)})
+		r.ContentLength = size
+		r.Header.Del("X-Amz-Decoded-Content-Length")
+		r.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	}
+	v, err := t.creds.Get()
+	if err != nil {
+		return nil, err
+	}
+	region := signingRegion(req.Header.Get("Authorization"))
+	r.Header.Del("Authorization")
+	return t.rt.RoundTrip(signer.SignV4(*r, v.AccessKeyID, v.SecretAccessKey, v.SessionToken, region))
+}
+
+// signingRegion returns the region of the credential scope of a SigV4
+// Authorization header.
+func signingRegion(auth string) string {
+	i := strings.Index(auth, "Credential=")
+	if i < 0 {
+		return "us-east-1"
+	}
+	scope := strings.Split(strings.SplitN(auth[i+len("Credential="):], ",", 2)[0], "/")
+	if len(scope) < 3 {
+		return "us-east-1"
+	}
+	return scope[2]
+}
+
+// awsChunkedReader decodes the data of an aws-chunked body, chunks of
+// "size;chunk-signature=sig\r\n" followed by the data and "\r\n", ending
+// with a chunk of size 0.
+type awsChunkedReader struct {
+	r    *bufio.Reader
+	n    int64
+	done bool
+}
+
+func (c *awsChunkedReader) Read(p []byte) (int, error) {
+	for c.n == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+	if int64(len(p)) > c.n {
+		p = p[:c.n]
+	}
+	n, err := c.r.Read(p)
+	c.n -= int64(n)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	if err == nil && c.n == 0 {
+		var crlf [2]byte
+		if _, err = io.ReadFull(c.r, crlf[:]); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+// next reads the header of the next chunk.
+func (c *awsChunkedReader) next() error {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	size := strings.TrimSpace(strings.SplitN(line, ";", 2)[0])
+	n, err := strconv.ParseInt(size, 16, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid aws-chunked chunk header %q", line)
+	}
+	c.n, c.done = n, n == 0
+	return nil
+}
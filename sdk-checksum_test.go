@@ -0,0 +1,213 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/s3utils"
+)
+
+const (
+	testAccessKey = "minio"
+	testSecretKey = "minio123"
+)
+
+// receivedPut is the upload as the destination received it.
+type receivedPut struct {
+	header        http.Header
+	body          []byte
+	contentLength int64
+	signatureErr  string
+}
+
+// TestSendChecksums uploads through minio-go the way migrate does and
+// checks that the checksum reaches the destination signed, with the data
+// intact. It fails when a minio-go upgrade changes the shape of the upload
+// request the transport rewrites: plain HTTP uploads are aws-chunked
+// streaming uploads, HTTPS ones are sent as they are.
+func TestSendChecksums(t *testing.T) {
+	forwardChecksums = true
+	defer func() { forwardChecksums = false }()
+
+	data := bytes.Repeat([]byte("moveobject checksum "), 100000)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+	checksum := base64.StdEncoding.EncodeToString(crc)
+
+	for _, secure := range []bool{false, true} {
+		name := "http"
+		if secure {
+			name = "https"
+		}
+		t.Run(name, func(t *testing.T) {
+			var puts []receivedPut
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPut {
+					w.WriteHeader(http.StatusNotImplemented)
+					return
+				}
+				body, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				puts = append(puts, receivedPut{
+					header:        r.Header.Clone(),
+					body:          body,
+					contentLength: r.ContentLength,
+					signatureErr:  verifySignatureV4(r, testSecretKey),
+				})
+				sum := md5.Sum(body)
+				w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+				w.WriteHeader(http.StatusOK)
+			})
+			var srv *httptest.Server
+			if secure {
+				srv = httptest.NewTLSServer(handler)
+			} else {
+				srv = httptest.NewServer(handler)
+			}
+			defer srv.Close()
+
+			creds := credentials.NewStaticV4(testAccessKey, testSecretKey, "")
+			u, _ := url.Parse(srv.URL)
+			client, err := miniogo.New(u.Host, &miniogo.Options{
+				Creds:     creds,
+				Secure:    secure,
+				Region:    "us-east-1",
+				Transport: sendChecksums(srv.Client().Transport, creds),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, sums := withChecksums(context.Background())
+			source := &http.Response{Header: make(http.Header)}
+			source.Header.Set("X-Amz-Checksum-Crc32c", checksum)
+			sums.capture(source)
+
+			_, err = client.PutObject(ctx, "bucket", "dir/object", bytes.NewReader(data), int64(len(data)), miniogo.PutObjectOptions{
+				DisableMultipart: true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(puts) != 1 {
+				t.Fatalf("expected a single PUT, got %d", len(puts))
+			}
+			put := puts[0]
+			if got := put.header.Get("X-Amz-Checksum-Crc32c"); got != checksum {
+				t.Errorf("checksum header %q, expected %q", got, checksum)
+			}
+			if !strings.Contains(put.header.Get("Authorization"), "x-amz-checksum-crc32c") {
+				t.Errorf("checksum header is not signed: %s", put.header.Get("Authorization"))
+			}
+			if put.signatureErr != "" {
+				t.Error(put.signatureErr)
+			}
+			if put.header.Get("X-Amz-Content-Sha256") == streamingPayload || put.header.Get("Content-Encoding") == "aws-chunked" {
+				t.Errorf("body is still aws-chunked encoded")
+			}
+			if !bytes.Equal(put.body, data) {
+				t.Errorf("body of %d bytes differs from the %d bytes uploaded", len(put.body), len(data))
+			}
+			if put.contentLength != int64(len(data)) {
+				t.Errorf("content length %d, expected %d", put.contentLength, len(data))
+			}
+		})
+	}
+}
+
+// verifySignatureV4 checks the SigV4 Authorization header of r the way
+// the destination does, it returns why the signature is invalid, empty
+// when it is valid.
+func verifySignatureV4(r *http.Request, secretKey string) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		return "not a SigV4 Authorization header: " + auth
+	}
+	fields := make(map[string]string)
+	for _, f := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(f), "=", 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+	scope := strings.SplitN(fields["Credential"], "/", 2)
+	if len(scope) != 2 {
+		return "malformed credential " + fields["Credential"]
+	}
+	signedHeaders := strings.Split(fields["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signedHeaders) {
+		return "signed headers are not sorted"
+	}
+	var headers strings.Builder
+	for _, h := range signedHeaders {
+		v := strings.Join(r.Header.Values(h), ",")
+		if h == "host" {
+			v = r.Host
+		}
+		headers.WriteString(h + ":" + strings.Join(strings.Fields(v), " ") + "\n")
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		s3utils.EncodePath(r.URL.Path),
+		strings.Replace(r.URL.Query().Encode(), "+", "%20", -1),
+		headers.String(),
+		fields["SignedHeaders"],
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		r.Header.Get("X-Amz-Date"),
+		scope[1],
+		hex.EncodeToString(hashed[:]),
+	}, "\n")
+	key := []byte("AWS4" + secretKey)
+	for _, part := range strings.Split(scope[1], "/") {
+		key = hmacSum(key, part)
+	}
+	if want := hex.EncodeToString(hmacSum(key, stringToSign)); want != fields["Signature"] {
+		return "signature " + fields["Signature"] + " does not match " + want
+	}
+	return ""
+}
+
+func hmacSum(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,62 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// shardIndex and shardCount set by --shard i/N, a zero shardCount
+// processes every key.
+var shardIndex, shardCount uint32
+
+// parseShard parses "i/N" where 0 <= i < N.
+func parseShard(s string) (index, count uint32, err error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid shard %q, expected i/N", s)
+	}
+	i, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q: %v", s, err)
+	}
+	n, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q: %v", s, err)
+	}
+	if n == 0 || i >= n {
+		return 0, 0, fmt.Errorf("invalid shard %q, expected 0 <= i < N", s)
+	}
+	return uint32(i), uint32(n), nil
+}
+
+// inShard reports whether key belongs to this instance's shard, keys
+// are partitioned by hash so every instance must use the same N.
+func inShard(key string) bool {
+	if shardCount == 0 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()%shardCount == shardIndex
+}
@@ -0,0 +1,169 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+// sourceBucket is a source bucket drained by migrate --src-buckets,
+// optionally only the objects under prefix.
+type sourceBucket struct {
+	bucket, prefix string
+}
+
+var (
+	// srcBuckets set by --src-buckets replaces --src-bucket and
+	// object_listing.txt with a listing of each of the buckets.
+	srcBuckets []sourceBucket
+	// activeSource is the entry of srcBuckets being migrated.
+	activeSource *sourceBucket
+	// listSource set by --list-source queues objects from a live listing
+	// of --src-bucket instead of object_listing.txt.
+	listSource bool
+	// listPrefixes set by --list-prefix limits the live listing.
+	listPrefixes []string
+)
+
+// parseSourceBuckets parses a comma separated list of "bucket" and
+// "bucket/prefix" entries.
+func parseSourceBuckets(s string) ([]sourceBucket, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var sources []sourceBucket
+	seen := make(map[sourceBucket]bool)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		parts := strings.SplitN(entry, "/", 2)
+		src := sourceBucket{bucket: parts[0]}
+		if len(parts) == 2 {
+			src.prefix = parts[1]
+		}
+		if src.bucket == "" {
+			return nil, fmt.Errorf("invalid --src-buckets entry %q, expected bucket or bucket/prefix", entry)
+		}
+		if seen[src] {
+			return nil, fmt.Errorf("--src-buckets entry %q is listed twice", entry)
+		}
+		seen[src] = true
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// dataDir returns the data directory of src below root, outputs of each
+// source are kept apart so that retry and undo can run per bucket.
+func (src sourceBucket) dataDir(root string) string {
+	if src.prefix == "" {
+		return path.Join(root, src.bucket)
+	}
+	return path.Join(root, src.bucket, strings.Trim(src.prefix, "/"))
+}
+
+func (src sourceBucket) String() string {
+	if src.prefix == "" {
+		return src.bucket
+	}
+	return src.bucket + "/" + src.prefix
+}
+
+// migrateSources runs migrateOnce for each of the --src-buckets in turn, a
+// failure of one bucket does not stop the others.
+func migrateSources(cliCtx *cli.Context) error {
+	if len(srcBuckets) == 0 {
+		return migrateOnce(cliCtx)
+	}
+	root := dirPath
+	defer func() { dirPath, activeSource = root, nil }()
+	var failed []string
+	for i, src := range srcBuckets {
+		activeSource = &srcBuckets[i]
+		minioSrcBucket = src.bucket
+		dirPath = src.dataDir(root)
+		if err := os.MkdirAll(dirPath, 0700); err != nil {
+			return err
+		}
+		logMsg(fmt.Sprintf("Migrating source bucket %s, output in %s", src, dirPath))
+		if err := migrateOnce(cliCtx); err != nil {
+			logMsg(fmt.Sprintf("error migrating source bucket %s: %s", src, err))
+			failed = append(failed, src.String())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("migration of source buckets %s failed", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// listedSources returns the sources migrateOnce lists itself instead of
+// reading object_listing.txt.
+func listedSources() []sourceBucket {
+	if activeSource != nil {
+		return []sourceBucket{*activeSource}
+	}
+	if !listSource {
+		return nil
+	}
+	if len(listPrefixes) == 0 {
+		return []sourceBucket{{bucket: minioSrcBucket}}
+	}
+	sources := make([]sourceBucket, 0, len(listPrefixes))
+	for _, prefix := range listPrefixes {
+		sources = append(sources, sourceBucket{bucket: minioSrcBucket, prefix: prefix})
+	}
+	return sources
+}
+
+// queueFromBucket queues the latest version of every object of src as it
+// is listed, objects not matching the expected pattern are left out as they
+// are by list and those in succeeded are skipped.
+func queueFromBucket(ctx context.Context, src sourceBucket, succeeded map[string]struct{}) error {
+	opts := miniogo.ListObjectsOptions{
+		Recursive: true,
+		Prefix:    src.prefix,
+	}
+	for object := range listObjects(ctx, minioSrcClient, src.bucket, opts) {
+		if object.Err != nil {
+			return object.Err
+		}
+		if !patternMatch(object.Key) || !inShard(object.Key) {
+			continue
+		}
+		if _, ok := succeeded[object.Key]; ok {
+			logDMsg(fmt.Sprintf("skipping %s, already succeeded", object.Key), nil)
+			continue
+		}
+		if presentAtDestination(object.Key) {
+			logDMsg(fmt.Sprintf("skipping %s, already at the destination", object.Key), nil)
+			continue
+		}
+		if !admit() {
+			break
+		}
+		migrationState.queueUploadTask(object.Key)
+		logDMsg(fmt.Sprintf("adding %s to migration queue", object.Key), nil)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// encryptionFlags configure the server side encryption of objects copied
+// within the bucket by move and copy.
+var encryptionFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "src-sse-c-key",
+		Usage: "base64 encoded 32 byte key to read SSE-C encrypted objects with",
+	},
+	cli.StringFlag{
+		Name:  "dst-sse-c-key",
+		Usage: "base64 encoded 32 byte key to encrypt the copies with, the copies of SSE-C objects keep --src-sse-c-key by default",
+	},
+	cli.StringFlag{
+		Name:  "dst-sse",
+		Usage: "encrypt the copies with SSE-S3 when set to s3 or with SSE-KMS when set to kms:keyID",
+	},
+}
+
+var (
+	// srcSSEC set by --src-sse-c-key decrypts SSE-C source objects.
+	srcSSEC encrypt.ServerSide
+	// dstSSE set by --dst-sse-c-key or --dst-sse encrypts the copies.
+	dstSSE encrypt.ServerSide
+)
+
+// parseSSECKey decodes a base64 encoded SSE-C key.
+func parseSSECKey(flag, s string) (encrypt.ServerSide, error) {
+	if s == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s, expected base64: %v", flag, err)
+	}
+	sse, err := encrypt.NewSSEC(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s: %v", flag, err)
+	}
+	return sse, nil
+}
+
+// parseEncryption reads the encryptionFlags.
+func parseEncryption(ctx *cli.Context) (err error) {
+	if srcSSEC, err = parseSSECKey("src-sse-c-key", ctx.String("src-sse-c-key")); err != nil {
+		return err
+	}
+	if dstSSE, err = parseSSECKey("dst-sse-c-key", ctx.String("dst-sse-c-key")); err != nil {
+		return err
+	}
+	sse := ctx.String("dst-sse")
+	if sse == "" {
+		return nil
+	}
+	if dstSSE != nil {
+		return fmt.Errorf("only one of --dst-sse-c-key or --dst-sse can be set")
+	}
+	switch {
+	case sse == "s3":
+		dstSSE = encrypt.NewSSE()
+	case strings.HasPrefix(sse, "kms:") && len(sse) > len("kms:"):
+		if dstSSE, err = encrypt.NewSSEKMS(strings.TrimPrefix(sse, "kms:"), nil); err != nil {
+			return fmt.Errorf("invalid --dst-sse: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown --dst-sse %q, should be s3 or kms:keyID", sse)
+	}
+	return nil
+}
+
+// statEncrypted stats versionID of object, again with --src-sse-c-key when
+// the plain stat fails. It returns the key needed to read the object, nil
+// when it is not SSE-C encrypted.
+func statEncrypted(ctx context.Context, object, versionID string) (miniogo.ObjectInfo, encrypt.ServerSide, error) {
+	statCtx, cancel := opContext(ctx)
+	defer cancel()
+	opts := miniogo.StatObjectOptions{VersionID: versionID}
+	stat, err := minioClient.StatObject(statCtx, minioBucket, object, opts)
+	if err == nil || srcSSEC == nil {
+		return stat, nil, err
+	}
+	opts.ServerSideEncryption = srcSSEC
+	stat, kerr := minioClient.StatObject(statCtx, minioBucket, object, opts)
+	if kerr != nil {
+		return stat, nil, err
+	}
+	return stat, srcSSEC, nil
+}
+
+// applyEncryption sets key to read the source with and the encryption of
+// the copy, which keeps the source key unless the destination has its own.
+func applyEncryption(src *miniogo.CopySrcOptions, dst *miniogo.CopyDestOptions, key encrypt.ServerSide) {
+	src.Encryption = key
+	dst.Encryption = key
+	if dstSSE != nil {
+		dst.Encryption = dstSSE
+	}
+}
@@ -0,0 +1,138 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// stallTimeout set by --stall-timeout cancels a transfer when no
+	// bytes have moved for this long, disabled when zero.
+	stallTimeout time.Duration
+	// stallRetries set by --stall-retries is the number of times a
+	// stalled transfer is retried.
+	stallRetries int
+)
+
+var errTransferStalled = errors.New("transfer stalled")
+
+// stallWatch cancels its context when the data passing through reader
+// stops moving for stallTimeout. Bytes sent by the upload, reported to
+// progress, count as moving too, reading stops while a buffered part is
+// uploaded.
+type stallWatch struct {
+	last    int64 // unix nanoseconds of the last read or send that moved bytes
+	stalled int32
+	cancel  context.CancelFunc
+}
+
+// watchStall returns a context cancelled by the returned watch, requests
+// made with it are aborted when the transfer stalls.
+func watchStall(ctx context.Context) (context.Context, *stallWatch) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, &stallWatch{cancel: cancel}
+}
+
+// reader starts watching the data read from r.
+func (w *stallWatch) reader(ctx context.Context, r io.Reader) io.Reader {
+	if stallTimeout <= 0 {
+		return r
+	}
+	atomic.StoreInt64(&w.last, time.Now().UnixNano())
+	go func() {
+		ticker := time.NewTicker(stallTimeout / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if time.Since(time.Unix(0, atomic.LoadInt64(&w.last))) > stallTimeout {
+					atomic.StoreInt32(&w.stalled, 1)
+					w.cancel()
+					return
+				}
+			}
+		}
+	}()
+	return &stallReader{r: r, w: w}
+}
+
+// progress returns the reader to report the bytes the upload sends to,
+// nil when stalls are not watched.
+func (w *stallWatch) progress() io.Reader {
+	if stallTimeout <= 0 {
+		return nil
+	}
+	return stallProgress{w: w}
+}
+
+// err returns errTransferStalled in place of err if the watch cancelled
+// the transfer.
+func (w *stallWatch) err(err error) error {
+	if err != nil && atomic.LoadInt32(&w.stalled) == 1 {
+		return fmt.Errorf("%w, no bytes moved for %s", errTransferStalled, stallTimeout)
+	}
+	return err
+}
+
+// stop releases the watch.
+func (w *stallWatch) stop() {
+	w.cancel()
+}
+
+type stallReader struct {
+	r io.Reader
+	w *stallWatch
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		atomic.StoreInt64(&s.w.last, time.Now().UnixNano())
+	}
+	return n, err
+}
+
+// stallProgress is told the bytes the upload sends.
+type stallProgress struct {
+	w *stallWatch
+}
+
+func (s stallProgress) Read(p []byte) (int, error) {
+	if len(p) > 0 {
+		atomic.StoreInt64(&s.w.last, time.Now().UnixNano())
+	}
+	return len(p), nil
+}
+
+// retryStalled calls fn again up to --stall-retries times while it fails
+// with a stalled transfer.
+func retryStalled(object string, fn func() error) error {
+	err := fn()
+	for i := 0; i < stallRetries && errors.Is(err, errTransferStalled); i++ {
+		logMsg(fmt.Sprintf("retrying stalled transfer of %s: %s", object, err))
+		err = fn()
+	}
+	return err
+}
@@ -0,0 +1,352 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/console"
+)
+
+var statusFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "data-dir",
+		Usage: "data directory",
+	},
+	cli.StringFlag{
+		Name:  "run",
+		Usage: "only show the run with this ID and its output files",
+	},
+	cli.BoolFlag{
+		Name:  "json",
+		Usage: "print the runs as JSON",
+	},
+}
+
+var statusCmd = cli.Command{
+	Name:   "status",
+	Usage:  "show the runs in a data directory, their progress and outstanding failures",
+	Action: statusAction,
+	Flags:  statusFlags,
+	CustomHelpTemplate: `NAME:
+	 {{.HelpName}} - {{.Usage}}
+ 
+ USAGE:
+	 {{.HelpName}} --data-dir DIR [--run ID, --json]
+ 
+ FLAGS:
+	{{range .VisibleFlags}}{{.}}
+	{{end}}
+ 
+ EXAMPLES:
+ 1. Show all runs in the data directory and the failures that are not retried yet.
+	$ moveobject status --data-dir /tmp/
+
+ 2. Show a single run with the records in each of its output files.
+	$ moveobject status --data-dir /tmp/ --run 20240102T030405Z-4242
+ `,
+}
+
+// failFiles are the fail files retry and export consume, the latest of
+// each holds the failures that are still outstanding.
+var failFiles = []string{
+	failMigFile,
+	failMoveFile,
+	failCopyFile,
+	failDeleteFile,
+	failFixMetaFile,
+	failCleanMarkersFile,
+	failPruneVersionsFile,
+	failUndoFile,
+	failRenameFile,
+	failExportFile,
+}
+
+// runInfo is a run of the manifest with its progress as far as its
+// checkpoint, status and summary files tell.
+type runInfo struct {
+	RunID     string      `json:"runId"`
+	Command   string      `json:"command"`
+	State     string      `json:"state"`
+	Started   time.Time   `json:"started"`
+	Ended     *time.Time  `json:"ended,omitempty"`
+	Dirs      []string    `json:"dirs"`
+	Progress  *float64    `json:"progress,omitempty"`
+	Objects   uint64      `json:"objects"`
+	Failures  uint64      `json:"failures"`
+	Bytes     uint64      `json:"bytes"`
+	Error     string      `json:"error,omitempty"`
+	LastError *taskError  `json:"lastError,omitempty"`
+	Files     []fileCount `json:"files,omitempty"`
+}
+
+// fileCount is an output file and the number of records in it.
+type fileCount struct {
+	Name    string `json:"name"`
+	Records uint64 `json:"records"`
+}
+
+// outstandingFailures is the latest fail file of a command.
+type outstandingFailures struct {
+	File    string `json:"file"`
+	Records uint64 `json:"records"`
+}
+
+func statusAction(cliCtx *cli.Context) error {
+	dirPath = cliCtx.String("data-dir")
+	if dirPath == "" {
+		console.Fatalln(fmt.Errorf("path to working dir required, please set --data-dir flag"))
+	}
+	runs, err := loadRuns()
+	if err != nil {
+		console.Fatalln(err)
+	}
+	if id := cliCtx.String("run"); id != "" {
+		var found []*runInfo
+		for _, r := range runs {
+			if r.RunID == id {
+				found = append(found, r)
+			}
+		}
+		if len(found) == 0 {
+			console.Fatalln(fmt.Errorf("no run %s in %s", id, path.Join(dirPath, runsDir, runManifestFile)))
+		}
+		runs = found
+	}
+	for _, r := range runs {
+		if err = r.countFiles(); err != nil {
+			console.Fatalln(err)
+		}
+	}
+	var outstanding []outstandingFailures
+	for _, name := range failFiles {
+		latest, err := latestFile(name)
+		if err != nil {
+			// No run wrote this fail file yet.
+			continue
+		}
+		n, err := countRecords(latest)
+		if err != nil {
+			console.Fatalln(err)
+		}
+		if n > 0 {
+			outstanding = append(outstanding, outstandingFailures{File: latest, Records: n})
+		}
+	}
+
+	if cliCtx.Bool("json") {
+		body, err := json.MarshalIndent(struct {
+			Runs        []*runInfo            `json:"runs"`
+			Outstanding []outstandingFailures `json:"outstanding"`
+		}{runs, outstanding}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(body))
+		return nil
+	}
+
+	if len(runs) == 0 {
+		fmt.Printf("No runs recorded in %s\n", dirPath)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Run\tCommand\tState\tStarted\tProgress\tObjects\tFailures\tSize\t")
+	for _, r := range runs {
+		progress := "-"
+		if r.Progress != nil {
+			progress = fmt.Sprintf("%.1f%%", *r.Progress)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\t\n", r.RunID, r.Command, r.State,
+			r.Started.Local().Format("2006-01-02 15:04:05"), progress, r.Objects, r.Failures, humanize.IBytes(r.Bytes))
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if cliCtx.String("run") != "" {
+		for _, r := range runs {
+			fmt.Println()
+			if r.Error != "" {
+				fmt.Println("Error:", r.Error)
+			}
+			if r.LastError != nil {
+				fmt.Printf("Last error: %s: %s at %s\n", r.LastError.Key, r.LastError.Error, r.LastError.Time.Local().Format(time.RFC3339))
+			}
+			for _, f := range r.Files {
+				fmt.Printf("%10d  %s\n", f.Records, f.Name)
+			}
+		}
+	}
+	if len(outstanding) > 0 {
+		fmt.Println()
+		fmt.Println("Outstanding failures:")
+		for _, o := range outstanding {
+			fmt.Printf("%10d  %s\n", o.Records, o.File)
+		}
+	}
+	return nil
+}
+
+// loadRuns reads the run manifest of data-dir and the checkpoint, status
+// and summary files of each run, oldest run first.
+func loadRuns() ([]*runInfo, error) {
+	f, err := os.Open(path.Join(dirPath, runsDir, runManifestFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []*runInfo
+	byID := make(map[string]*runInfo)
+	// A run ends once for each of its --src-buckets or --every passes, the
+	// last summary of each directory counts.
+	ends := make(map[string]map[string]runEntry)
+	dirs := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e runEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// A line cut short by a crash.
+			continue
+		}
+		r, ok := byID[e.RunID]
+		if !ok {
+			r = &runInfo{RunID: e.RunID, Command: e.Command, State: "unfinished", Started: e.Time}
+			byID[e.RunID] = r
+			ends[e.RunID] = make(map[string]runEntry)
+			runs = append(runs, r)
+		}
+		if !dirs[e.Dir] {
+			dirs[e.Dir] = true
+			r.Dirs = append(r.Dirs, e.Dir)
+		}
+		if e.Event == runManifestEnd {
+			ends[e.RunID][e.Dir] = e
+			r.State, r.Error = e.Status, e.Error
+			end := e.Time
+			r.Ended = &end
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, r := range runs {
+		for _, e := range ends[r.RunID] {
+			r.Objects += e.Objects
+			r.Failures += e.Failures
+			r.Bytes += e.Bytes
+		}
+		for _, dir := range r.Dirs {
+			if r.Ended == nil {
+				r.readStatus(dir)
+			}
+			if err := r.readCheckpoint(dir); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return runs, nil
+}
+
+// readStatus takes the counters of an unfinished run from the status file
+// of its data directory, which is only rewritten by a live run.
+func (r *runInfo) readStatus(dir string) {
+	data, err := ioutil.ReadFile(filepath.Join(filepath.Dir(filepath.Dir(dir)), statusFile))
+	if err != nil {
+		return
+	}
+	var s runStatus
+	if json.Unmarshal(data, &s) != nil || s.RunID != r.RunID {
+		return
+	}
+	if s.State == "running" || s.State == "paused" {
+		r.State = s.State
+	}
+	r.Objects, r.Failures, r.Bytes = s.Objects, s.Failures, s.Bytes
+	r.LastError = s.LastError
+}
+
+// readCheckpoint sets the progress of r to how far into the listing the
+// checkpoint in dir got.
+func (r *runInfo) readCheckpoint(dir string) error {
+	data, err := ioutil.ReadFile(filepath.Join(dir, checkpointFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var c checkpointState
+	if err = json.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("unable to parse %s: %v", filepath.Join(dir, checkpointFile), err)
+	}
+	if c.FileSize > 0 {
+		p := float64(c.Offset) * 100 / float64(c.FileSize)
+		r.Progress = &p
+	}
+	return nil
+}
+
+// countFiles counts the records of the success and fail files of r.
+func (r *runInfo) countFiles() error {
+	r.Files = nil
+	for _, dir := range r.Dirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*"))
+		if err != nil {
+			return err
+		}
+		for _, name := range files {
+			if outputKind(name) == "" {
+				continue
+			}
+			n, err := countRecords(name)
+			if err != nil {
+				return err
+			}
+			r.Files = append(r.Files, fileCount{Name: name, Records: n})
+		}
+	}
+	return nil
+}
+
+// countRecords returns the number of records in the output file name.
+func countRecords(name string) (uint64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var n uint64
+	scanner := newRecordScanner(f)
+	for scanner.Scan() {
+		n++
+	}
+	return n, scanner.Err()
+}
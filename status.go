@@ -0,0 +1,161 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/minio/minio/pkg/console"
+)
+
+// statusFile is rewritten in data-dir every statusInterval while a command
+// runs so that watchdogs can tell a hung run from a quiet one.
+const statusFile = "status.json"
+
+// statusInterval set by --status-interval, zero disables the status file.
+var statusInterval = 10 * time.Second
+
+// taskError is the most recent failure of a run.
+type taskError struct {
+	Key   string    `json:"key"`
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// runStatus is the content of the status file.
+type runStatus struct {
+	RunID         string     `json:"runId"`
+	Operation     string     `json:"operation"`
+	State         string     `json:"state"`
+	PID           int        `json:"pid"`
+	Start         time.Time  `json:"start"`
+	Updated       time.Time  `json:"updated"`
+	LastKey       string     `json:"lastKey"`
+	LastKeyTime   time.Time  `json:"lastKeyTime"`
+	Objects       uint64     `json:"objects"`
+	Failures      uint64     `json:"failures"`
+	Bytes         uint64     `json:"bytes"`
+	QueueDepth    int        `json:"queueDepth"`
+	ObjectsPerSec float64    `json:"objectsPerSec"`
+	BytesPerSec   float64    `json:"bytesPerSec"`
+	LastError     *taskError `json:"lastError,omitempty"`
+}
+
+var (
+	statusMu    sync.Mutex
+	lastKey     string
+	lastKeyTime time.Time
+	lastError   *taskError
+)
+
+// noteProcessed records object as the last key a worker finished with,
+// marks its listing entry done for the checkpoint and counts it towards
+// the next flush.
+func noteProcessed(object string) {
+	statusMu.Lock()
+	lastKey, lastKeyTime = object, time.Now()
+	statusMu.Unlock()
+	checkpoint.done(object)
+	countProcessed()
+}
+
+// noteError records the failure of object as the last error of the run
+// and in the fail file of its error class.
+func noteError(object string, err error) {
+	statusMu.Lock()
+	lastError = &taskError{Key: object, Error: err.Error(), Time: time.Now()}
+	statusMu.Unlock()
+	recordFailureClass(object, err)
+}
+
+// startStatus rewrites the status file of op until the returned function
+// is called, which writes it one last time in the finished state.
+func startStatus(op string, c progressCounter) func() {
+	if statusInterval <= 0 {
+		return func() {}
+	}
+	statusMu.Lock()
+	lastKey, lastKeyTime, lastError = "", time.Time{}, nil
+	statusMu.Unlock()
+	s := &runStatus{RunID: runID, Operation: op, State: "running", PID: os.Getpid(), Start: time.Now()}
+	s.write(c)
+	doneCh := make(chan struct{})
+	stoppedCh := make(chan struct{})
+	flushCh := make(chan struct{}, 1)
+	setFlushCh(&statusFlushCh, flushCh)
+	go func() {
+		defer close(stoppedCh)
+		ticker := time.NewTicker(statusInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-doneCh:
+				s.State = "finished"
+				s.write(c)
+				return
+			case <-ticker.C:
+				s.write(c)
+			case <-flushCh:
+				s.write(c)
+			}
+		}
+	}()
+	return func() {
+		setFlushCh(&statusFlushCh, nil)
+		close(doneCh)
+		<-stoppedCh
+	}
+}
+
+// write refreshes s from c and atomically replaces the status file, the
+// rates are measured since the previous write.
+func (s *runStatus) write(c progressCounter) {
+	now := time.Now()
+	count, bytes := c.getCount(), c.getBytes()
+	if elapsed := now.Sub(s.Updated).Seconds(); !s.Updated.IsZero() && elapsed > 0 {
+		s.ObjectsPerSec = float64(count-s.Objects) / elapsed
+		s.BytesPerSec = float64(bytes-s.Bytes) / elapsed
+	}
+	s.Updated, s.Objects, s.Bytes = now, count, bytes
+	s.Failures, s.QueueDepth = c.getFailCount(), c.queueDepth()
+	statusMu.Lock()
+	s.LastKey, s.LastKeyTime, s.LastError = lastKey, lastKeyTime, lastError
+	statusMu.Unlock()
+
+	out := *s
+	if out.State == "running" && intake.isPaused() {
+		out.State = "paused"
+	}
+	body, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		console.Errorln(fmt.Sprintf("unable to encode %s: %v", statusFile, err))
+		return
+	}
+	name := path.Join(dirPath, statusFile)
+	if err = ioutil.WriteFile(name+".tmp", append(body, '\n'), 0600); err == nil {
+		err = os.Rename(name+".tmp", name)
+	}
+	if err != nil {
+		console.Errorln(fmt.Sprintf("unable to write %s: %v", statusFile, err))
+	}
+}
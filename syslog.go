@@ -0,0 +1,71 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// syslogWriter is the part of *syslog.Writer the log functions use.
+type syslogWriter interface {
+	Info(string) error
+	Debug(string) error
+}
+
+// sysLog set by --syslog receives the log and debug lines in addition to
+// stdout, nil when they only go to stdout.
+var sysLog syslogWriter
+
+// toSyslog passes a log line to sysLog, debug lines at debug level.
+func toSyslog(msg string, debug bool) {
+	if sysLog == nil {
+		return
+	}
+	if debug {
+		sysLog.Debug(msg)
+		return
+	}
+	sysLog.Info(msg)
+}
+
+// openSyslog connects to target, "local" for the local syslog daemon or
+// udp://host:port or tcp://host:port for a remote one, an empty target
+// leaves logging to stdout.
+func openSyslog(target, tag string) error {
+	if target == "" {
+		return nil
+	}
+	var network, addr string
+	if target != "local" {
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Host == "" {
+			return fmt.Errorf("invalid --syslog %q, should be local, udp://host:port or tcp://host:port", target)
+		}
+		network, addr = u.Scheme, u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "514")
+		}
+	}
+	w, err := dialSyslog(network, addr, tag)
+	if err != nil {
+		return fmt.Errorf("unable to connect to syslog %s: %v", target, err)
+	}
+	sysLog = w
+	return nil
+}
@@ -0,0 +1,265 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// taskPoolConcurrent is the number of workers of a taskPool, raised to
+// GOMAXPROCS.
+var taskPoolConcurrent = 100
+
+// taskPool runs the tasks of a command queued to it on a fixed set of
+// workers and writes each task to the success or fail file of the command.
+type taskPool struct {
+	objectCh  chan string
+	failedCh  chan string
+	successCh chan string
+	count     uint64
+	failCnt   uint64
+	bytes     uint64
+	wg        sync.WaitGroup
+
+	// writerDone is closed once the records of the tasks are written.
+	writerDone chan struct{}
+
+	stopProgress func()
+	tasks        poolTasks
+}
+
+// poolTasks describes the tasks of a command run by a taskPool.
+type poolTasks struct {
+	// action names the work in the progress and logs, e.g. "Renaming".
+	action string
+	// fields is the number of fields of a task record, tasks of one field
+	// are object keys.
+	fields int
+	// key is the field naming the object of a task.
+	key int
+	// matchPattern fails the tasks whose object does not match the
+	// expected pattern.
+	matchPattern bool
+	// run processes the fields of a task.
+	run func(ctx context.Context, fields []string) error
+	// failFile and successFile name the record files.
+	failFile, successFile string
+	// done is the format of the final log line, given the counts of
+	// processed and failed tasks.
+	done string
+}
+
+func newTaskPool(tasks poolTasks) *taskPool {
+	if runtime.GOMAXPROCS(0) > taskPoolConcurrent {
+		taskPoolConcurrent = runtime.GOMAXPROCS(0)
+	}
+	return &taskPool{
+		objectCh:   make(chan string, taskPoolConcurrent),
+		failedCh:   make(chan string, taskPoolConcurrent),
+		successCh:  make(chan string, taskPoolConcurrent),
+		writerDone: make(chan struct{}),
+		tasks:      tasks,
+	}
+}
+
+func (m *taskPool) queueUploadTask(task string) {
+	m.objectCh <- task
+}
+
+// Increase count processed
+func (m *taskPool) incCount() {
+	atomic.AddUint64(&m.count, 1)
+}
+
+// Get total count processed
+func (m *taskPool) getCount() uint64 {
+	return atomic.LoadUint64(&m.count)
+}
+
+// Increase count failed
+func (m *taskPool) incFailCount() {
+	atomic.AddUint64(&m.failCnt, 1)
+}
+
+// Get total count failed
+func (m *taskPool) getFailCount() uint64 {
+	return atomic.LoadUint64(&m.failCnt)
+}
+
+// Increase bytes processed
+func (m *taskPool) addBytes(n int64) {
+	atomic.AddUint64(&m.bytes, uint64(n))
+}
+
+// Get total bytes processed
+func (m *taskPool) getBytes() uint64 {
+	return atomic.LoadUint64(&m.bytes)
+}
+
+// Get number of tasks waiting for a worker
+func (m *taskPool) queueDepth() int {
+	return len(m.objectCh)
+}
+
+// addWorker creates worker id to process tasks
+func (m *taskPool) addWorker(ctx context.Context, id int) {
+	m.wg.Add(1)
+	// Add a new worker.
+	go func() {
+		defer m.wg.Done()
+		for {
+			if !intake.wait(ctx, id) {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case task, ok := <-m.objectCh:
+				if !ok {
+					return
+				}
+				m.process(ctx, task)
+			}
+		}
+	}()
+}
+
+// process runs task and records its outcome.
+func (m *taskPool) process(ctx context.Context, task string) {
+	fields := []string{task}
+	if m.tasks.fields > 1 {
+		var err error
+		if fields, err = parseRecord(task); err != nil || len(fields) != m.tasks.fields {
+			m.incFailCount()
+			logMsg(fmt.Sprintf("invalid task %s", task))
+			countFailure("InvalidTask")
+			noteProcessed(task)
+			m.failedCh <- task
+			return
+		}
+	}
+	object := fields[m.tasks.key]
+	logDMsg(fmt.Sprintf("%s...%s", m.tasks.action, object), nil)
+	if m.tasks.matchPattern && !patternMatch(object) {
+		m.incFailCount()
+		logMsg(fmt.Sprintf("error matching object %s", object))
+		countFailure("PatternMismatch")
+		noteProcessed(object)
+		m.failedCh <- task
+		return
+	}
+	if err := retryTransient(ctx, object, func() error {
+		return m.tasks.run(ctx, fields)
+	}); err != nil {
+		m.incFailCount()
+		logMsg(fmt.Sprintf("error %s %s: %s", strings.ToLower(m.tasks.action), task, err))
+		countFailure(failureReason(err))
+		noteError(object, err)
+		noteProcessed(object)
+		m.failedCh <- task
+		return
+	}
+	noteProcessed(object)
+	m.successCh <- task
+	m.incCount()
+}
+
+func (m *taskPool) finish(ctx context.Context) {
+	time.Sleep(100 * time.Millisecond)
+	close(m.objectCh)
+	m.wg.Wait() // wait on workers to finish
+	m.stopProgress()
+	close(m.failedCh)
+	close(m.successCh)
+	<-m.writerDone
+
+	if !dryRun {
+		logMsg(fmt.Sprintf(m.tasks.done, m.getCount(), m.getFailCount()))
+	}
+}
+
+func (m *taskPool) init(ctx context.Context) {
+	if m == nil {
+		return
+	}
+	m.stopProgress = startProgress(m.tasks.action, m)
+	for i := 0; i < taskPoolConcurrent; i++ {
+		m.addWorker(ctx, i)
+	}
+	go func() {
+		defer close(m.writerDone)
+		f, err := createRecordFile(m.tasks.failFile)
+		if err != nil {
+			logDMsg("could not create "+m.tasks.failFile, err)
+			return
+		}
+		defer f.Close()
+
+		s, err := createRecordFile(m.tasks.successFile)
+		if err != nil {
+			logDMsg("could not create "+m.tasks.successFile, err)
+			return
+		}
+		defer s.Close()
+
+		// Drain both channels, the one closed last may still hold
+		// records when the other is closed.
+		failedCh, successCh := m.failedCh, m.successCh
+		for failedCh != nil || successCh != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case task, ok := <-failedCh:
+				if !ok {
+					failedCh = nil
+					continue
+				}
+				if err := m.writeTask(f, task); err != nil {
+					logMsg(fmt.Sprintf("Error writing to %s for %s: %s", m.tasks.failFile, task, err))
+					os.Exit(exitAborted)
+				}
+			case task, ok := <-successCh:
+				if !ok {
+					successCh = nil
+					continue
+				}
+				if err := m.writeTask(s, task); err != nil {
+					logMsg(fmt.Sprintf("Error writing to %s for %s: %s", m.tasks.successFile, task, err))
+					os.Exit(exitAborted)
+				}
+			}
+		}
+	}()
+}
+
+// writeTask writes task to w as a record, tasks of several fields already
+// are one.
+func (m *taskPool) writeTask(w io.Writer, task string) error {
+	if m.tasks.fields > 1 {
+		_, err := io.WriteString(w, task+"\n")
+		return err
+	}
+	return writeRecord(w, task)
+}
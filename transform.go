@@ -0,0 +1,175 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+// transformer set by --transform-cmd computes the destination of each
+// migrated object, nil keeps the built-in rename and routing.
+var transformer *transformHook
+
+// transformRequest is sent to the transform program for every object as
+// one line of JSON.
+type transformRequest struct {
+	Bucket    string            `json:"bucket"`
+	Key       string            `json:"key"`
+	Size      int64             `json:"size"`
+	Metadata  map[string]string `json:"metadata"`
+	DstBucket string            `json:"dstBucket"`
+	DstKey    string            `json:"dstKey"`
+}
+
+// transformResponse is read back as one line of JSON, empty fields keep
+// the defaults of the request. Metadata entries are set on the copy, an
+// empty value removes the entry.
+type transformResponse struct {
+	Bucket   string            `json:"bucket,omitempty"`
+	Key      string            `json:"key,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Skip     bool              `json:"skip,omitempty"`
+}
+
+// transformHook is a long running program answering transform requests
+// in order.
+type transformHook struct {
+	mu    sync.Mutex
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	enc   *json.Encoder
+	dec   *json.Decoder
+}
+
+// startTransform starts command with the shell, its stderr is passed
+// through.
+func startTransform(command string) (*transformHook, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("unable to start --transform-cmd %q: %v", command, err)
+	}
+	return &transformHook{
+		cmd:   cmd,
+		stdin: stdin,
+		enc:   json.NewEncoder(stdin),
+		dec:   json.NewDecoder(bufio.NewReader(stdout)),
+	}, nil
+}
+
+func (t *transformHook) transform(req transformRequest) (transformResponse, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var resp transformResponse
+	if err := t.enc.Encode(req); err != nil {
+		return resp, fmt.Errorf("unable to write to --transform-cmd: %v", err)
+	}
+	if err := t.dec.Decode(&resp); err != nil {
+		return resp, fmt.Errorf("unable to read from --transform-cmd: %v", err)
+	}
+	return resp, nil
+}
+
+// close ends the input of the program and waits for it to exit.
+func (t *transformHook) close() {
+	if t == nil {
+		return
+	}
+	t.stdin.Close()
+	if err := t.cmd.Wait(); err != nil {
+		logMsg(fmt.Sprintf("--transform-cmd exited: %v", err))
+	}
+}
+
+// destination is where a migrated object is written to.
+type destination struct {
+	bucket string
+	key    string
+	// metadata replaces the metadata of the copy when not nil.
+	metadata map[string]string
+	skip     bool
+	// versionID and etag identify the copy written, undo removes only
+	// that copy.
+	versionID, etag string
+}
+
+// resolveDestination returns the destination of object, computed by
+// --transform-cmd when set.
+func resolveDestination(object string, stat miniogo.ObjectInfo) (destination, error) {
+	bucket, err := destBucket(object, stat.Size)
+	if err != nil && transformer == nil {
+		return destination{}, err
+	}
+	dest := destination{bucket: bucket, key: convert(object)}
+	if transformer == nil {
+		return dest, nil
+	}
+	meta := preservedMetadata(stat)
+	resp, err := transformer.transform(transformRequest{
+		Bucket:    minioSrcBucket,
+		Key:       object,
+		Size:      stat.Size,
+		Metadata:  meta,
+		DstBucket: dest.bucket,
+		DstKey:    dest.key,
+	})
+	if err != nil {
+		return destination{}, err
+	}
+	if resp.Skip {
+		dest.skip = true
+		return dest, nil
+	}
+	if resp.Bucket != "" {
+		dest.bucket = resp.Bucket
+	}
+	if resp.Key != "" {
+		dest.key = resp.Key
+	}
+	if dest.bucket == "" {
+		return destination{}, fmt.Errorf("no destination bucket for %s", object)
+	}
+	if len(resp.Metadata) > 0 {
+		for k, v := range resp.Metadata {
+			k = http.CanonicalHeaderKey(k)
+			if v == "" {
+				delete(meta, k)
+				continue
+			}
+			meta[k] = v
+		}
+		dest.metadata = meta
+	}
+	return dest, nil
+}
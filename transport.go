@@ -0,0 +1,116 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// newStaticCredentials returns static credentials signed with the
+// requested signature version, "v4" (default) or "v2".
+func newStaticCredentials(accessKey, secretKey, signature string) (*credentials.Credentials, error) {
+	switch signature {
+	case "", "v4":
+		return credentials.NewStaticV4(accessKey, secretKey, ""), nil
+	case "v2":
+		return credentials.NewStaticV2(accessKey, secretKey, ""), nil
+	default:
+		return nil, fmt.Errorf("unsupported signature %s, should be one of v2 or v4", signature)
+	}
+}
+
+// newBucketLookup returns the bucket addressing style, "auto" (default)
+// leaves it to the client, "path" and "dns" force path style and virtual
+// host style requests.
+func newBucketLookup(lookup string) (miniogo.BucketLookupType, error) {
+	switch lookup {
+	case "", "auto":
+		return miniogo.BucketLookupAuto, nil
+	case "path":
+		return miniogo.BucketLookupPath, nil
+	case "dns":
+		return miniogo.BucketLookupDNS, nil
+	default:
+		return miniogo.BucketLookupAuto, fmt.Errorf("unsupported lookup %s, should be one of auto, path or dns", lookup)
+	}
+}
+
+// newTransport returns the http transport shared by source and
+// destination clients.
+func newTransport(ctx *cli.Context) (*http.Transport, error) {
+	tlsConfig := &tls.Config{
+		RootCAs: mustGetSystemCertPool(),
+		// Can't use SSLv3 because of POODLE and BEAST
+		// Can't use TLSv1.0 because of POODLE and BEAST using CBC cipher
+		// Can't use TLSv1.1 because of RC4 cipher usage
+		MinVersion:         tls.VersionTLS12,
+		NextProtos:         []string{"http/1.1"},
+		InsecureSkipVerify: ctx.GlobalBool("insecure"),
+	}
+
+	certFile, keyFile := ctx.String("client-cert"), ctx.String("client-key")
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("both --client-cert and --client-key need to be set")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate %s: %v", certFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	maxIdlePerHost := ctx.Int("max-idle-conns-per-host")
+	if maxIdlePerHost <= 0 {
+		return nil, fmt.Errorf("--max-idle-conns-per-host should be greater than 0")
+	}
+	maxIdle := 256
+	if maxIdlePerHost*2 > maxIdle {
+		// Leave room for both the source and the destination.
+		maxIdle = maxIdlePerHost * 2
+	}
+
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   ctx.Duration("dial-timeout"),
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          maxIdle,
+		MaxIdleConnsPerHost:   maxIdlePerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   ctx.Duration("tls-handshake-timeout"),
+		ResponseHeaderTimeout: ctx.Duration("response-header-timeout"),
+		ExpectContinueTimeout: 10 * time.Second,
+		TLSClientConfig:       tlsConfig,
+		// Set this value so that the underlying transport round-tripper
+		// doesn't try to auto decode the body of objects with
+		// content-encoding set to `gzip`.
+		//
+		// Refer:
+		//    https://golang.org/src/net/http/transport.go?h=roundTrip#L1843
+		DisableCompression: true,
+	}, nil
+}
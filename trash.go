@@ -0,0 +1,96 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+// trash set by --trash-bucket is where delete keeps a copy of every object
+// before removing it.
+var trash struct {
+	bucket, prefix string
+	ttl            time.Duration
+}
+
+// Tags of the copies in the trash, a lifecycle rule or a cleanup job can
+// expire them once moveobject-trash-expires has passed.
+const (
+	trashDeletedTag = "moveobject-trash-deleted"
+	trashExpiresTag = "moveobject-trash-expires"
+)
+
+// parseTrash parses "bucket" or "bucket/prefix".
+func parseTrash(s string, ttl time.Duration) error {
+	trash.bucket, trash.prefix, trash.ttl = "", "", ttl
+	if s == "" {
+		return nil
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("--trash-ttl should be greater than 0")
+	}
+	parts := strings.SplitN(s, "/", 2)
+	if parts[0] == "" {
+		return fmt.Errorf("invalid --trash-bucket %q, expected bucket or bucket/prefix", s)
+	}
+	trash.bucket = parts[0]
+	if len(parts) == 2 {
+		trash.prefix = parts[1]
+	}
+	return nil
+}
+
+// moveToTrash server side copies the version of object stat describes to
+// the trash, tagged with the time it expires at.
+func moveToTrash(ctx context.Context, object string, stat miniogo.ObjectInfo) error {
+	if trash.bucket == "" {
+		return nil
+	}
+	now := time.Now().UTC()
+	src := miniogo.CopySrcOptions{
+		Bucket:    minioBucket,
+		Object:    object,
+		VersionID: stat.VersionID,
+	}
+	dst := miniogo.CopyDestOptions{
+		Bucket:      trash.bucket,
+		Object:      trash.prefix + object,
+		ReplaceTags: true,
+		UserTags: map[string]string{
+			trashDeletedTag: now.Format(time.RFC3339),
+			trashExpiresTag: now.Add(trash.ttl).Format(time.RFC3339),
+		},
+	}
+	copyCtx, cancel := opContext(ctx)
+	defer cancel()
+	var err error
+	if stat.Size > maxCopyObjectSize {
+		_, err = minioClient.ComposeObject(copyCtx, dst, src)
+	} else {
+		_, err = minioClient.CopyObject(copyCtx, dst, src)
+	}
+	if err != nil {
+		logDMsg("copy to trash failed for "+object, err)
+		return fmt.Errorf("unable to copy %s to the trash: %w", object, err)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/console"
+)
+
+var undoFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "operation, o",
+		Usage: "operation to undo, one of move or migrate",
+	},
+	cli.StringFlag{
+		Name:  "success-file",
+		Usage: "success file to undo, defaults to the latest one of the operation in data directory",
+	},
+	cli.BoolFlag{
+		Name:  "fake",
+		Usage: "perform a fake undo",
+	},
+}
+
+var undoCmd = cli.Command{
+	Name:   "undo",
+	Usage:  "reverse the objects recorded in a success file",
+	Action: undoAction,
+	Flags:  append(append(append(append(allFlags, sourceFlags...), routeFlags...), undoFlags...), levelFlags...),
+	CustomHelpTemplate: `NAME:
+	 {{.HelpName}} - {{.Usage}}
+ 
+ USAGE:
+	 {{.HelpName}} --operation OPERATION [--success-file FILE, --fake]
+ 
+ DESCRIPTION:
+	Undoing a move copies each object back to its original key and removes
+	the moved copy. Undoing a migrate removes the migrated copy from the
+	destination bucket. The copies are the ones named by the success
+	records, only move records of runs before the new key was recorded
+	fall back to the current convert rules.
+ 
+ FLAGS:
+	{{range .VisibleFlags}}{{.}}
+	{{end}}
+ 
+ EXAMPLES:
+ 1. Move back the objects in the latest "move_success.txt" file.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_BUCKET=miniobucket
+	$ moveobject undo --data-dir /tmp/ --operation move
+
+ 2. Perform a dry run for removing the migrated copies of a specific migration success file.
+	$ export MINIO_ENDPOINT=https://minio:9000
+	$ export MINIO_ACCESS_KEY=minio
+	$ export MINIO_SECRET_KEY=minio123
+	$ export MINIO_SOURCE_ENDPOINT=https://minio-src:9000
+	$ export MINIO_SOURCE_ACCESS_KEY=minio
+	$ export MINIO_SOURCE_SECRET_KEY=minio123
+	$ export MINIO_DEST_BUCKET_1=dstbucket1
+	$ export MINIO_DEST_BUCKET_2=dstbucket2
+	$ export MINIO_DEST_BUCKET_3=dstbucket3
+	$ export MINIO_DEST_BUCKET_4=dstbucket4
+	$ export MINIO_SOURCE_BUCKET=srcbucket
+	$ moveobject undo --data-dir /tmp/ --operation migrate --success-file /tmp/migration_success.txt.03-01-2021-22-00-00 --fake --log
+ `,
+}
+
+func undoAction(cliCtx *cli.Context) (err error) {
+	checkArgsAndInit(cliCtx)
+	ctx := context.Background()
+	dryRun = cliCtx.Bool("fake")
+
+	var successFile string
+	var initClient func(*cli.Context) error
+	switch undoOperation = cliCtx.String("operation"); undoOperation {
+	case "move":
+		successFile, initClient = successMoveFile, initMinioClient
+	case "migrate":
+		successFile, initClient = successMigFile, initMinioClients
+	default:
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name)
+		console.Fatalln(fmt.Errorf("unknown operation %q, should be one of move or migrate", undoOperation))
+	}
+	if cliCtx.String("success-file") != "" {
+		successFile = cliCtx.String("success-file")
+	} else if successFile, err = latestFile(successFile); err != nil {
+		console.Fatalln(err)
+	}
+
+	logMsg("Init minio client..")
+	if err := initClient(cliCtx); err != nil {
+		logDMsg("Unable to  initialize MinIO client, exiting...%w", err)
+		cli.ShowCommandHelp(cliCtx, cliCtx.Command.Name) // last argument is exit code
+		console.Fatalln(err)
+	}
+	undState = newUndoState(ctx)
+	undState.init(ctx)
+	start := time.Now()
+	defer func() { reportRun("undo", undState, start, err) }()
+
+	file, err := os.Open(successFile)
+	if err != nil {
+		logDMsg(fmt.Sprintf("could not open file :%s ", successFile), err)
+		return err
+	}
+	defer file.Close()
+	logMsg("undoing objects in " + successFile)
+
+	// Read all records first, the workers look up undoDestinations.
+	var objects []string
+	undoDestinations = make(map[string]destination)
+	undoVersions = make(map[string][]string)
+	scanner := newRecordScanner(file)
+	if err := scanner.checkHeader(successFile); err != nil {
+		return err
+	}
+	for scanner.Scan() {
+		o := scanner.Text()
+		// Success records name the version written, migrate ones the
+		// destination too.
+		switch fields := scanner.Fields(); {
+		case undoOperation == "migrate" && len(fields) == 5:
+			undoDestinations[o] = recordedVersions(o, destination{bucket: fields[0], key: fields[1], versionID: fields[2], etag: fields[3]})
+		case undoOperation == "move" && len(fields) == 4:
+			undoDestinations[o] = recordedVersions(o, destination{key: fields[0], versionID: fields[1], etag: fields[2]})
+		case undoOperation == "move" && len(fields) == 3:
+			// Records before the new key was recorded.
+			undoDestinations[o] = destination{versionID: fields[0], etag: fields[1]}
+		}
+		if !admit() {
+			break
+		}
+		objects = append(objects, o)
+	}
+	if err := scanner.Err(); err != nil {
+		logDMsg(fmt.Sprintf("error processing file :%s ", successFile), err)
+		return err
+	}
+	for _, o := range objects {
+		undState.queueUploadTask(o)
+		logDMsg(fmt.Sprintf("adding %s to undo queue", o), nil)
+	}
+	undState.finish(ctx)
+	logMsg("successfully completed undo.")
+
+	return nil
+}
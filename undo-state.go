@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+var undState *taskPool
+
+func newUndoState(ctx context.Context) *taskPool {
+	return newTaskPool(poolTasks{
+		action:       "Undoing",
+		matchPattern: true,
+		run: func(ctx context.Context, fields []string) error {
+			return undoObject(ctx, fields[0])
+		},
+		failFile:    failUndoFile,
+		successFile: successUndoFile,
+		done:        "Undid %d objects, %d failures",
+	})
+}
+
+// undoOperation is the operation whose successes are being reversed.
+var undoOperation string
+
+// undoObject reverses a successful move or migrate of object.
+func undoObject(ctx context.Context, object string) error {
+	switch undoOperation {
+	case "move":
+		return undoMove(ctx, object)
+	case "migrate":
+		return undoMigrate(ctx, object)
+	}
+	return fmt.Errorf("cannot undo operation %s", undoOperation)
+}
+
+// errCopyNotRecorded fails the undo of an object whose success record does
+// not name the version the run wrote, the run wrote none or predates the
+// recording.
+var errCopyNotRecorded = errors.New("written version not recorded")
+
+// errCopyReplaced fails the undo of an object whose recorded version is no
+// longer the latest at its key, e.g. after an application wrote the key
+// since the cutover.
+var errCopyReplaced = errors.New("written version is no longer the latest")
+
+// statRecorded returns the stat of the version dest records, it fails
+// unless that version is still the latest at the key. The ETag stands in
+// for the version ID on unversioned buckets.
+func statRecorded(ctx context.Context, dest destination) (miniogo.ObjectInfo, error) {
+	if dest.versionID == "" && dest.etag == "" {
+		return miniogo.ObjectInfo{}, errCopyNotRecorded
+	}
+	statCtx, cancel := opContext(ctx)
+	stat, err := minioClient.StatObject(statCtx, dest.bucket, dest.key, miniogo.StatObjectOptions{})
+	cancel()
+	if err != nil {
+		return stat, err
+	}
+	if dest.versionID != "" && stat.VersionID != dest.versionID ||
+		dest.versionID == "" && strings.Trim(stat.ETag, "\"") != strings.Trim(dest.etag, "\"") {
+		return stat, fmt.Errorf("%w, %s/%s is at version %s", errCopyReplaced, dest.bucket, dest.key, stat.VersionID)
+	}
+	return stat, nil
+}
+
+// undoMove copies the moved object back to its original key and removes
+// the moved copy, as long as the moved copy is the latest version of the
+// new key.
+func undoMove(ctx context.Context, object string) error {
+	dest := undoDestinations[object]
+	dest.bucket = minioBucket
+	if dest.key == "" {
+		dest.key = convert(object)
+	}
+	if versions := undoVersions[object]; len(versions) > 0 {
+		return undoMoveVersions(ctx, object, dest, versions)
+	}
+	stat, err := statRecorded(ctx, dest)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		logMsg(migrateMsg(dest.key, object))
+		return nil
+	}
+
+	if _, err = copyBack(ctx, object, dest.key, stat.VersionID); err != nil {
+		return err
+	}
+	if err = removeObjectVersion(ctx, dest.key, stat.VersionID); err != nil {
+		return err
+	}
+	undState.addBytes(stat.Size)
+	logDMsg("Moved back "+object+" successfully", nil)
+	return nil
+}
+
+// recordedVersions returns dest of the success record of object with the
+// last of the versions written by --all-versions as its version, which
+// are kept in undoVersions.
+func recordedVersions(object string, dest destination) destination {
+	versions := strings.Fields(dest.versionID)
+	if len(versions) > 1 || strings.HasPrefix(dest.versionID, deleteMarkerPrefix) {
+		undoVersions[object] = versions
+		dest.versionID = strings.TrimPrefix(versions[len(versions)-1], deleteMarkerPrefix)
+	}
+	return dest
+}
+
+// statRecordedVersions fails unless the last of versions, which dest
+// names, is still the latest version of dest.key.
+func statRecordedVersions(ctx context.Context, dest destination, versions []string) error {
+	if !strings.HasPrefix(versions[len(versions)-1], deleteMarkerPrefix) {
+		_, err := statRecorded(ctx, dest)
+		return err
+	}
+	statCtx, cancel := opContext(ctx)
+	stat, _ := minioClient.StatObject(statCtx, dest.bucket, dest.key, miniogo.StatObjectOptions{})
+	cancel()
+	if !stat.IsDeleteMarker || stat.VersionID != dest.versionID {
+		return fmt.Errorf("%w, %s/%s is at version %s", errCopyReplaced, dest.bucket, dest.key, stat.VersionID)
+	}
+	return nil
+}
+
+// undoMoveVersions moves back all versions a move --all-versions wrote to
+// dest.key, oldest first, recreating the delete markers among them, as long
+// as the last of them is still the latest version of the new key.
+func undoMoveVersions(ctx context.Context, object string, dest destination, versions []string) error {
+	if err := statRecordedVersions(ctx, dest, versions); err != nil {
+		return err
+	}
+
+	if dryRun {
+		logMsg(fmt.Sprintf("%s: %d versions of %s", object, len(versions), dest.key))
+		return nil
+	}
+
+	for _, version := range versions {
+		if markerID := strings.TrimPrefix(version, deleteMarkerPrefix); markerID != version {
+			if _, err := addDeleteMarker(ctx, object); err != nil {
+				return fmt.Errorf("delete marker %s: %w", markerID, err)
+			}
+			continue
+		}
+		info, err := copyBack(ctx, object, dest.key, version)
+		if err != nil {
+			return fmt.Errorf("version %s: %w", version, err)
+		}
+		undState.addBytes(info.Size)
+	}
+	for _, version := range versions {
+		versionID := strings.TrimPrefix(version, deleteMarkerPrefix)
+		if err := removeObjectVersion(ctx, dest.key, versionID); err != nil {
+			return fmt.Errorf("version %s: %w", versionID, err)
+		}
+	}
+	logDMsg(fmt.Sprintf("Moved back %d versions of %s successfully", len(versions), object), nil)
+	return nil
+}
+
+// copyBack copies versionID of key back to the original key object.
+func copyBack(ctx context.Context, object, key, versionID string) (miniogo.UploadInfo, error) {
+	src := miniogo.CopySrcOptions{
+		Bucket:    minioBucket,
+		Object:    key,
+		VersionID: versionID,
+	}
+
+	// Destination object
+	dst := miniogo.CopyDestOptions{
+		Bucket: minioBucket,
+		Object: object,
+	}
+
+	copyCtx, cancel := opContext(ctx)
+	info, err := minioClient.CopyObject(copyCtx, dst, src)
+	cancel()
+	if err != nil {
+		logDMsg("copy back failed for "+object, err)
+	}
+	return info, err
+}
+
+// undoVersions holds the versions written by move --all-versions, oldest
+// first, for objects whose success record names them.
+var undoVersions map[string][]string
+
+// undoDestinations holds the copy written for objects whose success record
+// names it.
+var undoDestinations map[string]destination
+
+// undoMigrate removes the migrated copy of object from its destination
+// bucket, the source is left untouched by migrate. Only the version the
+// migration wrote is removed, as long as it is the latest version of the
+// destination key.
+func undoMigrate(ctx context.Context, object string) error {
+	dest := undoDestinations[object]
+	if versions := undoVersions[object]; len(versions) > 0 {
+		return undoMigrateVersions(ctx, object, dest, versions)
+	}
+	stat, err := statRecorded(ctx, dest)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		logMsg(fmt.Sprintf("%s: remove %s/%s", object, dest.bucket, dest.key))
+		return nil
+	}
+
+	opts := miniogo.RemoveObjectOptions{
+		VersionID: stat.VersionID,
+	}
+
+	removeCtx, cancel := opContext(ctx)
+	err = minioClient.RemoveObject(removeCtx, dest.bucket, dest.key, opts)
+	cancel()
+	if err != nil {
+		logDMsg("removeObject failed for "+object, err)
+		return err
+	}
+	undState.addBytes(stat.Size)
+	logDMsg("Removed migrated copy of "+object+" successfully", nil)
+	return nil
+}
+
+// undoMigrateVersions removes all versions a migrate --all-versions wrote
+// to dest, delete markers included, as long as the last of them is still
+// the latest version of the destination key.
+func undoMigrateVersions(ctx context.Context, object string, dest destination, versions []string) error {
+	if err := statRecordedVersions(ctx, dest, versions); err != nil {
+		return err
+	}
+
+	if dryRun {
+		logMsg(fmt.Sprintf("%s: remove %d versions of %s/%s", object, len(versions), dest.bucket, dest.key))
+		return nil
+	}
+
+	for _, version := range versions {
+		versionID := strings.TrimPrefix(version, deleteMarkerPrefix)
+		removeCtx, cancel := opContext(ctx)
+		err := minioClient.RemoveObject(removeCtx, dest.bucket, dest.key, miniogo.RemoveObjectOptions{VersionID: versionID})
+		cancel()
+		if err != nil {
+			logDMsg("removeObject failed for "+object, err)
+			return fmt.Errorf("version %s: %w", versionID, err)
+		}
+	}
+	logDMsg(fmt.Sprintf("Removed %d migrated versions of %s successfully", len(versions), object), nil)
+	return nil
+}
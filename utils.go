@@ -2,17 +2,22 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/x509"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 	"unicode/utf8"
 
+	miniogo "github.com/minio/minio-go/v7"
 	"github.com/minio/minio/pkg/console"
 )
 
@@ -32,9 +37,36 @@ const (
 	TDEBUG = "DEBUG"
 )
 
+// opTimeout bounds every single S3 request, zero means no timeout. The
+// object data a request streams is not bounded by it, a GET is bounded
+// until its response arrived and an upload is left to --stall-timeout.
+var opTimeout time.Duration
+
+// opContext returns a context for a single request bounded by --op-timeout.
+func opContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if opTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, opTimeout)
+}
+
+// openContext returns a context for a request whose response body is read
+// after it returned. --op-timeout bounds the request until opened is
+// called, the body is then only bounded by ctx.
+func openContext(ctx context.Context) (rctx context.Context, opened func()) {
+	if opTimeout <= 0 {
+		return ctx, func() {}
+	}
+	rctx, cancel := context.WithCancel(ctx)
+	t := time.AfterFunc(opTimeout, cancel)
+	return rctx, func() { t.Stop() }
+}
+
 func logMsg(msg string) {
+	toRunLog(msg)
 	if logFlag {
 		fmt.Println(msg)
+		toSyslog(msg, false)
 	}
 }
 
@@ -43,9 +75,13 @@ func logDMsg(msg string, err error) {
 	if debugFlag {
 		if err == nil {
 			fmt.Println(msg)
+			toRunLog(msg)
+			toSyslog(msg, true)
 			return
 		}
 		fmt.Println(msg, " :", err)
+		toRunLog(fmt.Sprint(msg, " : ", err))
+		toSyslog(fmt.Sprint(msg, " : ", err), true)
 	}
 }
 func trace(rq *http.Request, rs *http.Response) string {
@@ -169,18 +205,107 @@ func substr(s string, pos, length int) string {
 	return string(runes[pos:l])
 }
 
+// getParentDirectory returns the directory above directory, empty for a
+// top level directory.
 func getParentDirectory(directory string) string {
-	return substr(directory, 0, strings.LastIndex(directory, "/"))
+	i := strings.LastIndex(directory, "/")
+	if i < 0 {
+		return ""
+	}
+	return substr(directory, 0, i)
+}
+
+// srcPrefix and dstPrefix set by --src-prefix and --dst-prefix relocate
+// the subtree srcPrefix to dstPrefix instead of stripping one level.
+var srcPrefix, dstPrefix string
+
+// remapPrefix reports whether convert relocates an explicit subtree.
+func remapPrefix() bool {
+	return srcPrefix != "" || dstPrefix != ""
+}
+
+// checkPrefixes fails --src-prefix and --dst-prefix that would not
+// relocate a subtree: an empty source matches every key, and a destination
+// equal to or under the source moves keys onto themselves or into the
+// subtree still being processed.
+func checkPrefixes(src, dst string) error {
+	switch {
+	case src == "" && dst == "":
+		return nil
+	case src == "":
+		return errors.New("--dst-prefix needs the --src-prefix it replaces")
+	case src == dst:
+		return errors.New("--src-prefix and --dst-prefix should differ")
+	case strings.HasPrefix(dst, src):
+		return fmt.Errorf("--dst-prefix %s should not be under --src-prefix %s", dst, src)
+	}
+	return nil
+}
+
+// stripLevels and stripFromDepth set by --levels and --from-depth choose
+// the directory levels convert strips, by default the one holding the
+// object.
+var stripLevels, stripFromDepth = 1, 0
+
+// errNothingToStrip fails an object convert leaves at its key, a key with
+// no directory to strip, moving it onto itself would remove it.
+var errNothingToStrip = errors.New("no directory to strip")
+
+// convertKey returns the key convert moves s to, it fails when that is s.
+func convertKey(s string) (string, error) {
+	key := convert(s)
+	if key == s {
+		return "", errNothingToStrip
+	}
+	return key, nil
 }
 
 func convert(s string) string {
+	if remapPrefix() {
+		return normalizeKey(s, dstPrefix+strings.TrimPrefix(s, srcPrefix))
+	}
+	if stripLevels != 1 || stripFromDepth > 0 {
+		return normalizeKey(s, stripDirs(s))
+	}
 	dir := filepath.Dir(s)
-	return filepath.Join(getParentDirectory(dir), filepath.Base(s))
+	return normalizeKey(s, filepath.Join(getParentDirectory(dir), filepath.Base(s)))
+}
+
+// stripDirs removes stripLevels directories from key, those holding the
+// object or, with stripFromDepth, those starting at that depth, 1 being the
+// top level. Keys with fewer directories lose the ones they have.
+func stripDirs(key string) string {
+	parts := strings.Split(key, "/")
+	dirs, name := parts[:len(parts)-1], parts[len(parts)-1]
+	start := len(dirs) - stripLevels
+	if stripFromDepth > 0 {
+		start = stripFromDepth - 1
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > len(dirs) {
+		start = len(dirs)
+	}
+	end := start + stripLevels
+	if end > len(dirs) {
+		end = len(dirs)
+	}
+	kept := append(append([]string{}, dirs[:start]...), dirs[end:]...)
+	return strings.Join(append(kept, name), "/")
 }
 
 var matchFile = regexp.MustCompile(`[0-9].*/[0-9a-zA-Z].*/.*/.*/20[0-9][0-9]/[0-1][0-9]/`)
 
 func patternMatch(obj string) bool {
+	if remapPrefix() {
+		// An explicit subtree replaces the numbered layout.
+		return strings.HasPrefix(obj, srcPrefix)
+	}
+	if len(filePrefixes) > 0 {
+		// So does a prefix file.
+		return inFilePrefixes(obj)
+	}
 	found := matchFile.MatchString(obj)
 	if !found {
 		logDMsg(fmt.Sprintf("error matching object %s", obj), nil)
@@ -188,3 +313,110 @@ func patternMatch(obj string) bool {
 	}
 	return found
 }
+
+// loadSucceeded returns the entries of all the timestamped success files
+// named successFile in data-dir and its run directories, so that reruns can
+// skip them. Files written for another bucket are refused.
+func loadSucceeded(successFile string) (map[string]struct{}, error) {
+	files, err := artifactFiles(successFile + ".*")
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]struct{})
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		scanner := newRecordScanner(f)
+		if err = scanner.checkHeader(name); err != nil {
+			f.Close()
+			return nil, err
+		}
+		for scanner.Scan() {
+			done[scanner.Text()] = struct{}{}
+		}
+		f.Close()
+		if err = scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	logMsg(fmt.Sprintf("loaded %d entries from %d %s files", len(done), len(files), successFile))
+	return done, nil
+}
+
+// preservedMetadata returns the headers of stat that survive a copy with
+// ReplaceMetadata, keyed the way CopyDestOptions.UserMetadata expects.
+func preservedMetadata(stat miniogo.ObjectInfo) map[string]string {
+	meta := make(map[string]string)
+	for k, v := range stat.Metadata {
+		if len(v) == 0 {
+			continue
+		}
+		switch k = http.CanonicalHeaderKey(k); {
+		case k == "Content-Type", k == "Cache-Control", k == "Content-Encoding",
+			k == "Content-Language", k == "Content-Disposition", k == "Expires",
+			k == "X-Amz-Storage-Class":
+			meta[k] = v[0]
+		case strings.HasPrefix(k, "X-Amz-Meta-"):
+			meta[strings.TrimPrefix(k, "X-Amz-Meta-")] = v[0]
+		}
+	}
+	return meta
+}
+
+// metadataKey returns the header k in the form of the keys of
+// preservedMetadata, canonical and user metadata without X-Amz-Meta-.
+func metadataKey(k string) string {
+	return strings.TrimPrefix(http.CanonicalHeaderKey(k), "X-Amz-Meta-")
+}
+
+// storageClass set by --storage-class overrides the storage class of the
+// source object on the copy.
+var storageClass string
+
+// targetStorageClass returns the storage class to give the copy of the
+// object stat describes, empty for the destination default.
+func targetStorageClass(stat miniogo.ObjectInfo) string {
+	if storageClass != "" {
+		return storageClass
+	}
+	if stat.StorageClass != "" {
+		return stat.StorageClass
+	}
+	return stat.Metadata.Get("X-Amz-Storage-Class")
+}
+
+// setStorageClass makes a server side copy of the object stat describes
+// keep its storage class. A storage class can only be set on a copy by
+// replacing the metadata, so the metadata already set on dst, or else the
+// preserved metadata, is carried over.
+func setStorageClass(dst *miniogo.CopyDestOptions, stat miniogo.ObjectInfo) {
+	class := targetStorageClass(stat)
+	if class == "" {
+		return
+	}
+	meta := make(map[string]string)
+	if dst.UserMetadata == nil {
+		meta = preservedMetadata(stat)
+	}
+	for k, v := range dst.UserMetadata {
+		meta[k] = v
+	}
+	meta["X-Amz-Storage-Class"] = class
+	dst.ReplaceMetadata = true
+	dst.UserMetadata = meta
+}
+
+// sortOldestFirst orders the versions of a key as listed by ListObjects,
+// newest first, oldest first. The listing is reversed before the stable
+// sort so that versions within the same second of LastModified keep their
+// order, the latest version stays last.
+func sortOldestFirst(versions []miniogo.ObjectInfo) {
+	for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
+		versions[i], versions[j] = versions[j], versions[i]
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].LastModified.Before(versions[j].LastModified)
+	})
+}
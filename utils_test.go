@@ -0,0 +1,126 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+// TestConvertShallowKeys converts keys with fewer directories than the
+// default layout, as --prefix-file lets through.
+func TestConvertShallowKeys(t *testing.T) {
+	filePrefixes = []string{"a/", "x"}
+	defer func() { filePrefixes = nil }()
+
+	for key, expected := range map[string]string{
+		"a/x.jpg":   "x.jpg",
+		"a/b/x.jpg": "a/x.jpg",
+	} {
+		if !patternMatch(key) {
+			t.Fatalf("%s does not match the prefix file", key)
+		}
+		got, err := convertKey(key)
+		if err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		if got != expected {
+			t.Errorf("%s converts to %s, expected %s", key, got, expected)
+		}
+	}
+	if _, err := convertKey("x.jpg"); !errors.Is(err, errNothingToStrip) {
+		t.Errorf("x.jpg converts with %v, expected %v", err, errNothingToStrip)
+	}
+}
+
+// TestCheckPrefixes rejects --src-prefix and --dst-prefix that do not
+// relocate a subtree.
+func TestCheckPrefixes(t *testing.T) {
+	for _, c := range []struct {
+		src, dst string
+		valid    bool
+	}{
+		{"", "", true},
+		{"data/", "archive/", true},
+		{"data/archive/", "data/", true},
+		{"data/", "", true},
+		{"", "archive/", false},
+		{"data/", "data/", false},
+		{"data/", "data/archive/", false},
+	} {
+		err := checkPrefixes(c.src, c.dst)
+		if c.valid && err != nil {
+			t.Errorf("--src-prefix %q --dst-prefix %q: %v", c.src, c.dst, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("--src-prefix %q --dst-prefix %q is accepted", c.src, c.dst)
+		}
+	}
+}
+
+// TestSetStorageClassKeepsMetadata keeps the metadata already set on a
+// server side copy, by a transform or the source ACL, with the storage
+// class.
+func TestSetStorageClassKeepsMetadata(t *testing.T) {
+	stat := miniogo.ObjectInfo{
+		StorageClass: "REDUCED_REDUNDANCY",
+		Metadata:     http.Header{"Content-Type": []string{"image/jpeg"}},
+	}
+	dst := miniogo.CopyDestOptions{
+		ReplaceMetadata: true,
+		UserMetadata:    map[string]string{"Content-Type": "image/png", "X-Amz-Acl": "public-read"},
+	}
+	setStorageClass(&dst, stat)
+	for k, v := range map[string]string{
+		"Content-Type":        "image/png",
+		"X-Amz-Acl":           "public-read",
+		"X-Amz-Storage-Class": "REDUCED_REDUNDANCY",
+	} {
+		if dst.UserMetadata[k] != v {
+			t.Errorf("%s is %q, expected %q", k, dst.UserMetadata[k], v)
+		}
+	}
+
+	dst = miniogo.CopyDestOptions{}
+	setStorageClass(&dst, stat)
+	if !dst.ReplaceMetadata || dst.UserMetadata["Content-Type"] != "image/jpeg" {
+		t.Errorf("source metadata is not preserved: %v", dst.UserMetadata)
+	}
+}
+
+// TestSortOldestFirst keeps versions written within the same second in the
+// order they were written, the latest last.
+func TestSortOldestFirst(t *testing.T) {
+	second := time.Date(2021, 3, 1, 22, 0, 0, 0, time.UTC)
+	// As listed, newest first.
+	versions := []miniogo.ObjectInfo{
+		{VersionID: "v4", LastModified: second.Add(time.Second), IsLatest: true},
+		{VersionID: "v3", LastModified: second},
+		{VersionID: "v2", LastModified: second},
+		{VersionID: "v1", LastModified: second.Add(-time.Second)},
+	}
+	sortOldestFirst(versions)
+	for i, want := range []string{"v1", "v2", "v3", "v4"} {
+		if versions[i].VersionID != want {
+			t.Fatalf("version %d is %s, expected %s", i, versions[i].VersionID, want)
+		}
+	}
+}
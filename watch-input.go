@@ -0,0 +1,155 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/minio/cli"
+)
+
+// watchedObject is an object queued by watch mode.
+type watchedObject struct {
+	etag   string
+	queued time.Time
+}
+
+// watcher queues objects created in the source bucket, each version at
+// most once.
+type watcher struct {
+	seen map[string]watchedObject
+}
+
+// queue adds object to the migration queue unless the same version was
+// already queued.
+func (w *watcher) queue(object, etag string) {
+	if prev, ok := w.seen[object]; ok && prev.etag == etag {
+		return
+	}
+	if !inShard(object) {
+		return
+	}
+	if !patternMatch(object) {
+		logDMsg(fmt.Sprintf("ignoring %s, it doesn't match the expected pattern", object), nil)
+		return
+	}
+	if !admit() {
+		return
+	}
+	w.seen[object] = watchedObject{etag: etag, queued: time.Now()}
+	migrationState.queueUploadTask(object)
+	logDMsg(fmt.Sprintf("adding %s to migration queue", object), nil)
+}
+
+// queueFromWatch migrates objects created in the source bucket as their
+// notifications arrive, until interrupted. Right after subscribing the
+// watched prefixes are re-listed back to the cutover, and then every
+// --watch-relist for objects modified since the previous re-list minus
+// --watch-overlap, so objects whose notifications were lost are migrated
+// too.
+func queueFromWatch(ctx context.Context, cliCtx *cli.Context) error {
+	every := cliCtx.Duration("watch-relist")
+	overlap := cliCtx.Duration("watch-overlap")
+	if every <= 0 {
+		return errors.New("--watch-relist should be greater than 0")
+	}
+	cutover, err := cutoverSince(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	// An interrupt stops the notifications and any re-list in progress.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopCh := make(chan os.Signal, 1)
+	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stopCh)
+	go func() {
+		select {
+		case <-stopCh:
+			logMsg("stopping watch")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	events := []string{"s3:ObjectCreated:*"}
+	notifications := minioSrcClient.ListenBucketNotification(ctx, minioSrcBucket, srcPrefix, "", events)
+
+	w := &watcher{seen: make(map[string]watchedObject)}
+	// Catch the objects created since the cutover and while subscribing.
+	since := time.Now()
+	first := since
+	if !cutover.IsZero() && cutover.Before(first) {
+		first = cutover
+	}
+	if err := w.relist(ctx, first.Add(-overlap)); err != nil {
+		return watchErr(ctx, err)
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	logMsg(fmt.Sprintf("watching %s for new objects", minioSrcBucket))
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case info, ok := <-notifications:
+			if limitReached() {
+				return nil
+			}
+			if !ok {
+				return watchErr(ctx, errors.New("bucket notification stream closed"))
+			}
+			if info.Err != nil {
+				// The client reconnects by itself, the next re-list
+				// covers the gap.
+				logMsg(fmt.Sprintf("error listening for notifications: %s", info.Err))
+				continue
+			}
+			for _, record := range info.Records {
+				object, err := url.QueryUnescape(record.S3.Object.Key)
+				if err != nil {
+					logDMsg("unable to decode key "+record.S3.Object.Key, err)
+					continue
+				}
+				w.queue(object, record.S3.Object.ETag)
+			}
+		case <-ticker.C:
+			next := time.Now()
+			if err := w.relist(ctx, since.Add(-overlap)); err != nil {
+				return watchErr(ctx, err)
+			}
+			since = next
+		}
+	}
+}
+
+// watchErr returns err unless the watch was interrupted.
+func watchErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+/*
+ * MinIO Client (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/minio/cli"
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+// relist queues the objects of the watched prefixes modified since since,
+// catching anything the notifications missed.
+func (w *watcher) relist(ctx context.Context, since time.Time) error {
+	logMsg(fmt.Sprintf("re-listing objects modified since %s", since.Format(time.RFC3339)))
+	opts := miniogo.ListObjectsOptions{
+		Recursive: true,
+		Prefix:    srcPrefix,
+	}
+	objCh := minioSrcClient.ListObjects(ctx, minioSrcBucket, opts)
+	if len(filePrefixes) > 0 {
+		objCh = listFilePrefixes(ctx, minioSrcClient, minioSrcBucket, opts)
+	}
+	for object := range objCh {
+		if object.Err != nil {
+			return object.Err
+		}
+		if object.LastModified.Before(since) {
+			continue
+		}
+		w.queue(object.Key, object.ETag)
+	}
+	// Objects queued before the window are not listed again.
+	for object, o := range w.seen {
+		if o.queued.Before(since) {
+			delete(w.seen, object)
+		}
+	}
+	return nil
+}
+
+// cutoverSince returns the time the first re-list of --watch reaches back
+// to, --watch-since or else when object_listing.txt in data-dir was
+// generated. Objects created after the listing was taken and before the
+// watch subscribed are then migrated too. It is zero when neither is
+// known.
+func cutoverSince(cliCtx *cli.Context) (time.Time, error) {
+	if s := cliCtx.String("watch-since"); s != "" {
+		since, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return since, fmt.Errorf("unable to parse --watch-since %q: %v", s, err)
+		}
+		return since, nil
+	}
+	f, err := os.Open(path.Join(dirPath, objListFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	defer f.Close()
+	scanner := newRecordScanner(f)
+	if err = scanner.checkHeader(objListFile); err != nil {
+		return time.Time{}, err
+	}
+	if h := scanner.Header(); h != nil {
+		return h.generated, nil
+	}
+	return time.Time{}, nil
+}